@@ -0,0 +1,154 @@
+package clientid
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"unicode"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+// The DHCPv4 client identifier (option 61) is what ISC DHCP stores as the `uid`
+// of a lease. Its first octet is a type, see: https://datatracker.ietf.org/doc/html/rfc2132#section-9.14
+// - 0 means the rest is an opaque identifier, often text
+// - 1 to 254 are hardware types followed by an address, usually Ethernet and a MAC
+// - 255 is an IAID followed by a DUID, see: https://datatracker.ietf.org/doc/html/rfc4361#section-6.1
+
+type Kind int
+
+const (
+	KindOpaque Kind = iota
+	KindText
+	KindHardware
+	KindDUID
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindOpaque:
+		return "opaque"
+	case KindText:
+		return "text"
+	case KindHardware:
+		return "hardware"
+	case KindDUID:
+		return "duid"
+	default:
+		return "??"
+	}
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Kind) UnmarshalText(text []byte) error {
+	for _, kind := range []Kind{KindOpaque, KindText, KindHardware, KindDUID} {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown client identifier kind: %s", text)
+}
+
+const typeDUID = 255
+
+type Hardware struct {
+	HardwareType duid.HardwareType `json:"hwtype"`
+	HardwareAddr string            `json:"hwaddr"`
+}
+
+type ClientID struct {
+	Hex      string         `json:"hex"`
+	Kind     Kind           `json:"kind"`
+	Hardware *Hardware      `json:"hardware,omitempty"`
+	IAIDDUID *duid.IAIDDUID `json:"iaid-duid,omitempty"`
+	Text     string         `json:"text,omitempty"`
+}
+
+func Parse(uid []byte) (*ClientID, error) {
+	if len(uid) == 0 {
+		return nil, fmt.Errorf("empty client identifier")
+	}
+	res := &ClientID{
+		Hex:  hex.EncodeToString(uid),
+		Kind: KindOpaque,
+	}
+
+	switch typ := uid[0]; {
+	case typ == typeDUID:
+		iaidduid, err := duid.ParseIAIDDUID(uid[1:])
+		if err != nil {
+			return nil, fmt.Errorf("parse rfc 4361 client identifier: %w", err)
+		}
+		res.Kind = KindDUID
+		res.IAIDDUID = iaidduid
+
+	case typ != 0 && len(uid) > 1 && !isText(uid):
+		res.Kind = KindHardware
+		res.Hardware = &Hardware{
+			HardwareType: duid.HardwareType(typ),
+			HardwareAddr: net.HardwareAddr(uid[1:]).String(),
+		}
+
+	default:
+		// Type 0 is followed by opaque data, but some clients send an
+		// identifier without any type at all, typically a printable name.
+		// Ethernet's hardware type is not a printable character, so MACs aren't
+		// mistaken for text.
+		text := uid
+		if typ == 0 {
+			text = uid[1:]
+		}
+		if isText(text) {
+			res.Kind = KindText
+			res.Text = string(text)
+		}
+	}
+
+	return res, nil
+}
+
+func isText(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range string(b) {
+		if c == unicode.ReplacementChar || !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// HardwareAddr is the link-layer address the client identifies itself by,
+// either directly or through a DUID-LL or DUID-LLT.
+func (c *ClientID) HardwareAddr() (net.HardwareAddr, bool) {
+	var hwaddr string
+	switch {
+	case c.Hardware != nil && c.Hardware.HardwareType == duid.HardwareTypeEthernet:
+		hwaddr = c.Hardware.HardwareAddr
+	case c.IAIDDUID != nil && c.IAIDDUID.DUID.LL != nil:
+		hwaddr = c.IAIDDUID.DUID.LL.HardwareAddr
+	case c.IAIDDUID != nil && c.IAIDDUID.DUID.LLT != nil:
+		hwaddr = c.IAIDDUID.DUID.LLT.HardwareAddr
+	default:
+		return nil, false
+	}
+	mac, err := net.ParseMAC(hwaddr)
+	if err != nil {
+		return nil, false
+	}
+	return mac, true
+}
+
+// DUID is the DHCPv6 identity an RFC 4361 client shares between its v4 and v6
+// leases, or nil for any other kind of client identifier.
+func (c *ClientID) DUID() *duid.DUID {
+	if c.IAIDDUID == nil {
+		return nil
+	}
+	return c.IAIDDUID.DUID
+}
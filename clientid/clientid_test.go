@@ -0,0 +1,79 @@
+package clientid
+
+import (
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/octalstr"
+)
+
+func TestParseHardware(t *testing.T) {
+	uid, err := octalstr.Parse("\"\\001\\214\\334\\324+\\354l\"")
+	if err != nil {
+		t.Fatalf("parse octal input: %v\n", err)
+	}
+
+	cid, err := Parse(uid)
+	if err != nil {
+		t.Fatalf("parse client identifier: %v", err)
+	}
+
+	if cid.Kind != KindHardware {
+		t.Errorf("expected hardware but was %s", cid.Kind)
+	}
+	expectedHex := "018cdcd42bec6c"
+	if cid.Hex != expectedHex {
+		t.Errorf("expected hex to be %s but was %s", expectedHex, cid.Hex)
+	}
+	mac, ok := cid.HardwareAddr()
+	expectedMAC := "8c:dc:d4:2b:ec:6c"
+	if !ok || mac.String() != expectedMAC {
+		t.Errorf("expected MAC to be %s but was %s", expectedMAC, mac)
+	}
+}
+
+func TestParseDUID(t *testing.T) {
+	uid, err := octalstr.Parse("\"\\377\\320\\244\\257\\276\\000\\003\\000\\001 \\311\\320\\244\\257\\276\"")
+	if err != nil {
+		t.Fatalf("parse octal input: %v\n", err)
+	}
+
+	cid, err := Parse(uid)
+	if err != nil {
+		t.Fatalf("parse client identifier: %v", err)
+	}
+
+	if cid.Kind != KindDUID {
+		t.Errorf("expected duid but was %s", cid.Kind)
+	}
+	if cid.DUID() == nil || cid.DUID().Type != duid.DUIDTypeLL {
+		t.Fatalf("expected DUID-LL but was %+v", cid.DUID())
+	}
+	mac, ok := cid.HardwareAddr()
+	expectedMAC := "20:c9:d0:a4:af:be"
+	if !ok || mac.String() != expectedMAC {
+		t.Errorf("expected MAC to be %s but was %s", expectedMAC, mac)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	cid, err := Parse([]byte("\x00wopr"))
+	if err != nil {
+		t.Fatalf("parse client identifier: %v", err)
+	}
+	if cid.Kind != KindText || cid.Text != "wopr" {
+		t.Errorf("expected text wopr but was %s %q", cid.Kind, cid.Text)
+	}
+
+	cid, err = Parse([]byte{0, 0x01, 0x02})
+	if err != nil {
+		t.Fatalf("parse client identifier: %v", err)
+	}
+	if cid.Kind != KindOpaque {
+		t.Errorf("expected opaque but was %s", cid.Kind)
+	}
+
+	if _, err := Parse([]byte{0xff, 0, 0}); err == nil {
+		t.Error("expected truncated rfc 4361 client identifier to fail")
+	}
+}
@@ -0,0 +1,169 @@
+// Package compact drops the records of a dhcpd.leases file which later ones
+// supersede, as dhcpd does when it rewrites the file. dhcpd only appends to
+// the file between rewrites, so it grows without bound while dhcpd is down
+// or can't rewrite it.
+package compact
+
+import (
+	"bytes"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/lex"
+)
+
+// The declarations are kept as written, from where each begins to where the
+// next does, so details the parser doesn't know of, and comments, survive.
+// Top-level directives such as authoring-byte-order are always kept.
+
+type Options struct {
+	// Drop the leases of addresses whose latest binding ended longer ago,
+	// other than those backing a failover peer. None are dropped if 0.
+	ExpiredFor time.Duration
+	// Defaults to the time of the compaction
+	Now time.Time
+}
+
+type Summary struct {
+	Declarations int `json:"declarations"`
+	Kept         int `json:"kept"`
+	// Leases, failover peer states, hosts, classes and subclasses declared
+	// again later in the file
+	Superseded int `json:"superseded"`
+	// Leases of addresses whose latest binding ended ExpiredFor ago
+	Expired int `json:"expired"`
+	Before  int `json:"before"` // bytes
+	After   int `json:"after"`
+}
+
+// key identifies what a declaration records, which its latest declaration
+// holds, and where it begins
+func key(d dhcpd.Declaration) (string, lex.Pos, bool) {
+	switch d := d.(type) {
+	case *dhcpd.DHCPv4Lease:
+		return "lease " + d.IP.String(), d.Pos, true
+	case *dhcpd.FailoverPeerState:
+		return "failover peer " + d.Name, d.Pos, true
+	case *dhcpd.DynamicHost:
+		// Tombstones are kept, as a deleted host may be declared in dhcpd.conf
+		return "host " + d.Name, d.Pos, true
+	case *dhcpd.Class:
+		return "class " + d.Name, d.Pos, true
+	case *dhcpd.Subclass:
+		return "subclass " + d.Class + " " + d.Value, d.Pos, true
+	}
+	return "", lex.Pos{}, false
+}
+
+// Compact returns input with the superseded declarations dropped, along
+// with those expired if asked. It fails on lease files which don't parse,
+// leniently, rather than lose what follows an error.
+func Compact(input []byte, opts Options) ([]byte, *Summary, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	var declared []dhcpd.Declaration
+	declarations, errc := dhcpd.ParseLenient(bytes.NewReader(input))
+	for d := range declarations {
+		declared = append(declared, d)
+	}
+	if err := <-errc; err != nil {
+		return nil, nil, err
+	}
+
+	keys := map[lex.Pos]string{}
+	last := map[string]lex.Pos{}
+	expired := map[string]bool{}
+	for _, d := range declared {
+		k, pos, ok := key(d)
+		if !ok {
+			continue
+		}
+		keys[pos], last[k] = k, pos
+		if lease, ok := d.(*dhcpd.DHCPv4Lease); ok {
+			expired[k] = opts.ExpiredFor > 0 && lease.Ends != nil &&
+				lease.Ends.Before(opts.Now.Add(-opts.ExpiredFor)) && lease.BindingState != "backup"
+		}
+	}
+
+	starts := statements(input)
+	offset := offsets(input)
+	sum := &Summary{Declarations: len(starts), Before: len(input)}
+	var res bytes.Buffer
+	if len(starts) == 0 {
+		res.Write(input)
+	} else {
+		res.Write(input[:offset(starts[0])])
+	}
+	for i, start := range starts {
+		end := len(input)
+		if i+1 < len(starts) {
+			end = offset(starts[i+1])
+		}
+		if k, ok := keys[start]; ok {
+			switch {
+			case last[k] != start:
+				sum.Superseded++
+				continue
+			case expired[k]:
+				sum.Expired++
+				continue
+			}
+		}
+		sum.Kept++
+		res.Write(input[offset(start):end])
+	}
+	sum.After = res.Len()
+	return res.Bytes(), sum, nil
+}
+
+// statements are where the top-level statements of input begin
+func statements(input []byte) []lex.Pos {
+	var res []lex.Pos
+	var depth int
+	var inStatement bool
+	tokens := lex.Lex(bytes.NewReader(input))
+	for token := range tokens {
+		switch token.Typ {
+		case lex.ItemBeginBlock:
+			depth++
+		case lex.ItemEndBlock:
+			depth--
+			if depth == 0 {
+				inStatement = false
+			}
+		case lex.ItemSemicolon:
+			if depth == 0 {
+				inStatement = false
+			}
+		default:
+			if depth == 0 && !inStatement {
+				res = append(res, token.Pos)
+				inStatement = true
+			}
+		}
+	}
+	return res
+}
+
+// offsets returns the byte offset of a position in input
+func offsets(input []byte) func(pos lex.Pos) int {
+	lines := []int{0}
+	for i, b := range input {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return func(pos lex.Pos) int {
+		if pos.Line >= len(lines) {
+			return len(input)
+		}
+		off := lines[pos.Line]
+		for char := 0; char < pos.Char && off < len(input); char++ {
+			_, n := utf8.DecodeRune(input[off:])
+			off += n
+		}
+		return off
+	}
+}
@@ -0,0 +1,101 @@
+package compact
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const leasesFile = `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+
+lease 10.0.0.1 {
+  starts 1 2021/12/27 09:00:00;
+  ends 1 2021/12/27 10:00:00;
+  binding state active;
+}
+failover peer "dhcp-failover" state {
+  my state normal at 1 2021/12/27 08:00:00;
+  partner state normal at 1 2021/12/27 08:00:00;
+}
+lease 10.0.0.2 {
+  starts 1 2021/12/20 09:00:00;
+  ends 1 2021/12/20 10:00:00;
+  binding state free;
+}
+host wopr {
+  dynamic;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+lease 10.0.0.3 {
+  starts 1 2021/12/20 09:00:00;
+  ends never;
+  binding state active;
+}
+lease 10.0.0.1 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 10:30:00;
+  binding state active;
+  option agent.circuit-id eth0;
+}
+failover peer "dhcp-failover" state {
+  my state communications-interrupted at 1 2021/12/27 09:11:12;
+  partner state normal at 1 2021/12/27 08:00:00;
+}
+host wopr {
+  dynamic;
+  deleted;
+}
+`
+
+func TestCompact(t *testing.T) {
+	out, sum, err := Compact([]byte(leasesFile), Options{
+		ExpiredFor: 24 * time.Hour,
+		Now:        time.Date(2021, time.December, 27, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+
+lease 10.0.0.3 {
+  starts 1 2021/12/20 09:00:00;
+  ends never;
+  binding state active;
+}
+lease 10.0.0.1 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 10:30:00;
+  binding state active;
+  option agent.circuit-id eth0;
+}
+failover peer "dhcp-failover" state {
+  my state communications-interrupted at 1 2021/12/27 09:11:12;
+  partner state normal at 1 2021/12/27 08:00:00;
+}
+host wopr {
+  dynamic;
+  deleted;
+}
+`
+	if string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+	if sum.Declarations != 9 || sum.Kept != 5 || sum.Superseded != 3 || sum.Expired != 1 || sum.Before != len(leasesFile) || sum.After != len(expected) {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+
+	// Nothing expires without ExpiredFor
+	out, sum, err = Compact([]byte(leasesFile), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Expired != 0 || !strings.Contains(string(out), "lease 10.0.0.2 {") {
+		t.Errorf("expected 10.0.0.2 to be kept, got %+v", sum)
+	}
+
+	if _, _, err := Compact([]byte("lease 10.0.0.1 {\n  binding state active;\n"), Options{}); err == nil {
+		t.Error("expected a truncated file to fail")
+	}
+}
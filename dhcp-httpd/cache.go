@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/sources"
+)
+
+// fileKey identifies a version of a file. dhcpd appends to the lease file,
+// changing its size and mtime, and periodically rewrites it by renaming a
+// new file over it, changing its inode.
+type fileKey struct {
+	ino   uint64
+	size  int64
+	mtime int64 // nanoseconds
+}
+
+func statKey(path string) (fileKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileKey{}, err
+	}
+	return fileKey{ino: inode(info), size: info.Size(), mtime: info.ModTime().UnixNano()}, nil
+}
+
+type snapshot[T any] struct {
+	mu     sync.Mutex
+	valid  bool
+	key    fileKey
+	leases []T
+}
+
+// load returns the cached leases if the file is unchanged, otherwise parses
+// it again. Concurrent loads of a changed file wait on the one parse.
+func (s *snapshot[T]) load(path string, parse func() ([]T, error)) ([]T, fileKey, error) {
+	key, err := statKey(path)
+	if err != nil {
+		return nil, key, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.valid && s.key == key {
+		return s.leases, key, nil
+	}
+	// Should the file change during the parse, the next load parses it again
+	leases, err := parse()
+	if err != nil {
+		return nil, key, err
+	}
+	s.valid, s.key, s.leases = true, key, leases
+	return leases, key, nil
+}
+
+// LeaseFile is a lease file of a server, whose leases are tagged with the
+// name and site of its source when read alongside others. Read parses it,
+// along with any files it depends on which change with it.
+type LeaseFile[T any] struct {
+	// Of parsing the file, for the metrics. First, so they are aligned for
+	// atomic access on 32-bit platforms.
+	parseDuration int64 // nanoseconds, of the latest parse
+	parseErrors   uint64
+
+	Source *sources.Source
+	Read   func() ([]T, error)
+
+	snap snapshot[T]
+}
+
+func (f *LeaseFile[T]) load() ([]T, fileKey, error) {
+	return f.snap.load(f.Source.Path, f.read)
+}
+
+// read parses the file, recording how long it took and whether it failed
+func (f *LeaseFile[T]) read() ([]T, error) {
+	start := time.Now()
+	leases, err := f.Read()
+	atomic.StoreInt64(&f.parseDuration, int64(time.Since(start)))
+	if err != nil {
+		atomic.AddUint64(&f.parseErrors, 1)
+	}
+	return leases, err
+}
+
+// parses reports the duration of the latest parse of the file, and how many
+// have failed
+func (f *LeaseFile[T]) parses() (time.Duration, uint64) {
+	return time.Duration(atomic.LoadInt64(&f.parseDuration)), atomic.LoadUint64(&f.parseErrors)
+}
+
+// merged is the leases of several lease files, in the order of the files
+type merged[T any] struct {
+	mu     sync.Mutex
+	valid  bool
+	keys   []fileKey
+	leases []T
+}
+
+// load returns the merged leases of the files, tagged by tag if any, merging
+// them again if any has changed. dedupe, if any, drops the leases of some files
+// which are copies of those of others.
+func (m *merged[T]) load(files []*LeaseFile[T], tag func(*T, *sources.Source), dedupe func([][]T) [][]T) ([]T, []fileKey, error) {
+	if len(files) == 1 && tag == nil {
+		leases, key, err := files[0].load()
+		return leases, []fileKey{key}, err
+	}
+	keys := make([]fileKey, len(files))
+	parts := make([][]T, len(files))
+	for i, f := range files {
+		var err error
+		if parts[i], keys[i], err = f.load(); err != nil {
+			return nil, nil, err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.valid && equalKeys(m.keys, keys) {
+		return m.leases, keys, nil
+	}
+	if dedupe != nil {
+		parts = dedupe(parts)
+	}
+	var leases []T
+	for i, part := range parts {
+		for _, lease := range part {
+			if tag != nil {
+				tag(&lease, files[i].Source)
+			}
+			leases = append(leases, lease)
+		}
+	}
+	m.valid, m.keys, m.leases = true, keys, leases
+	return leases, keys, nil
+}
+
+func equalKeys(a, b []fileKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CachedSource keeps the leases of the latest version of each lease file so
+// they are only parsed again once the file changes. The returned leases are
+// shared between callers and must not be modified.
+type CachedSource struct {
+	V4Files []*LeaseFile[dhcpd.DHCPv4Lease]
+	V6Files []*LeaseFile[dhcpd6.DHCPv6Lease]
+	// The declarations other than leases of dhcpd's v4 lease files
+	DeclarationFiles []*LeaseFile[Declared]
+
+	v4           merged[dhcpd.DHCPv4Lease]
+	v6           merged[dhcpd6.DHCPv6Lease]
+	declarations merged[Declared]
+}
+
+func (c *CachedSource) DHCPv4Leases() ([]dhcpd.DHCPv4Lease, error) {
+	leases, _, err := c.loadDHCPv4()
+	return leases, err
+}
+
+func (c *CachedSource) DHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error) {
+	leases, _, err := c.loadDHCPv6()
+	return leases, err
+}
+
+func (c *CachedSource) loadDHCPv4() ([]dhcpd.DHCPv4Lease, []fileKey, error) {
+	tag := func(lease *dhcpd.DHCPv4Lease, s *sources.Source) {
+		lease.Source, lease.Site = s.Name, s.Site
+	}
+	// The peers of failover pairs share their leases
+	dedupe := func(parts [][]dhcpd.DHCPv4Lease) [][]dhcpd.DHCPv4Lease {
+		srcs := make([]*sources.Source, len(c.V4Files))
+		for i, f := range c.V4Files {
+			srcs[i] = f.Source
+		}
+		return sources.DedupeDHCPv4(srcs, parts)
+	}
+	if !c.Tagged() {
+		tag = nil
+	}
+	return c.v4.load(c.V4Files, tag, dedupe)
+}
+
+func (c *CachedSource) loadDHCPv6() ([]dhcpd6.DHCPv6Lease, []fileKey, error) {
+	tag := func(lease *dhcpd6.DHCPv6Lease, s *sources.Source) {
+		lease.Source, lease.Site = s.Name, s.Site
+	}
+	if !c.Tagged() {
+		tag = nil
+	}
+	return c.v6.load(c.V6Files, tag, nil)
+}
+
+// Declarations are those of dhcpd's v4 lease files other than leases, in
+// the order of the files, always tagged with their file
+func (c *CachedSource) Declarations() ([]Declared, error) {
+	tag := func(d *Declared, s *sources.Source) {
+		d.Source, d.Site = s.Name, s.Site
+	}
+	declared, _, err := c.declarations.load(c.DeclarationFiles, tag, nil)
+	return declared, err
+}
+
+// Tagged reports whether leases are tagged with the lease file they're from,
+// which they are unless there's nothing to tell apart
+func (c *CachedSource) Tagged() bool {
+	return len(c.V4Files) > 1 || len(c.V6Files) > 1
+}
+
+// Version loads the current versions of all the lease files and identifies
+// them, as an entity tag and the latest modification time.
+func (c *CachedSource) Version() (string, time.Time, error) {
+	_, v4keys, err := c.loadDHCPv4()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	_, v6keys, err := c.loadDHCPv6()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	h := fnv.New64a()
+	var modified time.Time
+	for _, key := range append(v4keys, v6keys...) {
+		fmt.Fprintf(h, "%d-%d-%d;", key.ino, key.size, key.mtime)
+		if mtime := time.Unix(0, key.mtime); mtime.After(modified) {
+			modified = mtime
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64()), modified, nil
+}
+
+// notModified sets the ETag and Last-Modified headers of a response derived
+// from the lease files and reports whether the request's conditions mean it
+// can be answered with 304 Not Modified, which it then does. The variant
+// distinguishes the representations of the leases. Responses which change
+// without the files, e.g. HTML showing relative times, shouldn't use this.
+func notModified(w http.ResponseWriter, r *http.Request, c *CachedSource, variant string) bool {
+	version, modified, err := c.Version()
+	if err != nil {
+		// The handler will fail to read the files too, and report it
+		return false
+	}
+	// Should either file change before the handler reads it, the response
+	// is newer than its tag says and so the next request is a miss.
+	etag := fmt.Sprintf("\"%s-%s\"", version, variant)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatch(match, etag) {
+			return false
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || modified.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// queryVariant distinguishes the representations of a resource selected by
+// the request's query
+func queryVariant(variant string, r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return variant
+	}
+	h := fnv.New64a()
+	io.WriteString(h, r.URL.RawQuery)
+	return fmt.Sprintf("%s-%x", variant, h.Sum64())
+}
+
+// etagMatch implements the weak comparison of If-None-Match, RFC 7232 3.2
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+)
+
+// Declared is a declaration of one of dhcpd's v4 lease files other than a
+// lease, tagged with the file
+type Declared struct {
+	dhcpd.Declaration
+	Source string
+	Site   string
+}
+
+// readDeclarations parses the declarations other than leases of the lease
+// file at path, in file order
+func readDeclarations(path string) ([]Declared, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []Declared
+	declarations, errc := dhcpd.ParseFile(f)
+	for d := range declarations {
+		if _, ok := d.(*dhcpd.DHCPv4Lease); !ok {
+			res = append(res, Declared{Declaration: d})
+		}
+	}
+	if err := <-errc; err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return res, nil
+}
+
+// latest is the last of the items of each key, in the order the keys first
+// appear, without those which are deleted
+func latest[K comparable, T any](items []T, key func(T) K, deleted func(T) bool) []T {
+	index := map[K]int{}
+	var res []T
+	for _, item := range items {
+		k := key(item)
+		if i, ok := index[k]; ok {
+			res[i] = item
+			continue
+		}
+		index[k] = len(res)
+		res = append(res, item)
+	}
+	kept := res[:0]
+	for _, item := range res {
+		if !deleted(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/follow"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// The events of /v1/events are those of the follow package, with the client
+// MAC and its vendor as the lease page shows them.
+
+type v4Message struct {
+	follow.DHCPv4Event
+	HardwareAddr string `json:"hwaddr,omitempty"`
+	Vendor       string `json:"vendor,omitempty"`
+}
+
+type v6Message struct {
+	follow.DHCPv6Event
+	HardwareAddr string `json:"hwaddr,omitempty"`
+	Vendor       string `json:"vendor,omitempty"`
+}
+
+// feedEvent is an encoded message, along with what it can be filtered by
+type feedEvent struct {
+	name     string // of the SSE event, v4 or v6
+	data     []byte
+	ip       netip.Addr
+	hwaddr   net.HardwareAddr
+	hostname string
+}
+
+// feed fans out the changes to the lease files to every /v1/events client
+type feed struct {
+	mu   sync.Mutex
+	subs map[chan *feedEvent]struct{}
+}
+
+var events = &feed{subs: map[chan *feedEvent]struct{}{}}
+
+func (f *feed) subscribe() chan *feedEvent {
+	ch := make(chan *feedEvent, 64)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[ch] = struct{}{}
+	return ch
+}
+
+func (f *feed) unsubscribe(ch chan *feedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[ch]; ok {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// publish never waits on a client. One which falls behind is disconnected
+// rather than silently missing events, EventSource will reconnect.
+func (f *feed) publish(e *feedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Time to wait before following a lease file again after an error
+const followRetry = 10 * time.Second
+
+func (f *feed) followDHCPv4(path string) {
+	for {
+		leaseEvents, errc := follow.DHCPv4(context.Background(), path, time.Second)
+		for event := range leaseEvents {
+			hookRun.dhcpv4(event)
+			msg := v4Message{DHCPv4Event: event}
+			mac, ok := inventory.DHCPv4HardwareAddr(event.Lease)
+			if ok {
+				msg.HardwareAddr = mac.String()
+				msg.Vendor = inventory.Vendor(mac)
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			f.publish(&feedEvent{name: "v4", data: data, ip: event.Lease.IP, hwaddr: mac, hostname: event.Lease.ClientHostname})
+		}
+		log.Printf("follow %s: %v\n", path, <-errc)
+		time.Sleep(followRetry)
+	}
+}
+
+func (f *feed) followDHCPv6(path string) {
+	for {
+		leaseEvents, errc := follow.DHCPv6(context.Background(), path, time.Second)
+		for event := range leaseEvents {
+			hookRun.dhcpv6(event)
+			msg := v6Message{DHCPv6Event: event}
+			mac, ok := inventory.DHCPv6HardwareAddr(event.Lease)
+			if ok {
+				msg.HardwareAddr = mac.String()
+				msg.Vendor = inventory.Vendor(mac)
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			f.publish(&feedEvent{name: "v6", data: data, ip: event.Addr.IP, hwaddr: mac})
+		}
+		log.Printf("follow %s: %v\n", path, <-errc)
+		time.Sleep(followRetry)
+	}
+}
+
+// eventFilter selects events by the query parameters subnet, mac and
+// hostname. Only v4 leases have hostnames.
+type eventFilter struct {
+	subnet   netip.Prefix
+	hwaddr   net.HardwareAddr
+	hostname string
+}
+
+func parseEventFilter(r *http.Request) (*eventFilter, error) {
+	var filter eventFilter
+	q := r.URL.Query()
+	if s := q.Get("subnet"); s != "" {
+		subnet, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("subnet: %w", err)
+		}
+		filter.subnet = subnet.Masked()
+	}
+	if s := q.Get("mac"); s != "" {
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return nil, fmt.Errorf("mac: %w", err)
+		}
+		filter.hwaddr = mac
+	}
+	filter.hostname = q.Get("hostname")
+	return &filter, nil
+}
+
+func (filter *eventFilter) match(e *feedEvent) bool {
+	if filter.subnet.IsValid() && !filter.subnet.Contains(e.ip) {
+		return false
+	}
+	if filter.hwaddr != nil && !bytes.Equal(filter.hwaddr, e.hwaddr) {
+		return false
+	}
+	if filter.hostname != "" && !strings.EqualFold(filter.hostname, e.hostname) {
+		return false
+	}
+	return true
+}
+
+// Comments keep idle connections from being closed by proxies
+const heartbeatInterval = 30 * time.Second
+
+// serveEvents streams changes to the leases as Server-Sent Events, see:
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+func serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := events.subscribe()
+	defer events.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !filter.match(e) {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+		}
+		flusher.Flush()
+	}
+}
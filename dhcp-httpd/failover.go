@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+)
+
+// FailoverPeer is the state of a failover peer as recorded in one of dhcpd's
+// v4 lease files, with a warning when either server can't reach the other
+type FailoverPeer struct {
+	dhcpd.FailoverPeerState
+	Source  string `json:"source,omitempty"`
+	Site    string `json:"site,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+type V1Failover struct {
+	Peers []FailoverPeer `json:"peers"`
+}
+
+// failoverWarning describes the state of a peer which needs attention: in
+// communications-interrupted a server can only renew the leases it knows of,
+// and in partner-down it serves the whole pool, trusting its partner is down.
+func failoverWarning(state *dhcpd.FailoverPeerState) string {
+	for _, s := range []struct{ server, state string }{
+		{"my", state.MyState},
+		{"partner", state.PartnerState},
+	} {
+		switch s.state {
+		case "communications-interrupted", "partner-down":
+			return fmt.Sprintf("failover peer %s: %s state %s", state.Name, s.server, s.state)
+		}
+	}
+	return ""
+}
+
+// FailoverPeers are the latest states of the failover peers of each of
+// dhcpd's v4 lease files
+func (c *CachedSource) FailoverPeers() ([]FailoverPeer, error) {
+	declared, err := c.Declarations()
+	if err != nil {
+		return nil, err
+	}
+	var peers []FailoverPeer
+	for _, d := range declared {
+		if state, ok := d.Declaration.(*dhcpd.FailoverPeerState); ok {
+			peers = append(peers, FailoverPeer{FailoverPeerState: *state, Source: d.Source, Site: d.Site, Warning: failoverWarning(state)})
+		}
+	}
+	return latest(peers,
+		func(peer FailoverPeer) [2]string { return [2]string{peer.Source, peer.Name} },
+		func(FailoverPeer) bool { return false }), nil
+}
+
+// failoverWarnings are the warnings of the failover peers by lease file,
+// or why they couldn't be read
+func failoverWarnings() []string {
+	peers, err := cache.FailoverPeers()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var res []string
+	for _, peer := range peers {
+		if peer.Warning != "" {
+			res = append(res, peer.Source+": "+peer.Warning)
+		}
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/follow"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/hooks"
+)
+
+// How often pool utilization is checked against the hooks' threshold
+const poolCheckInterval = 30 * time.Second
+
+// hookRunner feeds the lease events and pool statistics to the hooks, a nil
+// *hookRunner does nothing.
+type hookRunner struct {
+	detector   *hooks.Detector
+	dispatcher *hooks.Dispatcher
+}
+
+var hookRun *hookRunner
+
+func startHooks(path string) (*hookRunner, error) {
+	conf, err := hooks.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	detector, err := hooks.NewDetector(conf)
+	if err != nil {
+		return nil, err
+	}
+	// The leases there already aren't news
+	v4leases, err := cache.DHCPv4Leases()
+	if err != nil {
+		log.Printf("hooks: %v\n", err)
+	}
+	v6leases, err := cache.DHCPv6Leases()
+	if err != nil {
+		log.Printf("hooks: %v\n", err)
+	}
+	detector.Seed(v4leases, v6leases)
+	dispatcher, err := hooks.NewDispatcher(conf)
+	if err != nil {
+		detector.Close()
+		return nil, err
+	}
+	h := &hookRunner{detector: detector, dispatcher: dispatcher}
+	if conf.PoolThreshold > 0 {
+		go h.checkPools()
+	}
+	return h, nil
+}
+
+func (h *hookRunner) dhcpv4(e follow.DHCPv4Event) {
+	if h == nil {
+		return
+	}
+	for _, event := range h.detector.DHCPv4(e) {
+		h.dispatcher.Dispatch(event)
+	}
+}
+
+func (h *hookRunner) dhcpv6(e follow.DHCPv6Event) {
+	if h == nil {
+		return
+	}
+	for _, event := range h.detector.DHCPv6(e) {
+		h.dispatcher.Dispatch(event)
+	}
+}
+
+func (h *hookRunner) checkPools() {
+	for range time.Tick(poolCheckInterval) {
+		pools, err := fetchPools()
+		if err != nil {
+			log.Printf("hooks: %v\n", err)
+			continue
+		}
+		for _, event := range h.detector.Pools(pools.Pools) {
+			h.dispatcher.Dispatch(event)
+		}
+	}
+}
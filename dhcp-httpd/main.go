@@ -1,24 +1,34 @@
 package main
 
 import (
-	"bytes"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"math"
 	"net"
 	"net/http"
-	"os/exec"
+	"net/netip"
+	"os"
 	"strings"
 	"time"
 
 	autoneg "github.com/adjust/goautoneg"
 	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
 	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
-	"github.com/cptaffe/isc-dhcpd-lease-parser/macvendors"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dnsmasq"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonv2"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/kea"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/query"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/sources"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/stats"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/tabular"
 )
 
 //go:embed templates
@@ -94,16 +104,9 @@ var funcs = template.FuncMap{
 		if err != nil {
 			log.Printf("parse mac %s: %v\n", mac, err)
 		}
-		if macvendors.IsLocal(hw) {
-			return "Local"
-		}
-		vendor := macvendors.Lookup(hw)
-		if vendor == "" {
-			return "Private"
-		}
-		return vendor
+		return inventory.Vendor(hw)
 	},
-	"revdns": func(ip net.IP) string {
+	"revdns": func(ip netip.Addr) string {
 		hosts, err := net.LookupAddr(ip.String())
 		if err != nil || len(hosts) == 0 {
 			return ""
@@ -116,24 +119,255 @@ var funcs = template.FuncMap{
 	"title": strings.Title,
 }
 var leasesTemplate = template.Must(template.New("leases.html").Funcs(funcs).ParseFS(content, "templates/leases.html"))
-var v4LeaseFileFlag = flag.String("v4f", "/var/lib/dhcp/dhcpd.leases", "Path to dhcpd.leases file")
-var v6LeaseFileFlag = flag.String("v6f", "/var/lib/dhcp/dhcpd6.leases", "Path to dhcpd6.leases file")
+var devicesTemplate = template.Must(template.New("devices.html").Funcs(funcs).ParseFS(content, "templates/devices.html"))
+var poolsTemplate = template.Must(template.New("pools.html").Funcs(funcs).ParseFS(content, "templates/pools.html"))
+var failoverTemplate = template.Must(template.New("failover.html").Funcs(funcs).ParseFS(content, "templates/failover.html"))
+var reservationsTemplate = template.Must(template.New("reservations.html").Funcs(funcs).ParseFS(content, "templates/reservations.html"))
+var resourceTemplate = template.Must(template.New("resource.html").Funcs(funcs).ParseFS(content, "templates/resource.html"))
+var v4LeaseFileFlag = flag.String("v4f", "/var/lib/dhcp/dhcpd.leases", "Path to dhcpd.leases file, or empty for none")
+var v6LeaseFileFlag = flag.String("v6f", "/var/lib/dhcp/dhcpd6.leases", "Path to dhcpd6.leases file, or empty for none")
+var kea4LeaseFileFlag = flag.String("kea4f", "", "Path to Kea's kea-leases4.csv file, shown alongside dhcpd's leases")
+var kea6LeaseFileFlag = flag.String("kea6f", "", "Path to Kea's kea-leases6.csv file, shown alongside dhcpd's leases")
+var dnsmasqLeaseFileFlag = flag.String("dnsmasqf", "", "Path to dnsmasq.leases file, shown alongside dhcpd's leases")
+var sourcesFlag = flag.String("sources", "", "Path to a JSON file of named lease files by site and failover role, see sources.Config for its format")
+var v4ConfFileFlag = flag.String("v4c", "/etc/dhcp/dhcpd.conf", "Path to dhcpd.conf file, for pool statistics")
+var v6ConfFileFlag = flag.String("v6c", "/etc/dhcp/dhcpd6.conf", "Path to dhcpd6.conf file, for pool statistics")
 var listenFlag = flag.String("l", ":8080", "Listen interface e.g. :80 or 192.168.1.1:80")
+var execFlag = flag.Bool("exec", false, "Parse lease files by running dhcpd2json and dhcpd62json, isolating the server from the parsers")
+var hooksFlag = flag.String("hooks", "", "Path to a JSON file configuring webhooks and commands to run on lease events")
+
+var cache *CachedSource
 
 type V1Leases struct {
 	DHCPv4Leases []dhcpd.DHCPv4Lease  `json:"v4Leases"`
 	DHCPv6Leases []dhcpd6.DHCPv6Lease `json:"v6Leases"`
+	// Cursor of the next page when limited, see the query package
+	Next string `json:"next,omitempty"`
+}
+
+// leasesPage is what the lease page is rendered from
+type leasesPage struct {
+	V1Leases
+	Sorted  bool // by the query, rather than the table
+	Tagged  bool // with the server each lease is from
+	NextURL string
+	// Of failover peers which need attention
+	Warnings []string
+}
+
+type V1Devices struct {
+	Devices []*inventory.Device `json:"devices"`
+}
+
+type V1Pools struct {
+	Pools []*stats.Usage `json:"pools"`
+}
+
+// sourceList is the sources given by repeated -source flags
+type sourceList []*sources.Source
+
+func (l *sourceList) String() string {
+	return fmt.Sprint(len(*l), " sources")
+}
+
+func (l *sourceList) Set(spec string) error {
+	s, err := sources.ParseSource(spec)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+var sourceFlags sourceList
+
+// configuredSources are those of -sources, then -source, then the lease file
+// flags. dhcpd's default lease files are only read when no others are given.
+func configuredSources() (*sources.Config, error) {
+	conf := &sources.Config{}
+	if *sourcesFlag != "" {
+		c, err := sources.LoadConfig(*sourcesFlag)
+		if err != nil {
+			return nil, err
+		}
+		conf.Sources = c.Sources
+	}
+	conf.Sources = append(conf.Sources, sourceFlags...)
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	defaults := len(conf.Sources) == 0
+	if *v4LeaseFileFlag != "" && (defaults || set["v4f"]) {
+		conf.Sources = append(conf.Sources, &sources.Source{Name: "isc", Path: *v4LeaseFileFlag, Family: 4})
+	}
+	if *v6LeaseFileFlag != "" && (defaults || set["v6f"]) {
+		conf.Sources = append(conf.Sources, &sources.Source{Name: "isc", Path: *v6LeaseFileFlag, Family: 6})
+	}
+	if *kea4LeaseFileFlag != "" {
+		conf.Sources = append(conf.Sources, &sources.Source{Name: "kea", Path: *kea4LeaseFileFlag, Family: 4, Format: sources.FormatKea})
+	}
+	if *kea6LeaseFileFlag != "" {
+		conf.Sources = append(conf.Sources, &sources.Source{Name: "kea", Path: *kea6LeaseFileFlag, Family: 6, Format: sources.FormatKea})
+	}
+	if *dnsmasqLeaseFileFlag != "" {
+		conf.Sources = append(conf.Sources, &sources.Source{Name: "dnsmasq", Path: *dnsmasqLeaseFileFlag, Format: sources.FormatDnsmasq})
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// newCachedSource reads the lease files of the sources, in their order
+func newCachedSource(conf *sources.Config) *CachedSource {
+	c := &CachedSource{}
+	for _, s := range conf.Sources {
+		path := s.Path
+		switch s.Format {
+		case sources.FormatISC:
+			var source LeaseSource = &FileSource{V4Path: path, V6Path: path}
+			if *execFlag {
+				source = &ExecSource{V4Path: path, V6Path: path}
+			}
+			if s.Family == 4 {
+				c.V4Files = append(c.V4Files, &LeaseFile[dhcpd.DHCPv4Lease]{Source: s, Read: source.DHCPv4Leases})
+				// Parsed in-process, even with -exec
+				c.DeclarationFiles = append(c.DeclarationFiles, &LeaseFile[Declared]{Source: s, Read: func() ([]Declared, error) {
+					return readDeclarations(path)
+				}})
+			} else {
+				c.V6Files = append(c.V6Files, &LeaseFile[dhcpd6.DHCPv6Lease]{Source: s, Read: source.DHCPv6Leases})
+			}
+		case sources.FormatKea:
+			if s.Family == 4 {
+				c.V4Files = append(c.V4Files, &LeaseFile[dhcpd.DHCPv4Lease]{Source: s, Read: func() ([]dhcpd.DHCPv4Lease, error) {
+					return parseKea(path, kea.ParseDHCPv4)
+				}})
+			} else {
+				c.V6Files = append(c.V6Files, &LeaseFile[dhcpd6.DHCPv6Lease]{Source: s, Read: func() ([]dhcpd6.DHCPv6Lease, error) {
+					return parseKea(path, kea.ParseDHCPv6)
+				}})
+			}
+		case sources.FormatDnsmasq:
+			if s.HasFamily(4) {
+				c.V4Files = append(c.V4Files, &LeaseFile[dhcpd.DHCPv4Lease]{Source: s, Read: func() ([]dhcpd.DHCPv4Lease, error) {
+					return parseFile(path, dnsmasq.ParseDHCPv4)
+				}})
+			}
+			if s.HasFamily(6) {
+				c.V6Files = append(c.V6Files, &LeaseFile[dhcpd6.DHCPv6Lease]{Source: s, Read: func() ([]dhcpd6.DHCPv6Lease, error) {
+					return parseFile(path, dnsmasq.ParseDHCPv6)
+				}})
+			}
+		}
+	}
+	return c
 }
 
 func main() {
+	flag.Var(&sourceFlags, "source", "A named lease file, repeatable, as comma separated key=value fields of sources.Source, e.g. name=dc1-a,path=/srv/dc1-a/dhcpd.leases,family=4,site=dc1,role=primary")
 	flag.Parse()
 
+	conf, err := configuredSources()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cache = newCachedSource(conf)
+	if err := cache.Watch(); err != nil {
+		log.Printf("watch lease files, changes will be noticed on request: %v\n", err)
+	}
+	if *hooksFlag != "" {
+		if hookRun, err = startHooks(*hooksFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Only dhcpd's lease files are followed for events
+	for _, s := range conf.Sources {
+		switch {
+		case s.Format != sources.FormatISC:
+		case s.Family == 4:
+			go events.followDHCPv4(s.Path)
+		default:
+			go events.followDHCPv6(s.Path)
+		}
+	}
+
 	// Convenience redirect
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/v1/leases", http.StatusMovedPermanently)
 	})
 
 	http.HandleFunc("/v1/leases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := query.Parse(r.URL.Query(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		columns, err := tabular.ParseColumns(r.URL.Query().Get("columns"))
+		if err != nil {
+			http.Error(w, "columns: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ct := autoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html", "text/csv", "text/tab-separated-values"})
+		// Relative times select different leases as time passes
+		if ct == "application/json" && !q.Relative() && notModified(w, r, cache, queryVariant("v1", r)) {
+			return
+		}
+		// Queries want the leases in file order, they put the newest first
+		v4leases, err := cache.DHCPv4Leases()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch v4 leases", http.StatusInternalServerError)
+			return
+		}
+		v6leases, err := cache.DHCPv6Leases()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch v6 leases", http.StatusInternalServerError)
+			return
+		}
+		res, err := q.Apply(v4leases, v6leases)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		leases := V1Leases{DHCPv4Leases: res.DHCPv4Leases, DHCPv6Leases: res.DHCPv6Leases, Next: res.Next}
+		page := leasesPage{V1Leases: leases, Sorted: len(q.Sort) > 0, Tagged: cache.Tagged(), Warnings: failoverWarnings()}
+		if res.Next != "" {
+			next := *r.URL
+			values := next.Query()
+			values.Set("cursor", res.Next)
+			next.RawQuery = values.Encode()
+			page.NextURL = next.RequestURI()
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", page.NextURL))
+		}
+		switch ct {
+		case "application/json":
+			json.NewEncoder(w).Encode(leases)
+		case "text/html":
+			err = leasesTemplate.Execute(w, page)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, "Failed to present leases", http.StatusInternalServerError)
+				return
+			}
+		case "text/csv", "text/tab-separated-values":
+			comma := ','
+			if ct == "text/tab-separated-values" {
+				comma = '\t'
+			}
+			w.Header().Set("Content-Type", ct+"; charset=utf-8")
+			err = writeTable(tabular.NewWriter(w, comma, columns), leases)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	})
+
+	http.HandleFunc("/v1/devices", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
 			return
@@ -151,68 +385,221 @@ func main() {
 			http.Error(w, "Failed to fetch v6 leases", http.StatusInternalServerError)
 			return
 		}
-		leases := V1Leases{DHCPv4Leases: v4leases, DHCPv6Leases: v6leases}
+		devices := V1Devices{Devices: inventory.Correlate(v4leases, v6leases)}
 		switch ct {
 		case "application/json":
-			json.NewEncoder(w).Encode(leases)
+			json.NewEncoder(w).Encode(devices)
 		case "text/html":
-			err = leasesTemplate.Execute(w, leases)
+			err = devicesTemplate.Execute(w, devices)
 			if err != nil {
 				log.Println(err)
-				http.Error(w, "Failed to present leases", http.StatusInternalServerError)
+				http.Error(w, "Failed to present devices", http.StatusInternalServerError)
 				return
 			}
 		}
 	})
+
+	http.HandleFunc("/v1/failover", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		ct := autoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html"})
+		peers, err := cache.FailoverPeers()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch failover peer states", http.StatusInternalServerError)
+			return
+		}
+		failover := V1Failover{Peers: peers}
+		switch ct {
+		case "application/json":
+			json.NewEncoder(w).Encode(failover)
+		case "text/html":
+			err = failoverTemplate.Execute(w, failover)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, "Failed to present failover peer states", http.StatusInternalServerError)
+				return
+			}
+		}
+	})
+
+	http.HandleFunc("/v1/reservations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		ct := autoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html"})
+		reservations, err := cache.Reservations()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch reservations", http.StatusInternalServerError)
+			return
+		}
+		switch ct {
+		case "application/json":
+			json.NewEncoder(w).Encode(reservations)
+		case "text/html":
+			err = reservationsTemplate.Execute(w, reservations)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, "Failed to present reservations", http.StatusInternalServerError)
+				return
+			}
+		}
+	})
+
+	http.HandleFunc("/v1/pools", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		ct := autoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html"})
+		pools, err := fetchPools()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to compute pool statistics", http.StatusInternalServerError)
+			return
+		}
+		switch ct {
+		case "application/json":
+			json.NewEncoder(w).Encode(pools)
+		case "text/html":
+			err = poolsTemplate.Execute(w, pools)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, "Failed to present pools", http.StatusInternalServerError)
+				return
+			}
+		}
+	})
+
+	// v2 differs from v1 only in its JSON representation, see the jsonv2 package
+	http.HandleFunc("/v2/leases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if notModified(w, r, cache, "v2") {
+			return
+		}
+		v4leases, err := fetchDHCPv4Leases()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch v4 leases", http.StatusInternalServerError)
+			return
+		}
+		v6leases, err := fetchDHCPv6Leases()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to fetch v6 leases", http.StatusInternalServerError)
+			return
+		}
+		leases := jsonv2.Leases{
+			Schema:       jsonv2.Schema,
+			DHCPv4Leases: make([]*jsonv2.DHCPv4Lease, 0, len(v4leases)),
+			DHCPv6Leases: make([]*jsonv2.DHCPv6Lease, 0, len(v6leases)),
+		}
+		for i := range v4leases {
+			leases.DHCPv4Leases = append(leases.DHCPv4Leases, jsonv2.FromDHCPv4Lease(&v4leases[i]))
+		}
+		for i := range v6leases {
+			leases.DHCPv6Leases = append(leases.DHCPv6Leases, jsonv2.FromDHCPv6Lease(&v6leases[i]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(leases)
+	})
+	http.HandleFunc("/v1/leases/v4/", serveV4Lease)
+	http.HandleFunc("/v1/leases/v6/", serveV6Lease)
+	http.HandleFunc("/v1/clients/mac/", serveClientMAC)
+	http.HandleFunc("/v1/clients/duid/", serveClientDUID)
+	http.HandleFunc("/v1/prefixes/", servePrefix)
+	http.HandleFunc("/v1/events", serveEvents)
+	http.HandleFunc("/metrics", serveMetrics)
+	http.HandleFunc("/v1/openapi.json", serveOpenAPI)
+
 	log.Fatal(http.ListenAndServe(*listenFlag, nil)) // CAP_NET_BIND_SERVICE
 }
 
 func fetchDHCPv4Leases() ([]dhcpd.DHCPv4Lease, error) {
-	var leases []dhcpd.DHCPv4Lease
-	cmd := exec.Command("dhcpd2json", "-f", *v4LeaseFileFlag)
-	stdout, err := cmd.StdoutPipe()
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	leases, err := cache.DHCPv4Leases()
+	if err != nil {
+		return nil, err
+	}
+	// Reverse to put the newest (by start date) on top
+	return reversed(leases), nil
+}
+
+func fetchDHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error) {
+	leases, err := cache.DHCPv6Leases()
 	if err != nil {
-		return leases, fmt.Errorf("dhcpd2json pipe: %w", err)
+		return nil, err
 	}
-	if err := cmd.Start(); err != nil {
-		return leases, fmt.Errorf("dhcpd2json start: %w", err)
+	// Reverse to put the newest (by start date) on top
+	return reversed(leases), nil
+}
+
+// readConfig parses a dhcpd.conf, which is optional, so a missing file is
+// treated as one without any declarations.
+func readConfig(path string) (*dhcpdconf.Config, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &dhcpdconf.Config{}, nil
 	}
-	dec := json.NewDecoder(stdout)
-	for dec.More() {
-		var lease dhcpd.DHCPv4Lease
-		dec.Decode(&lease)
-		// Reverse to put the newest (by start date) on top
-		leases = append([]dhcpd.DHCPv4Lease{lease}, leases...)
+	if err != nil {
+		return nil, err
 	}
-	if err := cmd.Wait(); err != nil {
-		return leases, fmt.Errorf("dhcpd2json wait: %w, stderr: %s", err, stderr.String())
+	defer f.Close()
+	conf, err := dhcpdconf.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
 	}
-	return leases, nil
+	return conf, nil
 }
 
-func fetchDHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error) {
-	var leases []dhcpd6.DHCPv6Lease
-	cmd := exec.Command("dhcpd62json", "-f", *v6LeaseFileFlag)
-	stdout, err := cmd.StdoutPipe()
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+func fetchPools() (V1Pools, error) {
+	var pools V1Pools
+	v4conf, err := readConfig(*v4ConfFileFlag)
 	if err != nil {
-		return leases, fmt.Errorf("dhcpd62json pipe: %w", err)
+		return pools, err
 	}
-	if err := cmd.Start(); err != nil {
-		return leases, fmt.Errorf("dhcpd62json start: %w", err)
+	v6conf, err := readConfig(*v6ConfFileFlag)
+	if err != nil {
+		return pools, err
+	}
+	// Statistics want the leases in file order, not newest first
+	v4leases, err := cache.DHCPv4Leases()
+	if err != nil {
+		return pools, err
+	}
+	v6leases, err := cache.DHCPv6Leases()
+	if err != nil {
+		return pools, err
+	}
+	pools.Pools = append(stats.DHCPv4(v4conf, v4leases), stats.DHCPv6(v6conf, v6leases)...)
+	return pools, nil
+}
+
+// writeTable writes the leases as rows, a row per address of v6 leases
+func writeTable(table *tabular.Writer, leases V1Leases) error {
+	for i := range leases.DHCPv4Leases {
+		if err := table.DHCPv4(&leases.DHCPv4Leases[i]); err != nil {
+			return err
+		}
 	}
-	dec := json.NewDecoder(stdout)
-	for dec.More() {
-		var lease dhcpd6.DHCPv6Lease
-		dec.Decode(&lease)
-		// Reverse to put the newest (by start date) on top
-		leases = append([]dhcpd6.DHCPv6Lease{lease}, leases...)
+	for i := range leases.DHCPv6Leases {
+		if err := table.DHCPv6(&leases.DHCPv6Leases[i]); err != nil {
+			return err
+		}
 	}
-	if err := cmd.Wait(); err != nil {
-		return leases, fmt.Errorf("dhcpd62json wait: %w, stderr: %s", err, stderr.String())
+	return table.Flush()
+}
+
+func reversed[T any](s []T) []T {
+	res := make([]T, len(s))
+	for i, v := range s {
+		res[len(s)-1-i] = v
 	}
-	return leases, nil
+	return res
 }
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/sources"
+)
+
+func lease(ip string) string {
+	return fmt.Sprintf(`lease %s {
+  ends 6 2021/12/25 22:00:00;
+  binding state active;
+}
+`, ip)
+}
+
+// countedSource caches the v4 lease file at path, counting its parses
+func countedSource(path string) (*CachedSource, *int32) {
+	var parses int32
+	read := func() ([]dhcpd.DHCPv4Lease, error) {
+		atomic.AddInt32(&parses, 1)
+		return (&FileSource{V4Path: path}).DHCPv4Leases()
+	}
+	c := &CachedSource{V4Files: []*LeaseFile[dhcpd.DHCPv4Lease]{
+		{Source: &sources.Source{Name: "isc", Path: path, Family: 4}, Read: read},
+	}}
+	return c, &parses
+}
+
+func writeFile(t *testing.T, path, s string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replaceFile renames a new file over path, as dhcpd does when rewriting it
+func replaceFile(t *testing.T, path, s string, mtime time.Time) {
+	t.Helper()
+	tmp := path + ".tmp"
+	writeFile(t, tmp, s, mtime)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	v4path, v6path := filepath.Join(dir, "dhcpd.leases"), filepath.Join(dir, "dhcpd6.leases")
+	mtime := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	writeFile(t, v4path, lease("10.0.0.1")+lease("10.0.0.2"), mtime)
+	writeFile(t, v6path, `ia-na "\001\000\000\000\000\003\000\001 \311\320\244\257\276" {
+  cltt 6 2021/12/25 22:24:37;
+  iaaddr 2001:db8::1 {
+    binding state active;
+  }
+}
+`, mtime)
+	s := &FileSource{V4Path: v4path, V6Path: v6path}
+	v4leases, err := s.DHCPv4Leases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v4leases) != 2 || v4leases[1].IP.String() != "10.0.0.2" {
+		t.Errorf("expected 2 v4 leases in file order, got %+v", v4leases)
+	}
+	v6leases, err := s.DHCPv6Leases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v6leases) != 1 || len(v6leases[0].Addrs) != 1 || v6leases[0].Addrs[0].IP.String() != "2001:db8::1" {
+		t.Errorf("expected the v6 lease of 2001:db8::1, got %+v", v6leases)
+	}
+
+	// Errors name the file
+	writeFile(t, v4path, lease("10.0.0.1")+"lease {\n", mtime)
+	if _, err := s.DHCPv4Leases(); err == nil || !strings.HasPrefix(err.Error(), "parse "+v4path+": ") {
+		t.Errorf("expected an error parsing %s, got %v", v4path, err)
+	}
+	s.V6Path = filepath.Join(dir, "missing.leases")
+	if _, err := s.DHCPv6Leases(); !os.IsNotExist(err) {
+		t.Errorf("expected the file not to exist, got %v", err)
+	}
+}
+
+func TestCachedSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	mtime := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	writeFile(t, path, lease("10.0.0.1"), mtime)
+	c, parses := countedSource(path)
+
+	expect := func(what string, n int32, ips ...string) {
+		t.Helper()
+		leases, err := c.DHCPv4Leases()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, lease := range leases {
+			got = append(got, lease.IP.String())
+		}
+		if strings.Join(got, " ") != strings.Join(ips, " ") {
+			t.Errorf("%s: expected leases %v, got %v", what, ips, got)
+		}
+		if p := atomic.LoadInt32(parses); p != n {
+			t.Errorf("%s: expected %d parses, got %d", what, n, p)
+		}
+	}
+	expect("first read", 1, "10.0.0.1")
+	expect("unchanged", 1, "10.0.0.1")
+
+	// dhcpd appends, changing the size
+	writeFile(t, path, lease("10.0.0.1")+lease("10.0.0.2"), mtime)
+	expect("appended", 2, "10.0.0.1", "10.0.0.2")
+
+	// The same size written again, changing the mtime
+	mtime = mtime.Add(time.Second)
+	writeFile(t, path, lease("10.0.0.1")+lease("10.0.0.3"), mtime)
+	expect("rewritten", 3, "10.0.0.1", "10.0.0.3")
+
+	// A new file of the same size and mtime renamed over it, changing the
+	// inode where there are inodes
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inode(info) == 0 {
+		t.Log("no inodes, skipping the rename")
+		return
+	}
+	replaceFile(t, path, lease("10.0.0.1")+lease("10.0.0.4"), mtime)
+	expect("replaced", 4, "10.0.0.1", "10.0.0.4")
+}
+
+func TestNotModified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	mtime := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	writeFile(t, path, lease("10.0.0.1"), mtime)
+	c, parses := countedSource(path)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if notModified(w, r, c, queryVariant("v1", r)) {
+			return
+		}
+		leases, err := c.DHCPv4Leases()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(w, len(leases))
+	})
+	get := func(target string, header ...string) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	res := get("/v1/leases")
+	etag, modified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if res.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", res.StatusCode, etag)
+	}
+	if expected := mtime.Format(http.TimeFormat); modified != expected {
+		t.Errorf("expected Last-Modified %s, got %s", expected, modified)
+	}
+
+	for _, tt := range []struct {
+		header []string
+		status int
+	}{
+		{[]string{"If-None-Match", etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", "W/" + etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", "*"}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other"`}, http.StatusOK},
+		{[]string{"If-Modified-Since", modified}, http.StatusNotModified},
+		{[]string{"If-Modified-Since", mtime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{[]string{"If-Modified-Since", "yesterday"}, http.StatusOK},
+		// If-None-Match takes precedence
+		{[]string{"If-None-Match", `"other"`, "If-Modified-Since", modified}, http.StatusOK},
+	} {
+		if res := get("/v1/leases", tt.header...); res.StatusCode != tt.status {
+			t.Errorf("%v: expected %d, got %d", tt.header, tt.status, res.StatusCode)
+		}
+	}
+	if p := atomic.LoadInt32(parses); p != 1 {
+		t.Errorf("expected the file to be parsed once, got %d", p)
+	}
+
+	// Each query is its own representation
+	if res := get("/v1/leases?state=active", "If-None-Match", etag); res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Errorf("expected a query to have its own ETag, got %d %s", res.StatusCode, res.Header.Get("ETag"))
+	}
+
+	// A change to the file is a new version
+	mtime = mtime.Add(time.Minute)
+	writeFile(t, path, lease("10.0.0.1")+lease("10.0.0.2"), mtime)
+	res = get("/v1/leases", "If-None-Match", etag, "If-Modified-Since", modified)
+	if res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Errorf("expected a new version, got %d %s", res.StatusCode, res.Header.Get("ETag"))
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != "2\n" {
+		t.Errorf("expected both leases, got %q", body)
+	}
+	if res := get("/v1/leases", "If-Modified-Since", modified); res.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 since the file was modified, got %d", res.StatusCode)
+	}
+}
+
+func TestServeResourceNotModified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	writeFile(t, path, lease("10.0.0.1"), time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC))
+	cache, _ = countedSource(path)
+	defer func() { cache = nil }()
+
+	for _, tt := range []struct {
+		name   string
+		err    error
+		status int
+		etag   bool
+	}{
+		{"found", nil, http.StatusNotModified, true},
+		{"not found", errNotFound, http.StatusNotFound, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/clients/mac/00:00:00:00:00:01", nil)
+		r.Header.Set("Accept", "application/json")
+		r.Header.Set("If-None-Match", "*")
+		w := httptest.NewRecorder()
+		serveResource(w, r, "00:00:00:00:00:01", func() (*V1Resource, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &V1Resource{}, nil
+		})
+		res := w.Result()
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, res.StatusCode)
+		}
+		if etag := res.Header.Get("ETag"); (etag != "") != tt.etag {
+			t.Errorf("%s: expected an ETag %t, got %q", tt.name, tt.etag, etag)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(serveEvents))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		query  string
+		status int
+	}{
+		{"subnet=10.0.0.0", http.StatusBadRequest},
+		{"mac=printer", http.StatusBadRequest},
+	} {
+		res, err := http.Get(srv.URL + "?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.query, tt.status, res.StatusCode)
+		}
+	}
+	if res, err := http.Post(srv.URL, "text/plain", nil); err != nil {
+		t.Fatal(err)
+	} else if res.Body.Close(); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: expected 405, got %d", res.StatusCode)
+	}
+
+	mac1, _ := net.ParseMAC("00:00:00:00:00:01")
+	mac2, _ := net.ParseMAC("00:00:00:00:00:02")
+	published := []*feedEvent{
+		{name: "v4", data: []byte(`{"ip":"10.0.0.1"}`), ip: netip.MustParseAddr("10.0.0.1"), hwaddr: mac1, hostname: "printer"},
+		{name: "v4", data: []byte(`{"ip":"192.168.1.1"}`), ip: netip.MustParseAddr("192.168.1.1"), hwaddr: mac2, hostname: "laptop"},
+		{name: "v6", data: []byte(`{"ip":"2001:db8::1"}`), ip: netip.MustParseAddr("2001:db8::1"), hwaddr: mac1},
+	}
+	stream := func(e *feedEvent) string {
+		return fmt.Sprintf("event: %s\ndata: %s\n\n", e.name, e.data)
+	}
+	clients := []struct {
+		query    string
+		expected []*feedEvent
+	}{
+		{"", published},
+		{"subnet=10.0.0.7/24", published[:1]},
+		{"subnet=2001:db8::/32", published[2:]},
+		{"mac=00-00-00-00-00-01", []*feedEvent{published[0], published[2]}},
+		{"hostname=PRINTER", published[:1]},
+		{"subnet=10.0.0.0/8&mac=00:00:00:00:00:02", nil},
+	}
+	bodies := make([]io.ReadCloser, len(clients))
+	for i, client := range clients {
+		// The client is subscribed once the response starts
+		res, err := http.Get(srv.URL + "?" + client.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("%s: expected an event stream, got %d %s", client.query, res.StatusCode, res.Header.Get("Content-Type"))
+		}
+		bodies[i] = res.Body
+	}
+	for _, e := range published {
+		events.publish(e)
+	}
+	// Ending the subscriptions ends the streams
+	var subs []chan *feedEvent
+	events.mu.Lock()
+	for ch := range events.subs {
+		subs = append(subs, ch)
+	}
+	events.mu.Unlock()
+	for _, ch := range subs {
+		events.unsubscribe(ch)
+	}
+	for i, client := range clients {
+		var expected strings.Builder
+		for _, e := range client.expected {
+			expected.WriteString(stream(e))
+		}
+		body, err := io.ReadAll(bodies[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != expected.String() {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", client.query, expected.String(), body)
+		}
+	}
+}
+
+func TestFeedSlowClient(t *testing.T) {
+	f := &feed{subs: map[chan *feedEvent]struct{}{}}
+	ch := f.subscribe()
+	e := &feedEvent{name: "v4", ip: netip.MustParseAddr("10.0.0.1")}
+	for i := 0; i <= cap(ch); i++ {
+		f.publish(e)
+	}
+	// The buffered events are delivered, then the stream ends rather than
+	// silently missing the rest
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != cap(ch) || len(f.subs) != 0 {
+		t.Errorf("expected %d events and no subscribers, got %d and %d", cap(ch), n, len(f.subs))
+	}
+	f.unsubscribe(ch)
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	mtime := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	writeFile(t, path, lease("10.0.0.1"), mtime)
+	c, parses := countedSource(path)
+	if _, err := c.DHCPv4Leases(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	replaceFile(t, path, lease("10.0.0.1")+lease("10.0.0.2"), mtime.Add(time.Minute))
+
+	if runtime.GOOS == "linux" {
+		// Parsed again in the background, without a request
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadInt32(parses) != 2 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the replaced file to be parsed again")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	leases, err := c.DHCPv4Leases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 || atomic.LoadInt32(parses) != 2 {
+		t.Errorf("expected 2 leases from 2 parses, got %d from %d", len(leases), atomic.LoadInt32(parses))
+	}
+}
+
+func TestWatchFallback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dhcp")
+	path := filepath.Join(dir, "dhcpd.leases")
+	c, parses := countedSource(path)
+	// Without the directory there is nothing to watch, but changes are
+	// still noticed on request
+	err := c.Watch()
+	if runtime.GOOS == "linux" && err == nil {
+		t.Error("expected an error watching a missing directory")
+	}
+	if _, err := c.DHCPv4Leases(); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	writeFile(t, path, lease("10.0.0.1"), mtime)
+	for _, n := range []int{1, 2} {
+		leases, err := c.DHCPv4Leases()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leases) != n {
+			t.Errorf("expected %d leases, got %d", n, len(leases))
+		}
+		writeFile(t, path, lease("10.0.0.1")+lease("10.0.0.2"), mtime.Add(time.Minute))
+	}
+	// The missing file wasn't cached, and each version was parsed once
+	if p := atomic.LoadInt32(parses); p != 2 {
+		t.Errorf("expected 2 parses, got %d", p)
+	}
+}
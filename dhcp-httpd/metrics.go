@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/metrics"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/sources"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/stats"
+)
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A failure to parse one file shouldn't hide the metrics of the other,
+	// the failure is itself reported as a metric.
+	v4leases, err := cache.DHCPv4Leases()
+	if err != nil {
+		log.Println(err)
+	}
+	v6leases, err := cache.DHCPv6Leases()
+	if err != nil {
+		log.Println(err)
+	}
+
+	var b bytes.Buffer
+	m := metrics.NewWriter(&b)
+
+	m.Family("dhcpd_leases", metrics.TypeGauge, "Leased addresses and prefixes by the binding state of their latest lease.")
+	v4states := stats.DHCPv4States(v4leases)
+	for _, state := range sortedKeys(v4states) {
+		m.Sample("dhcpd_leases", float64(v4states[state]),
+			metrics.Label{Name: "family", Value: "v4"},
+			metrics.Label{Name: "type", Value: "lease"},
+			metrics.Label{Name: "state", Value: state})
+	}
+	v6states := stats.DHCPv6States(v6leases)
+	for _, typ := range []dhcpd6.DHCPv6LeaseType{dhcpd6.DHCPv6LeaseTypeNonTemporary, dhcpd6.DHCPv6LeaseTypeTemporary, dhcpd6.DHCPv6LeaseTypePrefixDelegation} {
+		for _, state := range sortedKeys(v6states[typ]) {
+			m.Sample("dhcpd_leases", float64(v6states[typ][state]),
+				metrics.Label{Name: "family", Value: "v6"},
+				metrics.Label{Name: "type", Value: string(typ)},
+				metrics.Label{Name: "state", Value: state})
+		}
+	}
+
+	m.Family("dhcpd_leases_vendor_class", metrics.TypeGauge, "Active DHCPv4 leases by vendor-class-identifier.")
+	classes := stats.DHCPv4VendorClasses(v4leases)
+	for _, class := range sortedKeys(classes) {
+		m.Sample("dhcpd_leases_vendor_class", float64(classes[class]),
+			metrics.Label{Name: "vendor_class", Value: class})
+	}
+
+	writePoolMetrics(m, v4leases, v6leases)
+
+	type parses struct {
+		family   string
+		source   *sources.Source
+		duration time.Duration
+		errors   uint64
+	}
+	var parsed []parses
+	for _, f := range cache.V4Files {
+		duration, errors := f.parses()
+		parsed = append(parsed, parses{"v4", f.Source, duration, errors})
+	}
+	for _, f := range cache.V6Files {
+		duration, errors := f.parses()
+		parsed = append(parsed, parses{"v6", f.Source, duration, errors})
+	}
+	m.Family("dhcpd_lease_parse_duration_seconds", metrics.TypeGauge, "Time taken by the latest parse of the lease file.")
+	for _, p := range parsed {
+		m.Sample("dhcpd_lease_parse_duration_seconds", p.duration.Seconds(),
+			metrics.Label{Name: "family", Value: p.family}, metrics.Label{Name: "source", Value: p.source.Name})
+	}
+	m.Family("dhcpd_lease_parse_errors_total", metrics.TypeCounter, "Failed parses of the lease file.")
+	for _, p := range parsed {
+		m.Sample("dhcpd_lease_parse_errors_total", float64(p.errors),
+			metrics.Label{Name: "family", Value: p.family}, metrics.Label{Name: "source", Value: p.source.Name})
+	}
+
+	type leaseFile struct {
+		family string
+		source *sources.Source
+		info   os.FileInfo
+	}
+	var files []leaseFile
+	for _, f := range cache.V4Files {
+		if info, err := os.Stat(f.Source.Path); err == nil {
+			files = append(files, leaseFile{"v4", f.Source, info})
+		}
+	}
+	for _, f := range cache.V6Files {
+		if info, err := os.Stat(f.Source.Path); err == nil {
+			files = append(files, leaseFile{"v6", f.Source, info})
+		}
+	}
+	m.Family("dhcpd_lease_file_size_bytes", metrics.TypeGauge, "Size of the lease file.")
+	for _, f := range files {
+		m.Sample("dhcpd_lease_file_size_bytes", float64(f.info.Size()),
+			metrics.Label{Name: "family", Value: f.family}, metrics.Label{Name: "source", Value: f.source.Name})
+	}
+	m.Family("dhcpd_lease_file_modified_timestamp_seconds", metrics.TypeGauge, "Modification time of the lease file.")
+	for _, f := range files {
+		m.Sample("dhcpd_lease_file_modified_timestamp_seconds", float64(f.info.ModTime().Unix()),
+			metrics.Label{Name: "family", Value: f.family}, metrics.Label{Name: "source", Value: f.source.Name})
+	}
+
+	writeFailoverMetrics(m)
+
+	if err := m.Err(); err != nil {
+		log.Println(err)
+		http.Error(w, "Failed to present metrics", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// writeFailoverMetrics reports when each server of the failover peers of
+// dhcpd's v4 lease files entered its current state
+func writeFailoverMetrics(m *metrics.Writer) {
+	peers, err := cache.FailoverPeers()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	m.Family("dhcpd_failover_state_timestamp_seconds", metrics.TypeGauge, "When each server of a failover peer entered its state, by the server, my or partner.")
+	for _, peer := range peers {
+		for _, s := range []struct {
+			server, state string
+			at            *time.Time
+		}{
+			{"my", peer.MyState, peer.MyStateTime},
+			{"partner", peer.PartnerState, peer.PartnerStateTime},
+		} {
+			if s.at == nil {
+				continue
+			}
+			m.Sample("dhcpd_failover_state_timestamp_seconds", float64(s.at.Unix()),
+				metrics.Label{Name: "source", Value: peer.Source}, metrics.Label{Name: "peer", Value: peer.Name},
+				metrics.Label{Name: "server", Value: s.server}, metrics.Label{Name: "state", Value: s.state})
+		}
+	}
+}
+
+// writePoolMetrics reports the utilization of the pools in the configuration
+// files, if there are any.
+func writePoolMetrics(m *metrics.Writer, v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease) {
+	var pools []*stats.Usage
+	if v4conf, err := readConfig(*v4ConfFileFlag); err != nil {
+		log.Println(err)
+	} else {
+		pools = append(pools, stats.DHCPv4(v4conf, v4leases)...)
+	}
+	if v6conf, err := readConfig(*v6ConfFileFlag); err != nil {
+		log.Println(err)
+	} else {
+		pools = append(pools, stats.DHCPv6(v6conf, v6leases)...)
+	}
+
+	labels := func(u *stats.Usage, extra ...metrics.Label) []metrics.Label {
+		r := fmt.Sprintf("%s-%s", u.Start, u.End)
+		if u.PrefixLen != 0 {
+			r = fmt.Sprintf("%s/%d", r, u.PrefixLen)
+		}
+		return append([]metrics.Label{
+			{Name: "site", Value: u.Site},
+			{Name: "shared_network", Value: u.SharedNetwork},
+			{Name: "subnet", Value: u.Subnet.String()},
+			{Name: "type", Value: u.Type},
+			{Name: "range", Value: r},
+		}, extra...)
+	}
+
+	m.Family("dhcpd_pool_size", metrics.TypeGauge, "Addresses, or for prefix6 prefixes, in the range.")
+	for _, u := range pools {
+		m.Sample("dhcpd_pool_size", float64(u.Total), labels(u)...)
+	}
+	m.Family("dhcpd_pool_leases", metrics.TypeGauge, "Addresses, or for prefix6 prefixes, in the range by binding state, summing to the size.")
+	for _, u := range pools {
+		for _, c := range []struct {
+			state string
+			count uint64
+		}{
+			{"active", u.Active},
+			{"free", u.Free},
+			{"backup", u.Backup},
+			{"expired", u.Expired},
+			{"released", u.Released},
+			{"abandoned", u.Abandoned},
+			{"reserved", u.Reserved},
+		} {
+			m.Sample("dhcpd_pool_leases", float64(c.count), labels(u, metrics.Label{Name: "state", Value: c.state})...)
+		}
+	}
+	m.Family("dhcpd_pool_utilization_ratio", metrics.TypeGauge, "Fraction of the range which is actively leased.")
+	for _, u := range pools {
+		m.Sample("dhcpd_pool_utilization_ratio", u.Utilization()/100, labels(u)...)
+	}
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
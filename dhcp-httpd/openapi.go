@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonschema"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonv2"
+)
+
+// The API is described by an OpenAPI 3.1 document, see:
+// https://spec.openapis.org/oas/v3.1.0
+// Its schemas are generated from the types the handlers encode, so they
+// can't drift from the responses.
+
+type openAPI struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       openAPIInfo          `json:"info"`
+	Paths      map[string]*pathItem `json:"paths"`
+	Components openAPIComponents    `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*jsonschema.Schema `json:"schemas"`
+}
+
+type pathItem struct {
+	Get *operation `json:"get"`
+}
+
+type operation struct {
+	Summary     string               `json:"summary"`
+	OperationID string               `json:"operationId"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string             `json:"name"`
+	In          string             `json:"in"` // query or path
+	Description string             `json:"description,omitempty"`
+	Required    bool               `json:"required,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *jsonschema.Schema `json:"schema,omitempty"`
+}
+
+var openAPIDocument = newOpenAPI()
+
+func newOpenAPI() *openAPI {
+	g := jsonschema.NewGenerator("#/components/schemas/")
+	schema := func(v interface{}) *jsonschema.Schema {
+		return g.Schema(reflect.TypeOf(v))
+	}
+	str := &jsonschema.Schema{Type: jsonschema.Types{"string"}}
+	integer := &jsonschema.Schema{Type: jsonschema.Types{"integer"}}
+
+	// Resources are JSON, or an HTML page for browsers
+	ok := func(description string, v interface{}) *response {
+		return &response{Description: description, Content: map[string]*mediaType{
+			"application/json": {Schema: schema(v)},
+			"text/html":        {},
+		}}
+	}
+	errorResponse := func(description string) *response {
+		return &response{Description: description, Content: map[string]*mediaType{"text/plain": {Schema: str}}}
+	}
+	notModified := &response{Description: "The leases haven't changed since the ETag or Last-Modified given"}
+	query := func(name, description string) *parameter {
+		return &parameter{Name: name, In: "query", Description: description, Schema: str}
+	}
+	get := func(id, summary string, params []*parameter, responses map[string]*response) *pathItem {
+		return &pathItem{Get: &operation{Summary: summary, OperationID: id, Parameters: params, Responses: responses}}
+	}
+	resource := func(id, summary, name, description string) *pathItem {
+		return get(id, summary, []*parameter{{Name: name, In: "path", Description: description, Required: true, Schema: str}}, map[string]*response{
+			"200": ok("The current binding of each address held and every lease in the lease files, newest first", V1Resource{}),
+			"304": notModified,
+			"400": errorResponse("Malformed " + name),
+			"404": errorResponse("No leases in the lease files"),
+		})
+	}
+
+	doc := &openAPI{
+		OpenAPI: "3.1.0",
+		Info: openAPIInfo{
+			Title:       "dhcp-httpd",
+			Description: "The leases of ISC dhcpd, parsed from its lease files",
+			Version:     "1",
+		},
+		Paths: map[string]*pathItem{
+			"/v1/leases": get("listLeases", "Leases, newest first unless sorted", []*parameter{
+				query("family", "4 or 6, both by default"),
+				query("state", "Binding states, repeated or comma separated"),
+				query("mac", "Client MAC, from the hardware address or DUID"),
+				query("hostname", "Glob matched case insensitively, v4 leases only"),
+				query("subnet", "Prefix containing the address"),
+				query("vendor", "Substring of the vendor of the MAC, case insensitive"),
+				query("source", "Names of the lease files, repeated or comma separated"),
+				query("site", "Sites of the lease files, repeated or comma separated"),
+				query("since", "Leases granted or renewed since, RFC 3339 or a duration ago like 24h"),
+				query("until", "Leases granted or renewed until, RFC 3339 or a duration ago like 24h"),
+				query("sort", "JSON field names, comma separated, descending when prefixed by -"),
+				{Name: "limit", In: "query", Description: "Leases of each family per page", Schema: integer},
+				query("cursor", "The next cursor of the previous page"),
+				query("columns", "Comma separated columns of CSV and TSV, see the tabular package"),
+			}, map[string]*response{
+				"200": {Description: "Leases, with the cursor of the next page when limited", Content: map[string]*mediaType{
+					"application/json":          {Schema: schema(V1Leases{})},
+					"text/html":                 {},
+					"text/csv":                  {Schema: str},
+					"text/tab-separated-values": {Schema: str},
+				}},
+				"304": notModified,
+				"400": errorResponse("Malformed query"),
+			}),
+			"/v2/leases": get("listLeasesV2", "Leases in the v2 schema, newest first", nil, map[string]*response{
+				"200": {Description: "Leases", Content: map[string]*mediaType{"application/json": {Schema: schema(jsonv2.Leases{})}}},
+				"304": notModified,
+			}),
+			"/v1/leases/v4/{ip}":      resource("getDHCPv4Lease", "The leases of an IPv4 address", "ip", "IPv4 address"),
+			"/v1/leases/v6/{ip}":      resource("getDHCPv6Lease", "The leases of an IPv6 address", "ip", "IPv6 address"),
+			"/v1/clients/mac/{mac}":   resource("getClientByMAC", "The leases of a client by MAC", "mac", "MAC address"),
+			"/v1/clients/duid/{duid}": resource("getClientByDUID", "The leases of a client by DUID", "duid", "DUID as colon separated hex"),
+			"/v1/prefixes/{prefix}":   resource("getPrefix", "The leases of a delegated prefix", "prefix", "IPv6 prefix, its slash unescaped, e.g. 2001:db8:1::/56"),
+			"/v1/devices": get("listDevices", "Clients correlated across their v4 and v6 leases", nil, map[string]*response{
+				"200": ok("Devices", V1Devices{}),
+			}),
+			"/v1/pools": get("listPools", "Utilization of the ranges in dhcpd.conf", nil, map[string]*response{
+				"200": ok("Pools", V1Pools{}),
+			}),
+			"/v1/failover": get("listFailoverPeers", "States of the failover peers in dhcpd's v4 lease files", nil, map[string]*response{
+				"200": ok("Failover peers, warning of those in communications-interrupted or partner-down", V1Failover{}),
+			}),
+			"/v1/reservations": get("listReservations", "Hosts, classes and subclasses declared in dhcpd's v4 lease files through OMAPI", nil, map[string]*response{
+				"200": ok("Reservations, as last declared, without those since deleted", V1Reservations{}),
+			}),
+			"/v1/events": get("streamEvents", "Changes to the leases as Server-Sent Events named v4 or v6", []*parameter{
+				query("subnet", "Prefix containing the address"),
+				query("mac", "Client MAC"),
+				query("hostname", "Hostname, v4 leases only"),
+			}, map[string]*response{
+				"200": {Description: "Events whose data is a v4Message or v6Message", Content: map[string]*mediaType{"text/event-stream": {Schema: str}}},
+				"400": errorResponse("Malformed filter"),
+			}),
+			"/metrics": get("metrics", "Prometheus metrics", nil, map[string]*response{
+				"200": {Description: "Metrics in the Prometheus text exposition format", Content: map[string]*mediaType{"text/plain": {Schema: str}}},
+			}),
+			"/v1/openapi.json": get("openAPI", "This document", nil, map[string]*response{
+				"200": {Description: "OpenAPI 3.1 document", Content: map[string]*mediaType{"application/json": {}}},
+			}),
+		},
+	}
+	// Named for the events' data, which the event stream can't describe
+	schema(v4Message{})
+	schema(v6Message{})
+	doc.Components.Schemas = g.Defs
+	return doc
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openAPIDocument)
+}
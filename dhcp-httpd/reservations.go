@@ -0,0 +1,61 @@
+package main
+
+import "github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+
+// Host is a host declaration of one of dhcpd's v4 lease files
+type Host struct {
+	dhcpd.DynamicHost
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+}
+
+// Class is a class declaration of one of dhcpd's v4 lease files
+type Class struct {
+	dhcpd.Class
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+}
+
+// Subclass is a subclass declaration of one of dhcpd's v4 lease files
+type Subclass struct {
+	dhcpd.Subclass
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+}
+
+type V1Reservations struct {
+	Hosts      []Host     `json:"hosts"`
+	Classes    []Class    `json:"classes"`
+	Subclasses []Subclass `json:"subclasses"`
+}
+
+// Reservations are the hosts, classes and subclasses created through OMAPI
+// of each of dhcpd's v4 lease files, as last declared, without those since
+// deleted
+func (c *CachedSource) Reservations() (*V1Reservations, error) {
+	declared, err := c.Declarations()
+	if err != nil {
+		return nil, err
+	}
+	var res V1Reservations
+	for _, d := range declared {
+		switch decl := d.Declaration.(type) {
+		case *dhcpd.DynamicHost:
+			res.Hosts = append(res.Hosts, Host{DynamicHost: *decl, Source: d.Source, Site: d.Site})
+		case *dhcpd.Class:
+			res.Classes = append(res.Classes, Class{Class: *decl, Source: d.Source, Site: d.Site})
+		case *dhcpd.Subclass:
+			res.Subclasses = append(res.Subclasses, Subclass{Subclass: *decl, Source: d.Source, Site: d.Site})
+		}
+	}
+	res.Hosts = latest(res.Hosts,
+		func(h Host) [2]string { return [2]string{h.Source, h.Name} },
+		func(h Host) bool { return h.Deleted })
+	res.Classes = latest(res.Classes,
+		func(c Class) [2]string { return [2]string{c.Source, c.Name} },
+		func(c Class) bool { return c.Deleted })
+	res.Subclasses = latest(res.Subclasses,
+		func(s Subclass) [3]string { return [3]string{s.Source, s.Class, s.Value} },
+		func(s Subclass) bool { return s.Deleted })
+	return &res, nil
+}
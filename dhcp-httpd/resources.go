@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	autoneg "github.com/adjust/goautoneg"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// V1Resource is one address, client or delegated prefix: the current binding
+// of each address it holds, and every lease of it in the lease files, both
+// newest first. dhcpd appends a lease each time a binding changes, so the
+// files are its history until they're rewritten.
+type V1Resource struct {
+	Current V1Leases `json:"current"`
+	History V1Leases `json:"history"`
+}
+
+// resourcePage is what the resource page is rendered from
+type resourcePage struct {
+	Title string
+	V1Resource
+}
+
+var errNotFound = errors.New("not found")
+
+// bindings selects the leases matching from those given in file order, and of
+// those the current ones: the newest of all the leases with the same key.
+func bindings[T any](leases []T, match func(*T) bool, key func(*T) (string, bool)) (current, history []T) {
+	seen := map[string]bool{}
+	for i := len(leases) - 1; i >= 0; i-- {
+		lease := &leases[i]
+		k, ok := key(lease)
+		newest := ok && !seen[k]
+		if ok {
+			seen[k] = true
+		}
+		if match(lease) {
+			history = append(history, *lease)
+			if newest {
+				current = append(current, *lease)
+			}
+		}
+	}
+	return current, history
+}
+
+// The bindings of v4 leases are their addresses, and of v6 leases their
+// identity associations
+func v4Binding(lease *dhcpd.DHCPv4Lease) (string, bool) {
+	return lease.IP.String(), true
+}
+
+func v6Binding(lease *dhcpd6.DHCPv6Lease) (string, bool) {
+	var d []byte
+	if lease.DUID != nil {
+		d = lease.DUID.Bytes()
+	}
+	return string(lease.Type) + "/" + hex.EncodeToString(lease.IAID) + "/" + hex.EncodeToString(d), true
+}
+
+// findResource selects the v4 and v6 leases of a resource, either may be nil
+func findResource(v4match func(*dhcpd.DHCPv4Lease) bool, v6match func(*dhcpd6.DHCPv6Lease) bool, v6key func(*dhcpd6.DHCPv6Lease) (string, bool)) (*V1Resource, error) {
+	var res V1Resource
+	if v4match != nil {
+		leases, err := cache.DHCPv4Leases()
+		if err != nil {
+			return nil, err
+		}
+		res.Current.DHCPv4Leases, res.History.DHCPv4Leases = bindings(leases, v4match, v4Binding)
+	}
+	if v6match != nil {
+		leases, err := cache.DHCPv6Leases()
+		if err != nil {
+			return nil, err
+		}
+		res.Current.DHCPv6Leases, res.History.DHCPv6Leases = bindings(leases, v6match, v6key)
+	}
+	if len(res.History.DHCPv4Leases) == 0 && len(res.History.DHCPv6Leases) == 0 {
+		return nil, errNotFound
+	}
+	return &res, nil
+}
+
+// v6AddrBinding keys the leases holding prefix by it alone, so the current
+// lease is the newest to hold it, whichever identity association that was.
+func v6AddrBinding(prefix netip.Prefix) func(*dhcpd6.DHCPv6Lease) (string, bool) {
+	return func(lease *dhcpd6.DHCPv6Lease) (string, bool) {
+		for _, addr := range lease.Addrs {
+			if addr.Prefix() == prefix {
+				return prefix.String(), true
+			}
+		}
+		return "", false
+	}
+}
+
+func serveV4Lease(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	ip, err := netip.ParseAddr(strings.TrimPrefix(r.URL.Path, "/v1/leases/v4/"))
+	if err != nil || !ip.Is4() {
+		http.Error(w, "Expected an IPv4 address", http.StatusBadRequest)
+		return
+	}
+	serveResource(w, r, ip.String(), func() (*V1Resource, error) {
+		return findResource(func(lease *dhcpd.DHCPv4Lease) bool {
+			return lease.IP == ip
+		}, nil, nil)
+	})
+}
+
+func serveV6Lease(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	ip, err := netip.ParseAddr(strings.TrimPrefix(r.URL.Path, "/v1/leases/v6/"))
+	if err != nil || !ip.Is6() {
+		http.Error(w, "Expected an IPv6 address", http.StatusBadRequest)
+		return
+	}
+	key := v6AddrBinding(netip.PrefixFrom(ip, ip.BitLen()))
+	serveResource(w, r, ip.String(), func() (*V1Resource, error) {
+		return findResource(nil, func(lease *dhcpd6.DHCPv6Lease) bool {
+			_, ok := key(lease)
+			return ok
+		}, key)
+	})
+}
+
+func servePrefix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	prefix, err := netip.ParsePrefix(strings.TrimPrefix(r.URL.Path, "/v1/prefixes/"))
+	if err != nil || !prefix.Addr().Is6() {
+		http.Error(w, "Expected an IPv6 prefix", http.StatusBadRequest)
+		return
+	}
+	prefix = prefix.Masked()
+	key := v6AddrBinding(prefix)
+	serveResource(w, r, prefix.String(), func() (*V1Resource, error) {
+		return findResource(nil, func(lease *dhcpd6.DHCPv6Lease) bool {
+			_, ok := key(lease)
+			return ok && lease.Type == dhcpd6.DHCPv6LeaseTypePrefixDelegation
+		}, key)
+	})
+}
+
+func serveClientMAC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	mac, err := net.ParseMAC(strings.TrimPrefix(r.URL.Path, "/v1/clients/mac/"))
+	if err != nil {
+		http.Error(w, "Expected a MAC address", http.StatusBadRequest)
+		return
+	}
+	serveResource(w, r, mac.String(), func() (*V1Resource, error) {
+		return findResource(func(lease *dhcpd.DHCPv4Lease) bool {
+			hwaddr, ok := inventory.DHCPv4HardwareAddr(lease)
+			return ok && bytes.Equal(hwaddr, mac)
+		}, func(lease *dhcpd6.DHCPv6Lease) bool {
+			hwaddr, ok := inventory.DHCPv6HardwareAddr(lease)
+			return ok && bytes.Equal(hwaddr, mac)
+		}, v6Binding)
+	})
+}
+
+func serveClientDUID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	d, err := duid.ParseDUIDString(strings.TrimPrefix(r.URL.Path, "/v1/clients/duid/"))
+	if err != nil {
+		http.Error(w, "Expected a DUID as colon separated hex", http.StatusBadRequest)
+		return
+	}
+	b := d.Bytes()
+	// RFC 4361 clients use their DUID for v4 too
+	serveResource(w, r, d.String(), func() (*V1Resource, error) {
+		return findResource(func(lease *dhcpd.DHCPv4Lease) bool {
+			return lease.ClientID != nil && lease.ClientID.DUID() != nil && bytes.Equal(lease.ClientID.DUID().Bytes(), b)
+		}, func(lease *dhcpd6.DHCPv6Lease) bool {
+			return lease.DUID != nil && bytes.Equal(lease.DUID.Bytes(), b)
+		}, v6Binding)
+	})
+}
+
+func serveResource(w http.ResponseWriter, r *http.Request, title string, find func() (*V1Resource, error)) {
+	ct := autoneg.Negotiate(r.Header.Get("Accept"), []string{"application/json", "text/html"})
+	res, err := find()
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "No leases of "+title, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Failed to fetch leases", http.StatusInternalServerError)
+		return
+	}
+	// Only a resource which exists is a version of the leases
+	if ct == "application/json" && notModified(w, r, cache, "v1") {
+		return
+	}
+	switch ct {
+	case "application/json":
+		json.NewEncoder(w).Encode(res)
+	case "text/html":
+		err = resourceTemplate.Execute(w, resourcePage{Title: title, V1Resource: *res})
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Failed to present leases", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/kea"
+)
+
+// LeaseSource reads leases in the order they appear in the lease files,
+// so later leases for an address supersede earlier ones.
+type LeaseSource interface {
+	DHCPv4Leases() ([]dhcpd.DHCPv4Lease, error)
+	DHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error)
+}
+
+// FileSource parses the lease files in-process
+type FileSource struct {
+	V4Path string
+	V6Path string
+}
+
+func (s *FileSource) DHCPv4Leases() ([]dhcpd.DHCPv4Lease, error) {
+	return parseFile(s.V4Path, dhcpd.Parse)
+}
+
+func (s *FileSource) DHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error) {
+	return parseFile(s.V6Path, dhcpd6.Parse)
+}
+
+// parseFile parses the lease file at path
+func parseFile[T any](path string, parse func(io.Reader) (<-chan *T, <-chan error)) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	leases, err := collect(parse(f))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return leases, nil
+}
+
+// parseKea parses the lease files of Kea's memfile at path, which only
+// together hold all its leases, see kea.Files
+func parseKea[T any](path string, parse func(io.Reader) (<-chan *T, <-chan error)) ([]T, error) {
+	var res []T
+	for _, p := range kea.Files(path) {
+		leases, err := parseFile(p, parse)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, leases...)
+	}
+	return res, nil
+}
+
+func collect[T any](leases <-chan *T, errc <-chan error) ([]T, error) {
+	var res []T
+	for lease := range leases {
+		res = append(res, *lease)
+	}
+	return res, <-errc
+}
+
+// ExecSource runs the dhcpd2json and dhcpd62json commands, which must be
+// on $PATH, to parse the lease files. This costs a process per request but
+// isolates the server from the parsers.
+type ExecSource struct {
+	V4Path string
+	V6Path string
+}
+
+func (s *ExecSource) DHCPv4Leases() ([]dhcpd.DHCPv4Lease, error) {
+	return execLeases[dhcpd.DHCPv4Lease]("dhcpd2json", s.V4Path)
+}
+
+func (s *ExecSource) DHCPv6Leases() ([]dhcpd6.DHCPv6Lease, error) {
+	return execLeases[dhcpd6.DHCPv6Lease]("dhcpd62json", s.V6Path)
+}
+
+func execLeases[T any](name, path string) ([]T, error) {
+	cmd := exec.Command(name, "-f", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s pipe: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s start: %w", name, err)
+	}
+	var leases []T
+	dec := json.NewDecoder(stdout)
+	for {
+		var lease T
+		err := dec.Decode(&lease)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Unblock the command, if it's still writing
+			io.Copy(io.Discard, stdout)
+			cmd.Wait()
+			return nil, fmt.Errorf("%s decode: %w", name, err)
+		}
+		leases = append(leases, lease)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("%s wait: %w, stderr: %s", name, err, stderr.String())
+	}
+	return leases, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func inode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+// Writes come in bursts, wait for them to settle before parsing
+const watchDelay = 100 * time.Millisecond
+
+// Watch parses the lease files again in the background as soon as they
+// change, so requests don't wait on the parse. It watches the directories
+// of the files using inotify, as dhcpd, Kea and dnsmasq replace the files
+// when rewriting them.
+func (c *CachedSource) Watch() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_MOVED_TO
+	refreshV4 := func() {
+		if _, err := c.DHCPv4Leases(); err != nil {
+			log.Println(err)
+		}
+	}
+	refreshV6 := func() {
+		if _, err := c.DHCPv6Leases(); err != nil {
+			log.Println(err)
+		}
+	}
+	refresh := map[string]func(){}
+	for _, f := range c.V4Files {
+		refresh[filepath.Clean(f.Source.Path)] = refreshV4
+	}
+	for _, f := range c.V6Files {
+		refresh[filepath.Clean(f.Source.Path)] = refreshV6
+	}
+	dirs := map[string]bool{}
+	watches := map[int32]string{}
+	for path := range refresh {
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		wd, err := syscall.InotifyAddWatch(fd, dir, mask)
+		if err != nil {
+			syscall.Close(fd)
+			return os.NewSyscallError("inotify_add_watch "+dir, err)
+		}
+		watches[int32(wd)] = dir
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+		timers := map[string]*time.Timer{}
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				log.Printf("inotify read: %v\n", err)
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(event.Len)]
+				off += syscall.SizeofInotifyEvent + int(event.Len)
+
+				path := filepath.Join(watches[event.Wd], string(bytes.TrimRight(name, "\x00")))
+				fn, ok := refresh[path]
+				if !ok {
+					continue
+				}
+				if t, ok := timers[path]; ok {
+					t.Reset(watchDelay)
+				} else {
+					timers[path] = time.AfterFunc(watchDelay, fn)
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown gives f the owner of the file of info, as dhcpd may run as its own
+// user. Only root may give files away, so it's left to whoever runs the
+// command otherwise.
+func chown(f *os.File, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
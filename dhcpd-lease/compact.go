@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/compact"
+)
+
+// runCompact rewrites a lease file without its superseded records, exiting
+// 1 if it can't. dhcpd must be stopped meanwhile, as it keeps appending to the
+// file it has open, which is no longer the lease file once replaced.
+func runCompact(args []string) int {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compact [flags] dhcpd.leases\n\n"+
+			"Stop dhcpd first: it keeps appending to the file it opened, so the leases\n"+
+			"it writes after the file is replaced would be lost.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	expiredDays := fs.Int("expired-days", 0, "Drop the leases of addresses which expired more than this many days ago, or 0 to keep them")
+	dryRun := fs.Bool("n", false, "Only summarize what would be dropped, leaving the file as it is")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Print("expected the path of a dhcpd.leases file, which is rewritten in place")
+		return 2
+	}
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	info, err := f.Stat()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	defer f.Close()
+	input, err := io.ReadAll(f)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	output, sum, err := compact.Compact(input, compact.Options{ExpiredFor: time.Duration(*expiredDays) * 24 * time.Hour})
+	if err != nil {
+		log.Printf("parse %s: %v", path, err)
+		return 1
+	}
+	fmt.Printf("%s: kept %d of %d declarations, dropping %d superseded and %d expired, %d to %d bytes\n",
+		path, sum.Kept, sum.Declarations, sum.Superseded, sum.Expired, sum.Before, sum.After)
+	if *dryRun || sum.Kept == sum.Declarations {
+		return 0
+	}
+	if err := replace(path, info, output); err != nil {
+		log.Print(err)
+		return 1
+	}
+	// The replaced file is still open, so anything appended to it since was
+	// written by a dhcpd which is still running
+	if cur, err := f.Stat(); err == nil && cur.Size() != info.Size() {
+		late := make([]byte, cur.Size()-info.Size())
+		n, _ := f.ReadAt(late, info.Size())
+		log.Printf("dhcpd appended %d bytes to %s after it was replaced, which it won't read back; stop dhcpd and append them:\n%s",
+			len(late), path, late[:n])
+		return 1
+	}
+	return 0
+}
+
+// replace atomically writes the file at path, as it was when info was taken,
+// through a temporary file renamed over it, keeping its mode and owner. It
+// fails rather than lose what dhcpd has appended to the file since, though
+// not what a running dhcpd appends after the rename.
+func replace(path string, info os.FileInfo, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = func() error {
+		defer f.Close()
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+		if err := f.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := chown(f, info); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		return f.Close()
+	}()
+	if err == nil {
+		var cur os.FileInfo
+		cur, err = os.Stat(path)
+		if err == nil && (cur.Size() != info.Size() || !cur.ModTime().Equal(info.ModTime())) {
+			err = fmt.Errorf("%s changed while compacting it, is dhcpd running?", path)
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
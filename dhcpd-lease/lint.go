@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/lint"
+)
+
+type lintOutput struct {
+	File     string         `json:"file"`
+	Findings []lint.Finding `json:"findings"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// runLint checks a lease file, exiting 1 if there are findings, or 2 if it
+// can't be read
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	confFile := fs.String("c", "", "Path to dhcpd.conf, whose ranges leases must fall within")
+	maxLeases := fs.Int("max-leases", 4, "The most active leases a hardware address may hold, or 0 not to check")
+	jsonFlag := fs.Bool("json", false, "Write the findings as JSON")
+	fs.Parse(args)
+
+	opts := lint.Options{MaxLeases: *maxLeases}
+	if *confFile != "" {
+		f, err := os.Open(*confFile)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		opts.Conf, err = dhcpdconf.Parse(f)
+		f.Close()
+		if err != nil {
+			log.Printf("parse %s: %v", *confFile, err)
+			return 2
+		}
+	}
+
+	name, input := "-", os.Stdin
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+		f, err := os.Open(name)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		defer f.Close()
+		input = f
+	}
+
+	findings, err := lint.Lint(input, opts)
+	if *jsonFlag {
+		out := lintOutput{File: name, Findings: findings}
+		if out.Findings == nil {
+			out.Findings = []lint.Finding{}
+		}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+	} else {
+		for _, f := range findings {
+			fmt.Printf("%s:%s\n", name, &f)
+		}
+		if err != nil {
+			log.Printf("parse %s: %v", name, err)
+		}
+	}
+	switch {
+	case err != nil:
+		return 2
+	case len(findings) > 0:
+		return 1
+	}
+	return 0
+}
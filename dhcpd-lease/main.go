@@ -0,0 +1,45 @@
+// dhcpd-lease checks and maintains dhcpd.leases files, e.g.
+//
+//	dhcpd-lease lint -c /etc/dhcp/dhcpd.conf /var/lib/dhcp/dhcpd.leases
+//	dhcpd-lease compact -expired-days 30 /var/lib/dhcp/dhcpd.leases
+//
+// dhcpd must be stopped while compacting, as it keeps appending to the file it
+// opened rather than the one which replaces it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Commands take their arguments and return the exit code
+var commands = map[string]func(args []string) int{
+	"lint":    runLint,
+	"compact": runCompact,
+}
+
+func main() {
+	flag.Usage = func() {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <command> [flags] [dhcpd.leases]\n\nCommands: %v\n", os.Args[0], names)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(flag.CommandLine.Output(), "unknown command %s\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd(flag.Args()[1:]))
+}
@@ -2,33 +2,44 @@
 package dhcpd
 
 import (
+	"fmt"
 	"io"
-	"log"
-	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cptaffe/isc-dhcpd-lease-parser/clientid"
 	"github.com/cptaffe/isc-dhcpd-lease-parser/lex"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/octalstr"
 )
 
 type DHCPv4Lease struct {
-	IP                 net.IP     `json:"ip"`
-	Starts             *time.Time `json:"starts,omitempty"`
-	Ends               *time.Time `json:"ends,omitempty"`
-	TSTP               *time.Time `json:"tstp,omitempty"`
-	TSFP               *time.Time `json:"tsfp,omitempty"`
-	ATSFP              *time.Time `json:"atsfp,omitempty"`
-	CLTT               *time.Time `json:"cltt,omitempty"` // Client's Last Transaction Time
-	BindingState       string     `json:"binding-state,omitempty"`
-	NextBindingState   string     `json:"next-binding-state,omitempty"`
-	RewindBindingState string     `json:"rewind-binding-state,omitempty"`
-	HardwareEthernet   string     `json:"hardware-ethernet,omitempty"`
-	ClientHostname     string     `json:"client-hostname,omitempty"`
-	UID                []byte     `json:"uid,omitempty"`
+	IP                 netip.Addr         `json:"ip"`
+	Starts             *time.Time         `json:"starts,omitempty"`
+	Ends               *time.Time         `json:"ends,omitempty"` // nil if never
+	TSTP               *time.Time         `json:"tstp,omitempty"`
+	TSFP               *time.Time         `json:"tsfp,omitempty"`
+	ATSFP              *time.Time         `json:"atsfp,omitempty"`
+	CLTT               *time.Time         `json:"cltt,omitempty"` // Client's Last Transaction Time
+	BindingState       string             `json:"binding-state,omitempty"`
+	NextBindingState   string             `json:"next-binding-state,omitempty"`
+	RewindBindingState string             `json:"rewind-binding-state,omitempty"`
+	HardwareEthernet   string             `json:"hardware-ethernet,omitempty"`
+	ClientHostname     string             `json:"client-hostname,omitempty"`
+	UID                []byte             `json:"uid,omitempty"`
+	ClientID           *clientid.ClientID `json:"client-id,omitempty"` // decoded from UID
 	// set
 	VendorClassIdentifier string `json:"vendor-class-identifier,omitempty"`
 	DDNSFwdName           string `json:"ddns-fwd-name,omitempty"`
 	DDNSTxt               string `json:"ddns-txt,omitempty"`
 	DDNSRevName           string `json:"ddns-rev-name,omitempty"`
+	// Names the lease file of the lease when several are read together, and
+	// the site of the server which wrote it
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+	// Where the lease begins in its file
+	Pos lex.Pos `json:"-"`
 }
 
 // Allows us to pile up modifications to lease lazily and then
@@ -79,6 +90,10 @@ type DHCPv4LeaseOptionUID []byte
 
 func (uid DHCPv4LeaseOptionUID) Apply(lease *DHCPv4Lease) {
 	lease.UID = []byte(uid)
+	// Malformed client identifiers are still kept as the raw uid
+	if cid, err := clientid.Parse(uid); err == nil {
+		lease.ClientID = cid
+	}
 }
 
 type DHCPv4LeaseOptionBindingState string
@@ -135,10 +150,216 @@ func (drn DHCPv4LeaseOptionDDNSRevName) Apply(lease *DHCPv4Lease) {
 	lease.DDNSRevName = string(drn)
 }
 
+// Declaration is a top-level statement of a lease file: a *DHCPv4Lease,
+// *FailoverPeerState, *DynamicHost, *Class, *Subclass or AuthoringByteOrder,
+// or an *Unknown when parsed leniently
+type Declaration interface {
+	declaration()
+}
+
+func (*DHCPv4Lease) declaration() {}
+
+// AuthoringByteOrder is the byte order of the server which wrote the file,
+// little-endian or big-endian
+type AuthoringByteOrder string
+
+func (AuthoringByteOrder) declaration() {}
+
+// Unknown is a directive the parser doesn't know, e.g. one written by a
+// newer dhcpd, which ParseLenient skips rather than failing. Unknown details
+// of a lease are declared before the lease.
+type Unknown struct {
+	Pos       lex.Pos `json:"pos"`
+	Kind      string  `json:"kind"` // e.g. lease detail or top-level directive
+	Directive string  `json:"directive"`
+}
+
+func (*Unknown) declaration() {}
+
+func (u *Unknown) String() string {
+	return fmt.Sprintf("unknown %s: %s", u.Kind, u.Directive)
+}
+
+// FailoverPeerState is the state of a failover peer as dhcpd last recorded
+// it, that of this server and of its partner and when each was entered.
+// States are those of dhcpd.conf(5), e.g. normal, communications-interrupted
+// or partner-down.
+type FailoverPeerState struct {
+	Name             string     `json:"name"`
+	MyState          string     `json:"my-state"`
+	MyStateTime      *time.Time `json:"my-state-time,omitempty"`
+	PartnerState     string     `json:"partner-state"`
+	PartnerStateTime *time.Time `json:"partner-state-time,omitempty"`
+	// Maximum Client Lead Time in seconds, recorded by the primary
+	MCLT int     `json:"mclt,omitempty"`
+	Pos  lex.Pos `json:"-"`
+}
+
+func (*FailoverPeerState) declaration() {}
+
+type FailoverPeerStateOption interface {
+	Apply(state *FailoverPeerState)
+}
+
+// FailoverPeerStateOptionState is the state of this server, or its partner
+type FailoverPeerStateOptionState struct {
+	Partner bool
+	State   string
+	At      *time.Time // nil if never
+}
+
+func (s *FailoverPeerStateOptionState) Apply(state *FailoverPeerState) {
+	if s.Partner {
+		state.PartnerState, state.PartnerStateTime = s.State, s.At
+	} else {
+		state.MyState, state.MyStateTime = s.State, s.At
+	}
+}
+
+type FailoverPeerStateOptionMCLT int
+
+func (mclt FailoverPeerStateOptionMCLT) Apply(state *FailoverPeerState) {
+	state.MCLT = int(mclt)
+}
+
+// DynamicHost is a host declaration created through OMAPI, which dhcpd
+// records in the lease file rather than dhcpd.conf. A later declaration of
+// the same name replaces it, or deletes it.
+type DynamicHost struct {
+	Name             string   `json:"name"`
+	Deleted          bool     `json:"deleted,omitempty"`
+	HardwareEthernet string   `json:"hardware-ethernet,omitempty"`
+	UID              []byte   `json:"uid,omitempty"`
+	FixedAddresses   []string `json:"fixed-addresses,omitempty"` // addresses or hostnames
+	// The rest, e.g. supersede host-name = "wopr", as written
+	Statements []string `json:"statements,omitempty"`
+	Pos        lex.Pos  `json:"-"`
+}
+
+func (*DynamicHost) declaration() {}
+
+// Class is a class declaration created through OMAPI. A later declaration of
+// the same name replaces it, or deletes it.
+type Class struct {
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted,omitempty"`
+	// e.g. match if ..., spawn with ... or lease limit 4, as written
+	Statements []string `json:"statements,omitempty"`
+	Pos        lex.Pos  `json:"-"`
+}
+
+func (*Class) declaration() {}
+
+// Subclass is a member of a class, spawned by it or created through OMAPI,
+// whose value is matched by the class
+type Subclass struct {
+	Class string `json:"class"`
+	// Colon separated hex or, when quoted in the file, a string
+	Value      string   `json:"value"`
+	Deleted    bool     `json:"deleted,omitempty"`
+	Statements []string `json:"statements,omitempty"`
+	Pos        lex.Pos  `json:"-"`
+}
+
+func (*Subclass) declaration() {}
+
+// unquote is the contents of a quoted word, or the word
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseHex reads colon separated hex bytes, as dhcpd writes them without
+// leading zeros, e.g. 1:8:0:2b:4c:39:ad
+func parseHex(s string) ([]byte, error) {
+	var b []byte
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("hex %s: %w", s, err)
+		}
+		b = append(b, byte(n))
+	}
+	return b, nil
+}
+
+// common reads the statements every declaration may have, returning the
+// others as written
+func common(statements [][]string, deleted *bool) []string {
+	var res []string
+	for _, words := range statements {
+		switch strings.Join(words, " ") {
+		case "dynamic":
+			// All declarations in the lease file are
+		case "deleted":
+			*deleted = true
+		default:
+			res = append(res, strings.Join(words, " "))
+		}
+	}
+	return res
+}
+
+func newDynamicHost(pos lex.Pos, name string, statements [][]string) (*DynamicHost, error) {
+	host := &DynamicHost{Name: unquote(name), Pos: pos}
+	var rest [][]string
+	for _, words := range statements {
+		switch {
+		case len(words) == 3 && words[0] == "hardware" && words[1] == "ethernet":
+			host.HardwareEthernet = words[2]
+		case len(words) == 2 && words[0] == "uid":
+			var err error
+			if strings.HasPrefix(words[1], `"`) {
+				host.UID, err = octalstr.Parse(words[1])
+			} else {
+				host.UID, err = parseHex(words[1])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("host %s uid: %w", host.Name, err)
+			}
+		case len(words) >= 2 && words[0] == "fixed-address":
+			// fixed-address 10.0.0.5, wopr.example.com;
+			for _, addr := range strings.Split(strings.Join(words[1:], ""), ",") {
+				if addr != "" {
+					host.FixedAddresses = append(host.FixedAddresses, addr)
+				}
+			}
+		default:
+			rest = append(rest, words)
+		}
+	}
+	host.Statements = common(rest, &host.Deleted)
+	return host, nil
+}
+
+func newClass(pos lex.Pos, name string, statements [][]string) *Class {
+	class := &Class{Name: unquote(name), Pos: pos}
+	class.Statements = common(statements, &class.Deleted)
+	return class
+}
+
+func newSubclass(pos lex.Pos, class, value string, statements [][]string) *Subclass {
+	sub := &Subclass{Class: unquote(class), Value: unquote(value), Pos: pos}
+	sub.Statements = common(statements, &sub.Deleted)
+	return sub
+}
+
 type LeaseLex struct {
 	DHCPv4Leases chan *DHCPv4Lease
+	// Receives every declaration instead, if set
+	Declarations chan Declaration
+	// Declares unknown directives rather than failing, see ParseLenient
+	Lenient      bool
 	CurrentToken lex.Token
 	Tokens       chan lex.Token
+	err          error // the first error, which stops the parse
+
+	// Where the statement last read begins, and those enclosing it
+	start       lex.Pos
+	starts      []lex.Pos
+	inStatement bool
 }
 
 func (l *LeaseLex) Lex(lval *LeaseSymType) int {
@@ -149,6 +370,23 @@ func (l *LeaseLex) Lex(lval *LeaseSymType) int {
 	l.CurrentToken = token
 	lval.s = token.Val
 	switch token.Typ {
+	case lex.ItemBeginBlock:
+		l.starts = append(l.starts, l.start)
+		l.inStatement = false
+	case lex.ItemEndBlock:
+		// The block ends the statement which opened it
+		if n := len(l.starts); n > 0 {
+			l.start, l.starts = l.starts[n-1], l.starts[:n-1]
+		}
+		l.inStatement = false
+	case lex.ItemSemicolon:
+		l.inStatement = false
+	default:
+		if !l.inStatement {
+			l.start, l.inStatement = token.Pos, true
+		}
+	}
+	switch token.Typ {
 	case lex.ItemBeginBlock:
 		return BEGINBLOCK
 	case lex.ItemEndBlock:
@@ -165,22 +403,88 @@ func (l *LeaseLex) Lex(lval *LeaseSymType) int {
 		return SET
 	case lex.ItemLease:
 		return LEASE
+	case lex.ItemError:
+		l.Error(token.Val)
 	default:
-		log.Fatalf("unknown token: %+v\n", token)
+		l.Errorf("unknown token: %+v", token)
 	}
+	// Ending the input early stops the parse
 	return 0
 }
 
+func (l *LeaseLex) declare(d Declaration) {
+	if l.Declarations != nil {
+		l.Declarations <- d
+	} else if lease, ok := d.(*DHCPv4Lease); ok {
+		l.DHCPv4Leases <- lease
+	}
+}
+
+// unknown reports a directive the parser doesn't know, declaring it when
+// lenient so it's skipped, or else failing the parse
+func (l *LeaseLex) unknown(kind, format string, args ...interface{}) bool {
+	if !l.Lenient || l.Declarations == nil {
+		l.Errorf("unknown %s: "+format, append([]interface{}{kind}, args...)...)
+		return false
+	}
+	l.declare(&Unknown{Pos: l.start, Kind: kind, Directive: fmt.Sprintf(format, args...)})
+	return true
+}
+
 func (l *LeaseLex) Error(e string) {
-	log.Fatalf("at %+v: %v\n", l.CurrentToken, e)
+	if l.err == nil {
+		l.err = fmt.Errorf("at %s: %s", l.CurrentToken.Pos, e)
+	}
+}
+
+func (l *LeaseLex) Errorf(format string, args ...interface{}) {
+	l.Error(fmt.Sprintf(format, args...))
 }
 
-func Parse(input io.Reader) chan *DHCPv4Lease {
+// Parse streams the leases of input in the order they appear. Once the
+// leases channel is closed, the error channel receives the result of the
+// parse. Leases parsed before an error are still sent. The other
+// declarations are skipped, see ParseFile.
+func Parse(input io.Reader) (<-chan *DHCPv4Lease, <-chan error) {
 	tokens := lex.Lex(input)
 	leases := make(chan *DHCPv4Lease)
+	errc := make(chan error, 1)
 	go func() {
-		LeaseParse(&LeaseLex{Tokens: tokens, DHCPv4Leases: leases})
+		l := &LeaseLex{Tokens: tokens, DHCPv4Leases: leases}
+		LeaseParse(l)
+		// Let the lexer run to completion so it doesn't block forever
+		for range tokens {
+		}
 		close(leases)
+		errc <- l.err
+	}()
+	return leases, errc
+}
+
+// ParseFile streams the declarations of input, its leases along with the
+// rest, in the order they appear, as Parse does.
+func ParseFile(input io.Reader) (<-chan Declaration, <-chan error) {
+	return parseFile(input, false)
+}
+
+// ParseLenient streams the declarations of input as ParseFile does, but
+// declares the directives it doesn't know as an *Unknown and skips them,
+// rather than failing. Syntax errors still stop the parse.
+func ParseLenient(input io.Reader) (<-chan Declaration, <-chan error) {
+	return parseFile(input, true)
+}
+
+func parseFile(input io.Reader, lenient bool) (<-chan Declaration, <-chan error) {
+	tokens := lex.Lex(input)
+	declarations := make(chan Declaration)
+	errc := make(chan error, 1)
+	go func() {
+		l := &LeaseLex{Tokens: tokens, Declarations: declarations, Lenient: lenient}
+		LeaseParse(l)
+		for range tokens {
+		}
+		close(declarations)
+		errc <- l.err
 	}()
-	return leases
+	return declarations, errc
 }
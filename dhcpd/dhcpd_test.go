@@ -0,0 +1,271 @@
+package dhcpd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leasesFile generates a dhcpd.leases file of n distinct leases
+func leasesFile(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("authoring-byte-order little-endian;\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `lease 10.%d.%d.%d {
+  starts 6 2021/12/25 22:27:49;
+  ends 6 2021/12/25 22:34:37;
+  cltt 6 2021/12/25 22:24:37;
+  binding state active;
+  next binding state free;
+  rewind binding state free;
+  hardware ethernet 8c:dc:d4:%02x:%02x:%02x;
+  uid "\001\214\334\324+\354l";
+  set vendor-class-identifier = "MSFT 5.0";
+  client-hostname "host%d";
+}
+`, i>>16&0xff, i>>8&0xff, i&0xff, i>>16&0xff, i>>8&0xff, i&0xff, i)
+	}
+	return b.Bytes()
+}
+
+func TestParseError(t *testing.T) {
+	input := string(leasesFile(1)) + `lease 10.0.0.1 {
+  binding state active;
+  frobnicate the widget;
+}
+`
+	leases, errc := Parse(strings.NewReader(input))
+	var n int
+	for range leases {
+		n++
+	}
+	err := <-errc
+	if err == nil || !strings.Contains(err.Error(), "unknown lease detail: frobnicate the widget;") {
+		t.Errorf("expected unknown lease detail error, got %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected the lease before the error, got %d leases", n)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	input := `authoring-byte-order little-endian;
+db-time-format local;
+
+lease 10.0.0.1 {
+  binding state active;
+  frobnicate the widget;
+}
+host wopr {
+  dynamic;
+}
+`
+	declarations, errc := ParseLenient(strings.NewReader(input))
+	var res []Declaration
+	for d := range declarations {
+		res = append(res, d)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 5 {
+		t.Fatalf("expected 5 declarations, got %d", len(res))
+	}
+	if u, ok := res[1].(*Unknown); !ok || u.Pos.String() != "2:1" || u.String() != "unknown top-level directive: db-time-format local;" {
+		t.Errorf("unexpected unknown top-level directive %#v", res[1])
+	}
+	if u, ok := res[2].(*Unknown); !ok || u.Pos.String() != "6:3" || u.Kind != "lease detail" {
+		t.Errorf("unexpected unknown lease detail %#v", res[2])
+	}
+	if lease, ok := res[3].(*DHCPv4Lease); !ok || lease.Pos.String() != "4:1" || lease.BindingState != "active" {
+		t.Errorf("unexpected lease %#v", res[3])
+	}
+	if host, ok := res[4].(*DynamicHost); !ok || host.Pos.String() != "8:1" {
+		t.Errorf("unexpected host %#v", res[4])
+	}
+
+	// ParseFile doesn't skip them
+	declarations, errc = ParseFile(strings.NewReader(input))
+	for range declarations {
+	}
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "at 2:") {
+		t.Errorf("expected an unknown top-level directive error, got %v", err)
+	}
+}
+
+func TestParseEndsNever(t *testing.T) {
+	input := `lease 10.0.0.1 {
+  starts 1 2021/12/27 09:00:00;
+  ends never;
+  tstp never;
+  cltt 1 2021/12/27 09:00:00;
+  binding state active;
+}
+`
+	leases, errc := Parse(strings.NewReader(input))
+	var res []*DHCPv4Lease
+	for lease := range leases {
+		res = append(res, lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Ends != nil || res[0].TSTP != nil || res[0].Starts == nil || res[0].CLTT == nil {
+		t.Errorf("expected a lease without an end, got %+v", res)
+	}
+}
+
+const failoverFile = `authoring-byte-order little-endian;
+
+lease 10.0.0.1 {
+  starts 1 2021/12/27 09:00:00;
+  ends 1 2021/12/27 10:00:00;
+  tstp 1 2021/12/27 10:30:00;
+  tsfp 1 2021/12/27 10:30:00;
+  atsfp 1 2021/12/27 10:30:00;
+  cltt 1 2021/12/27 09:00:00;
+  binding state active;
+  next binding state expired;
+  rewind binding state free;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+
+failover peer "dhcp-failover" state {
+  my state communications-interrupted at 1 2021/12/27 09:11:12;
+  partner state normal at never;
+  mclt 3600;
+}
+lease 10.0.0.2 {
+  binding state free;
+}
+`
+
+func TestParseFile(t *testing.T) {
+	declarations, errc := ParseFile(strings.NewReader(failoverFile))
+	var res []Declaration
+	for d := range declarations {
+		res = append(res, d)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("expected 4 declarations, got %d", len(res))
+	}
+	if order, ok := res[0].(AuthoringByteOrder); !ok || order != "little-endian" {
+		t.Errorf("expected the authoring byte order, got %#v", res[0])
+	}
+	lease, ok := res[1].(*DHCPv4Lease)
+	if !ok || lease.ATSFP == nil || lease.RewindBindingState != "free" {
+		t.Errorf("expected a lease with failover fields, got %#v", res[1])
+	}
+	state, ok := res[2].(*FailoverPeerState)
+	if !ok {
+		t.Fatalf("expected a failover peer state, got %#v", res[2])
+	}
+	at := time.Date(2021, time.December, 27, 9, 11, 12, 0, time.UTC)
+	if state.Name != "dhcp-failover" || state.MyState != "communications-interrupted" || !state.MyStateTime.Equal(at) ||
+		state.PartnerState != "normal" || state.PartnerStateTime != nil || state.MCLT != 3600 {
+		t.Errorf("unexpected failover peer state %+v", state)
+	}
+
+	// Parse only has leases
+	leases, errc := Parse(strings.NewReader(failoverFile))
+	var n int
+	for range leases {
+		n++
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 leases, got %d", n)
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	input := string(leasesFile(1)) + `
+host wopr {
+  dynamic;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+  uid 1:8c:dc:d4:2b:ec:6c;
+  fixed-address 10.0.0.5, wopr.example.com;
+  supersede host-name = "wopr";
+}
+host "joshua" {
+  dynamic;
+  deleted;
+}
+class "printers" {
+  dynamic;
+  match if substring(option vendor-class-identifier, 0, 3) = "HP ";
+  lease limit 4;
+}
+subclass "allocation-class-1" 1:8:0:2b:4c:39:ad;
+subclass "allocation-class-1" "circuit 7" {
+  set circuit = "7";
+}
+`
+	declarations, errc := ParseFile(strings.NewReader(input))
+	var res []Declaration
+	for d := range declarations {
+		res = append(res, d)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 7 {
+		t.Fatalf("expected 7 declarations, got %d", len(res))
+	}
+	host, ok := res[2].(*DynamicHost)
+	if !ok || host.Name != "wopr" || host.Deleted || host.HardwareEthernet != "8c:dc:d4:2b:ec:6c" ||
+		string(host.UID) != "\x01\x8c\xdc\xd4\x2b\xec\x6c" || strings.Join(host.FixedAddresses, " ") != "10.0.0.5 wopr.example.com" ||
+		strings.Join(host.Statements, ";") != `supersede host-name = "wopr"` {
+		t.Errorf("unexpected host %#v", res[2])
+	}
+	if host, ok := res[3].(*DynamicHost); !ok || host.Name != "joshua" || !host.Deleted {
+		t.Errorf("expected a deleted host, got %#v", res[3])
+	}
+	class, ok := res[4].(*Class)
+	if !ok || class.Name != "printers" || len(class.Statements) != 2 || class.Statements[1] != "lease limit 4" {
+		t.Errorf("unexpected class %#v", res[4])
+	}
+	if sub, ok := res[5].(*Subclass); !ok || sub.Class != "allocation-class-1" || sub.Value != "1:8:0:2b:4c:39:ad" {
+		t.Errorf("unexpected subclass %#v", res[5])
+	}
+	if sub, ok := res[6].(*Subclass); !ok || sub.Value != "circuit 7" || len(sub.Statements) != 1 {
+		t.Errorf("unexpected subclass %#v", res[6])
+	}
+
+	// Parse skips them
+	leases, errc := Parse(strings.NewReader(input))
+	var n int
+	for range leases {
+		n++
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 lease, got %d", n)
+	}
+}
+
+// BenchmarkParse parses a file of 100k leases. Moving lease addresses from
+// net.IP to netip.Addr saved an allocation of 16 bytes per lease, 7.50M to
+// 7.40M allocs/op and 171.2MB to 169.6MB/op, the rest being the lexer's.
+func BenchmarkParse(b *testing.B) {
+	input := leasesFile(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		leases, errc := Parse(bytes.NewReader(input))
+		for range leases {
+		}
+		if err := <-errc; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
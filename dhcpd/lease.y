@@ -3,8 +3,8 @@
 package dhcpd
 
 import (
-	"log"
-	"net"
+	"net/netip"
+	"strconv"
 	"time"
 
 	"github.com/cptaffe/isc-dhcpd-lease-parser/octalstr"
@@ -19,6 +19,12 @@ import (
 	lease_detail DHCPv4LeaseOption
 	lease_details []DHCPv4LeaseOption
 	lease *DHCPv4Lease
+	failover_detail FailoverPeerStateOption
+	failover_details []FailoverPeerStateOption
+	failover *FailoverPeerState
+	words []string
+	statements [][]string
+	declaration Declaration
 }
 
 // any non-terminal which returns a value needs a type, which is
@@ -26,6 +32,13 @@ import (
 %type <lease> lease
 %type <lease_details> lease_details
 %type <lease_detail> lease_detail
+%type <failover> failover
+%type <failover_details> failover_details
+%type <failover_detail> failover_detail
+%type <declaration> declaration
+%type <s> name
+%type <words> words statement
+%type <statements> statements
 
 // same for terminals
 %token <s> BEGINBLOCK ENDBLOCK WORD STRING SEMICOLON ASSIGN SET LEASE
@@ -34,33 +47,206 @@ import (
 
 %%
 leases:
-	lease
+	/* empty */
+	| leases lease
 	{
-		Leaselex.(*LeaseLex).DHCPv4Leases <- $1
+		Leaselex.(*LeaseLex).declare($2)
 	}
-	| WORD WORD SEMICOLON
+	| leases WORD WORD SEMICOLON
 	{
 		switch {
 		
 		// authoring-byte-order little-endian;
-		case $1 == "authoring-byte-order":
-			// do nothing
+		case $2 == "authoring-byte-order":
+			Leaselex.(*LeaseLex).declare(AuthoringByteOrder($3))
 		
 		default:
-			log.Fatalf("unknown top-level directive: %s %s;\n", $1, $2)
+			if !Leaselex.(*LeaseLex).unknown("top-level directive", "%s %s;", $2, $3) {
+				return 1
+			}
 		}
 	}
-	| leases lease
+	| leases failover
+	{
+		// Unknown declarations are nil, when lenient
+		if $2 != nil {
+			Leaselex.(*LeaseLex).declare($2)
+		}
+	}
+	| leases declaration
 	{
-		Leaselex.(*LeaseLex).DHCPv4Leases <- $2
+		if $2 != nil {
+			Leaselex.(*LeaseLex).declare($2)
+		}
+	};
+
+// host, class and subclass declarations, written by dhcpd for those created
+// through OMAPI or spawned by a class
+declaration:
+	// host wopr { dynamic; hardware ethernet 8c:dc:d4:2b:ec:6c; }
+	// class "printers" { dynamic; match if ...; }
+	WORD name BEGINBLOCK statements ENDBLOCK
+	{
+		switch $1 {
+		case "host":
+			host, err := newDynamicHost(Leaselex.(*LeaseLex).start, $2, $4)
+			if err != nil {
+				Leaselex.(*LeaseLex).Error(err.Error())
+				return 1
+			}
+			$$ = host
+		case "class":
+			$$ = newClass(Leaselex.(*LeaseLex).start, $2, $4)
+		default:
+			if !Leaselex.(*LeaseLex).unknown("top-level declaration", "%s %s", $1, $2) {
+				return 1
+			}
+			$$ = nil
+		}
+	}
+	// subclass "allocation-class-1" 1:8:0:2b:4c:39:ad;
+	| WORD STRING name SEMICOLON
+	{
+		if $1 != "subclass" {
+			if !Leaselex.(*LeaseLex).unknown("top-level declaration", "%s %s %s;", $1, $2, $3) {
+				return 1
+			}
+			$$ = nil
+		} else {
+			$$ = newSubclass(Leaselex.(*LeaseLex).start, $2, $3, nil)
+		}
+	}
+	// subclass "allocation-class-1" "circuit-7" { lease limit 4; }
+	| WORD STRING name BEGINBLOCK statements ENDBLOCK
+	{
+		if $1 != "subclass" {
+			if !Leaselex.(*LeaseLex).unknown("top-level declaration", "%s %s %s", $1, $2, $3) {
+				return 1
+			}
+			$$ = nil
+		} else {
+			$$ = newSubclass(Leaselex.(*LeaseLex).start, $2, $3, $5)
+		}
+	};
+
+name: WORD | STRING;
+
+// Statements are kept as their words, as dhcpd.conf(5) allows many
+statements:
+	/* empty */ { $$ = nil }
+	| statements statement { $$ = append($1, $2) };
+
+statement: words SEMICOLON { $$ = $1 };
+
+words:
+	WORD { $$ = []string{$1} }
+	| STRING { $$ = []string{$1} }
+	| SET { $$ = []string{$1} }
+	| LEASE { $$ = []string{$1} }
+	| words WORD { $$ = append($1, $2) }
+	| words STRING { $$ = append($1, $2) }
+	| words ASSIGN { $$ = append($1, $2) }
+	| words SET { $$ = append($1, $2) }
+	| words LEASE { $$ = append($1, $2) };
+
+// failover peer "dhcp-failover" state {
+failover:
+	WORD WORD STRING WORD BEGINBLOCK failover_details ENDBLOCK
+	{
+		if $1 != "failover" || $2 != "peer" || $4 != "state" {
+			if !Leaselex.(*LeaseLex).unknown("top-level declaration", "%s %s %s %s", $1, $2, $3, $4) {
+				return 1
+			}
+			$$ = nil
+		} else {
+			$$ = &FailoverPeerState{Name: $3[1:len($3)-1], Pos: Leaselex.(*LeaseLex).start}
+			for _, opt := range $6 {
+				// Unknown details are nil, when lenient
+				if opt != nil {
+					opt.Apply($$)
+				}
+			}
+		}
+	};
+
+failover_details: failover_detail { $$ = []FailoverPeerStateOption{$1} }
+	| failover_details failover_detail { $$ = append($1, $2) };
+
+failover_detail:
+	WORD WORD SEMICOLON
+	{
+		switch {
+
+		// mclt 3600;
+		case $1 == "mclt":
+			mclt, err := strconv.Atoi($2)
+			if err != nil {
+				Leaselex.(*LeaseLex).Errorf("failover peer mclt: %v", err)
+				return 1
+			}
+			$$ = FailoverPeerStateOptionMCLT(mclt)
+
+		default:
+			if !Leaselex.(*LeaseLex).unknown("failover peer detail", "%s %s;", $1, $2) {
+				return 1
+			}
+			$$ = nil
+		}
+	}
+
+	| WORD WORD WORD WORD WORD SEMICOLON
+	{
+		switch {
+
+		// my state startup at never;
+		case $2 == "state" && $4 == "at" && $5 == "never" && ($1 == "my" || $1 == "partner"):
+			$$ = &FailoverPeerStateOptionState{Partner: $1 == "partner", State: $3}
+
+		default:
+			if !Leaselex.(*LeaseLex).unknown("failover peer detail", "%s %s %s %s %s;", $1, $2, $3, $4, $5) {
+				return 1
+			}
+			$$ = nil
+		}
+	}
+
+	| WORD WORD WORD WORD WORD WORD WORD SEMICOLON
+	{
+		switch {
+
+		// my state normal at 1 2021/12/27 09:11:12;
+		// partner state communications-interrupted at 1 2021/12/27 09:11:12;
+		case $2 == "state" && $4 == "at" && ($1 == "my" || $1 == "partner"):
+			// default db-time-format is weekday year/month/day hour:minute:second
+			t, err := time.Parse("2006/01/02 15:04:05", $6 + " " + $7)
+			if err != nil {
+				Leaselex.(*LeaseLex).Errorf("failover peer %s state: %v", $1, err)
+				return 1
+			}
+			$$ = &FailoverPeerStateOptionState{Partner: $1 == "partner", State: $3, At: &t}
+
+		default:
+			if !Leaselex.(*LeaseLex).unknown("failover peer detail", "%s %s %s %s %s %s %s;", $1, $2, $3, $4, $5, $6, $7) {
+				return 1
+			}
+			$$ = nil
+		}
 	};
 
 lease:
 	LEASE WORD BEGINBLOCK lease_details ENDBLOCK
 	{
-		$$ = &DHCPv4Lease{IP: net.ParseIP($2)}
+		ip, err := netip.ParseAddr($2)
+		if err != nil {
+			Leaselex.(*LeaseLex).Errorf("lease ip parse: %v", err)
+			return 1
+		}
+		$$ = &DHCPv4Lease{IP: ip, Pos: Leaselex.(*LeaseLex).start}
 		for _, opt := range $4 {
-			opt.Apply($$)
+			// Unknown details are nil, when lenient
+			if opt != nil {
+				opt.Apply($$)
+			}
 		}
 	};
 
@@ -68,7 +254,33 @@ lease_details: lease_detail { $$ = []DHCPv4LeaseOption{$1} }
 	| lease_details lease_detail { $$ = append($1, $2) };
 
 lease_detail:
-	WORD STRING SEMICOLON
+	WORD WORD SEMICOLON
+	{
+		switch {
+
+		// ends never;
+		// dhcpd writes never for the times of infinite and BOOTP leases,
+		// which are left nil
+		case $2 == "never" && $1 == "ends":
+			$$ = (*DHCPv4LeaseOptionEnds)(nil)
+		case $2 == "never" && $1 == "tstp":
+			$$ = (*DHCPv4LeaseOptionTSTP)(nil)
+		case $2 == "never" && $1 == "tsfp":
+			$$ = (*DHCPv4LeaseOptionTSFP)(nil)
+		case $2 == "never" && $1 == "atsfp":
+			$$ = (*DHCPv4LeaseOptionATSFP)(nil)
+		case $2 == "never" && $1 == "cltt":
+			$$ = (*DHCPv4LeaseOptionCLTT)(nil)
+
+		default:
+			if !Leaselex.(*LeaseLex).unknown("lease detail", "%s %s;", $1, $2) {
+				return 1
+			}
+			$$ = nil
+		}
+	}
+
+	| WORD STRING SEMICOLON
 	{
 		switch {
 		
@@ -76,7 +288,8 @@ lease_detail:
 		case $1 == "uid":
 			unquote, err := octalstr.Parse($2)
 			if err != nil {
-				log.Fatalf("lease detail uid string unquote: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail uid string unquote: %v", err)
+				return 1
 			}
 			$$ = DHCPv4LeaseOptionUID(unquote)
 
@@ -85,7 +298,10 @@ lease_detail:
 			$$ = DHCPv4LeaseOptionClientHostname($2[1:len($2)-1])
 		
 		default:
-			log.Fatalf("unknown lease detail: %s %s;\n", $1, $2)
+			if !Leaselex.(*LeaseLex).unknown("lease detail", "%s %s;", $1, $2) {
+				return 1
+			}
+			$$ = nil
 		}
 	};
 
@@ -99,15 +315,15 @@ lease_detail:
 
 		// hardware ethernet 8c:dc:d4:2b:ec:6c;
 		case $1 == "hardware" && $2 == "ethernet":
-			// TODO: MAC serializes to JSON as base64 by default
-			// ha, err := net.ParseMAC($3)
-			// if err != nil {
-			// 	log.Fatalf("lease detail hardware ethernet parse mac: %v\n", err)
-			// }
+			// Kept as written, so a malformed address doesn't fail the parse;
+			// jsonv2 validates and encodes it as a MAC
 			$$ = DHCPv4LeaseOptionHardwareEthernet($3)
 
 		default:
-			log.Fatalf("unknown lease detail: %s %s %s;\n", $1, $2, $3)
+			if !Leaselex.(*LeaseLex).unknown("lease detail", "%s %s %s;", $1, $2, $3) {
+				return 1
+			}
+			$$ = nil
 		}
 	}
 
@@ -120,7 +336,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail starts: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail starts: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionStarts(t)
 			$$ = &opt
@@ -130,7 +347,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail ends: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail ends: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionEnds(t)
 			$$ = &opt
@@ -140,7 +358,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail tstp: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail tstp: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionTSTP(t)
 			$$ = &opt
@@ -150,7 +369,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail tsfp: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail tsfp: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionTSFP(t)
 			$$ = &opt
@@ -160,7 +380,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail atsfp: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail atsfp: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionATSFP(t)
 			$$ = &opt
@@ -170,7 +391,8 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail cltt: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail cltt: %v", err)
+				return 1
 			}
 			opt := DHCPv4LeaseOptionCLTT(t)
 			$$ = &opt
@@ -184,7 +406,10 @@ lease_detail:
 			$$ = DHCPv4LeaseOptionRewindBindingState($4)
 
 		default:
-			log.Fatalf("unknown lease detail: %s %s %s %s;\n", $1, $2, $3, $4)
+			if !Leaselex.(*LeaseLex).unknown("lease detail", "%s %s %s %s;", $1, $2, $3, $4) {
+				return 1
+			}
+			$$ = nil
 		}
 	}
 
@@ -209,7 +434,10 @@ lease_detail:
 			$$ = DHCPv4LeaseOptionDDNSRevName($4[1:len($4)-1])
 
 		default:
-			log.Fatalf("unknown lease detail: set %s = %s;\n", $2, $4)
+			if !Leaselex.(*LeaseLex).unknown("lease detail", "set %s = %s;", $2, $4) {
+				return 1
+			}
+			$$ = nil
 		}
 	}
 %%
\ No newline at end of file
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/hosts"
+)
+
+var v4LeaseFileFlag = flag.String("v4f", "/var/lib/dhcp/dhcpd.leases", "Path to dhcpd.leases file")
+var v6LeaseFileFlag = flag.String("v6f", "", "Path to dhcpd6.leases file, for AAAA records of the clients named in dhcpd.leases")
+var outputFileFlag = flag.String("o", "", "Path to write ouput")
+var formatFlag = flag.String("format", "hosts", "Output format, hosts, ethers, zone or reverse")
+var domainFlag = flag.String("domain", "", "Domain of names without one, required for PTR records of them")
+var ttlFlag = flag.Int("ttl", 0, "TTL of zone records, the zone's default when 0")
+var conflictFlag = flag.String("conflict", string(hosts.Newest), "Which of the clients claiming the same name keep it, newest, suffix or omit")
+
+func main() {
+	flag.Parse()
+
+	policy, err := hosts.ParsePolicy(*conflictFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var write func(w io.Writer, hs []*hosts.Host) error
+	switch *formatFlag {
+	case "hosts":
+		write = func(w io.Writer, hs []*hosts.Host) error { return hosts.WriteHosts(w, hs) }
+	case "ethers":
+		write = func(w io.Writer, hs []*hosts.Host) error { return hosts.WriteEthers(w, hs) }
+	case "zone":
+		write = func(w io.Writer, hs []*hosts.Host) error { return hosts.WriteZone(w, hs, *ttlFlag) }
+	case "reverse":
+		write = func(w io.Writer, hs []*hosts.Host) error { return hosts.WriteReverseZone(w, hs, *ttlFlag) }
+	default:
+		log.Fatalf("unknown format: %s\n", *formatFlag)
+	}
+
+	v4leases := readDHCPv4Leases(*v4LeaseFileFlag)
+	var v6leases []dhcpd6.DHCPv6Lease
+	if *v6LeaseFileFlag != "" {
+		v6leases = readDHCPv6Leases(*v6LeaseFileFlag)
+	}
+	hs, conflicts := hosts.Collect(v4leases, v6leases, hosts.Options{Domain: *domainFlag, Conflict: policy})
+	for _, c := range conflicts {
+		log.Println(c)
+	}
+
+	outputFile := os.Stdout
+	if *outputFileFlag != "" {
+		f, err := os.Create(*outputFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputFile = f
+	}
+	if err := write(outputFile, hs); err != nil {
+		log.Fatal(err)
+	}
+	if err := outputFile.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readDHCPv4Leases(path string) []dhcpd.DHCPv4Lease {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var res []dhcpd.DHCPv4Lease
+	leases, errc := dhcpd.Parse(f)
+	for lease := range leases {
+		res = append(res, *lease)
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
+
+func readDHCPv6Leases(path string) []dhcpd6.DHCPv6Lease {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var res []dhcpd6.DHCPv6Lease
+	leases, errc := dhcpd6.Parse(f)
+	for lease := range leases {
+		res = append(res, *lease)
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
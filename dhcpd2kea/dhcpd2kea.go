@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/kea"
+)
+
+var leaseFileFlag = flag.String("f", "", "Path to dhcpd.leases or dhcpd6.leases file")
+var confFileFlag = flag.String("c", "", "Path to dhcpd.conf or dhcpd6.conf file, whose subnets are numbered from 1 for Kea subnet ids")
+var subnetIDsFlag = flag.String("subnet-ids", "", "Kea subnet ids, as comma separated prefix=id, overriding those numbered from -c")
+var outputFileFlag = flag.String("o", "", "Path to write ouput, e.g. kea-leases4.csv")
+var v6Flag = flag.Bool("6", false, "Convert a dhcpd6.leases file to kea-leases6.csv")
+
+func main() {
+	flag.Parse()
+
+	var conf *dhcpdconf.Config
+	if *confFileFlag != "" {
+		f, err := os.Open(*confFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf, err = dhcpdconf.Parse(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.Close()
+	}
+	subnets := kea.NewSubnets(conf)
+	if err := subnets.Parse(*subnetIDsFlag); err != nil {
+		log.Fatal(err)
+	}
+
+	leasesFile := os.Stdin
+	if *leaseFileFlag != "" {
+		f, err := os.Open(*leaseFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		leasesFile = f
+	}
+	outputFile := os.Stdout
+	if *outputFileFlag != "" {
+		f, err := os.Create(*outputFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputFile = f
+	}
+
+	var skipped kea.Skipped
+	var err error
+	if *v6Flag {
+		var res []dhcpd6.DHCPv6Lease
+		leases, errc := dhcpd6.Parse(leasesFile)
+		for lease := range leases {
+			res = append(res, *lease)
+		}
+		if err := <-errc; err != nil {
+			log.Fatal(err)
+		}
+		skipped, err = kea.WriteDHCPv6(outputFile, res, subnets)
+	} else {
+		var res []dhcpd.DHCPv4Lease
+		leases, errc := dhcpd.Parse(leasesFile)
+		for lease := range leases {
+			res = append(res, *lease)
+		}
+		if err := <-errc; err != nil {
+			log.Fatal(err)
+		}
+		skipped, err = kea.WriteDHCPv4(outputFile, res, subnets)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := outputFile.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if skipped != (kea.Skipped{}) {
+		log.Printf("skipped %s", skipped)
+	}
+}
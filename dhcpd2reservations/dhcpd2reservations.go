@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/query"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/reservations"
+)
+
+var v4LeaseFileFlag = flag.String("v4f", "", "Path to dhcpd.leases file")
+var v6LeaseFileFlag = flag.String("v6f", "", "Path to dhcpd6.leases file")
+var outputFileFlag = flag.String("o", "", "Path to write ouput")
+var formatFlag = flag.String("format", "dhcpd", "Output format, dhcpd for host declarations or kea for reservations")
+var filterFlag = flag.String("filter", "", "Leases to reserve, as the query parameters of dhcp-httpd's /v1/leases, e.g. subnet=192.168.1.0/24&hostname=printer*")
+
+func main() {
+	flag.Parse()
+
+	if *v4LeaseFileFlag == "" && *v6LeaseFileFlag == "" {
+		log.Fatal("a lease file is required, -v4f or -v6f")
+	}
+	switch *formatFlag {
+	case "dhcpd":
+		if *v4LeaseFileFlag != "" && *v6LeaseFileFlag != "" {
+			log.Fatal("dhcpd.conf and dhcpd6.conf are separate, give either -v4f or -v6f")
+		}
+	case "kea":
+	default:
+		log.Fatalf("unknown format: %s\n", *formatFlag)
+	}
+	values, err := url.ParseQuery(*filterFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	q, err := query.Parse(values, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var v4leases []dhcpd.DHCPv4Lease
+	if *v4LeaseFileFlag != "" {
+		v4leases = reservations.CurrentDHCPv4(readDHCPv4Leases(*v4LeaseFileFlag))
+	}
+	var v6leases []dhcpd6.DHCPv6Lease
+	if *v6LeaseFileFlag != "" {
+		v6leases = reservations.CurrentDHCPv6(readDHCPv6Leases(*v6LeaseFileFlag))
+	}
+	res, err := q.Apply(v4leases, v6leases)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Back in file order, newest last
+	v4hosts, v6hosts := reservations.Collect(reversed(res.DHCPv4Leases), reversed(res.DHCPv6Leases))
+
+	outputFile := os.Stdout
+	if *outputFileFlag != "" {
+		f, err := os.Create(*outputFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputFile = f
+	}
+	switch {
+	case *formatFlag == "kea":
+		var warnings []string
+		warnings, err = reservations.WriteKea(outputFile, v4hosts, v6hosts)
+		for _, warning := range warnings {
+			log.Println(warning)
+		}
+	case *v4LeaseFileFlag != "":
+		err = reservations.WriteDHCPv4Conf(outputFile, v4hosts)
+	default:
+		err = reservations.WriteDHCPv6Conf(outputFile, v6hosts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := outputFile.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func reversed[T any](s []T) []T {
+	res := make([]T, len(s))
+	for i, v := range s {
+		res[len(s)-1-i] = v
+	}
+	return res
+}
+
+func readDHCPv4Leases(path string) []dhcpd.DHCPv4Lease {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var res []dhcpd.DHCPv4Lease
+	leases, errc := dhcpd.Parse(f)
+	for lease := range leases {
+		res = append(res, *lease)
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
+
+func readDHCPv6Leases(path string) []dhcpd6.DHCPv6Lease {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var res []dhcpd6.DHCPv6Lease
+	leases, errc := dhcpd6.Parse(f)
+	for lease := range leases {
+		res = append(res, *lease)
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
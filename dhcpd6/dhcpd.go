@@ -2,9 +2,9 @@
 package dhcpd6
 
 import (
+	"fmt"
 	"io"
-	"log"
-	"net"
+	"net/netip"
 	"time"
 
 	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
@@ -22,19 +22,35 @@ const (
 )
 
 type DHCPv6LeaseAddr struct {
-	IP            net.IP     `json:"ip"`
+	IP            netip.Addr `json:"ip"`
+	PrefixLen     int        `json:"prefix-len,omitempty"` // only set for ia-pd prefixes
 	BindingState  string     `json:"binding-state,omitempty"`
 	PreferredLife int        `json:"preferred-life,omitempty"`
 	MaxLife       int        `json:"max-life,omitempty"`
 	Ends          *time.Time `json:"ends,omitempty"`
 }
 
+// Prefix is the delegated prefix of an ia-pd, or the single address otherwise
+func (addr *DHCPv6LeaseAddr) Prefix() netip.Prefix {
+	if addr.PrefixLen != 0 {
+		return netip.PrefixFrom(addr.IP, addr.PrefixLen)
+	}
+	return netip.PrefixFrom(addr.IP, addr.IP.BitLen())
+}
+
 type DHCPv6Lease struct {
 	Type  DHCPv6LeaseType    `json:"type"`
 	IAID  []byte             `json:"iaid"`           // Identity Associated ID
 	DUID  *duid.DUID         `json:"duid"`           // DHCP Unique ID
 	CLTT  *time.Time         `json:"cltt,omitempty"` // Client's Last Transaction Time
 	Addrs []*DHCPv6LeaseAddr `json:"addrs,omitempty"`
+	// Names the lease file of the lease when several are read together, and
+	// the site of the server which wrote it
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+	// The authoring-byte-order of the file, in which dhcpd writes the IAID:
+	// little-endian, big-endian, or empty if the file doesn't say
+	ByteOrder string `json:"-"`
 }
 
 // Allows us to pile up modifications to lease lazily and then
@@ -87,9 +103,11 @@ func (life DHCPv6LeaseAddrOptionMaxLife) Apply(addr *DHCPv6LeaseAddr) {
 
 type LeaseLex struct {
 	DHCPv6Leases chan *DHCPv6Lease
+	ByteOrder    string // of the leases which follow
 	Line         int
 	LineTokens   []lex.Token
 	Tokens       chan lex.Token
+	err          error // the first error, which stops the parse
 }
 
 func (l *LeaseLex) Lex(lval *LeaseSymType) int {
@@ -120,22 +138,44 @@ func (l *LeaseLex) Lex(lval *LeaseSymType) int {
 		return SET
 	case lex.ItemLease:
 		return LEASE
+	case lex.ItemError:
+		l.Error(token.Val)
 	default:
-		log.Fatalf("unknown token: %+v\n", token)
+		l.Errorf("unknown token: %+v", token)
 	}
+	// Ending the input early stops the parse
 	return 0
 }
 
 func (l *LeaseLex) Error(e string) {
-	log.Fatalf("at %+v: %v\n", l.LineTokens, e)
+	if l.err == nil {
+		var pos lex.Pos
+		if len(l.LineTokens) != 0 {
+			pos = l.LineTokens[len(l.LineTokens)-1].Pos
+		}
+		l.err = fmt.Errorf("at %s: %s", pos, e)
+	}
+}
+
+func (l *LeaseLex) Errorf(format string, args ...interface{}) {
+	l.Error(fmt.Sprintf(format, args...))
 }
 
-func Parse(input io.Reader) chan *DHCPv6Lease {
+// Parse streams the leases of input in the order they appear. Once the
+// leases channel is closed, the error channel receives the result of the
+// parse. Leases parsed before an error are still sent.
+func Parse(input io.Reader) (<-chan *DHCPv6Lease, <-chan error) {
 	tokens := lex.Lex(input)
 	leases := make(chan *DHCPv6Lease)
+	errc := make(chan error, 1)
 	go func() {
-		LeaseParse(&LeaseLex{Tokens: tokens, DHCPv6Leases: leases})
+		l := &LeaseLex{Tokens: tokens, DHCPv6Leases: leases}
+		LeaseParse(l)
+		// Let the lexer run to completion so it doesn't block forever
+		for range tokens {
+		}
 		close(leases)
+		errc <- l.err
 	}()
-	return leases
+	return leases, errc
 }
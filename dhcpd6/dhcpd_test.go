@@ -0,0 +1,59 @@
+package dhcpd6
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// leasesFile generates a dhcpd6.leases file of n distinct leases
+func leasesFile(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("authoring-byte-order little-endian;\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `ia-na "\276\257\244\320\000\003\000\001 \311\320\244\257\276" {
+  cltt 6 2021/12/25 22:24:37;
+  iaaddr 2001:db8::%x:%x {
+    binding state active;
+    preferred-life 375;
+    max-life 600;
+    ends 6 2021/12/25 22:34:37;
+  }
+}
+`, i>>16, i&0xffff)
+	}
+	return b.Bytes()
+}
+
+func TestParseError(t *testing.T) {
+	for _, input := range []string{
+		// unterminated block
+		string(leasesFile(1)) + "ia-na \"\\276\" {\n  cltt 6 2021/12/25 22:24:37;\n",
+		// bad preferred-life
+		strings.Replace(string(leasesFile(1)), "375", "forever", 1),
+	} {
+		leases, errc := Parse(strings.NewReader(input))
+		for range leases {
+		}
+		if err := <-errc; err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+}
+
+// BenchmarkParse parses a file of 100k iaaddrs, which took 8.30M allocs/op
+// with net.IP addresses and 8.20M with netip.Addr.
+func BenchmarkParse(b *testing.B) {
+	input := leasesFile(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		leases, errc := Parse(bytes.NewReader(input))
+		for range leases {
+		}
+		if err := <-errc; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
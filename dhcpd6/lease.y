@@ -3,9 +3,8 @@
 package dhcpd6
 
 import (
-	"log"
 	"time"
-	"net"
+	"net/netip"
 	"strconv"
 
 	"github.com/cptaffe/isc-dhcpd-lease-parser/octalstr"
@@ -46,10 +45,11 @@ statement:
 		
 		// authoring-byte-order little-endian;
 		case $1 == "authoring-byte-order":
-			// do nothing
+			Leaselex.(*LeaseLex).ByteOrder = $2
 		
 		default:
-			log.Fatalf("unknown top-level directive: %s %s;\n", $1, $2)
+			Leaselex.(*LeaseLex).Errorf("unknown top-level directive: %s %s;", $1, $2)
+			return 1
 		}
 	}
 
@@ -62,20 +62,23 @@ statement:
 			// do nothing
 		
 		default:
-			log.Fatalf("unknown top-level directive: %s %s;\n", $1, $2)
+			Leaselex.(*LeaseLex).Errorf("unknown top-level directive: %s %s;", $1, $2)
+			return 1
 		}
 	}
 
 	| LEASE STRING BEGINBLOCK lease_details ENDBLOCK
 	{
-		l := &DHCPv6Lease{Type: DHCPv6LeaseType($1)}
+		l := &DHCPv6Lease{Type: DHCPv6LeaseType($1), ByteOrder: Leaselex.(*LeaseLex).ByteOrder}
 		comb, err := octalstr.Parse($2)
 		if err != nil {
-			log.Fatalf("lease iaid-duid string parse: %v\n", err)
+			Leaselex.(*LeaseLex).Errorf("lease iaid-duid string parse: %v", err)
+			return 1
 		}
 		iaidduid, err := duid.ParseIAIDDUID(comb)
 		if err != nil {
-			log.Fatalf("lease iaid-duid parse: %v\n", err)
+			Leaselex.(*LeaseLex).Errorf("lease iaid-duid parse: %v", err)
+			return 1
 		}
 		l.IAID = iaidduid.IAID
 		l.DUID = iaidduid.DUID
@@ -97,13 +100,15 @@ lease_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease detail cltt: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease detail cltt: %v", err)
+				return 1
 			}
 			opt := DHCPv6LeaseOptionCLTT(t)
 			$$ = &opt
 
 		default:
-			log.Fatalf("unknown lease detail: %s %s %s %s;\n", $1, $2, $3, $4)
+			Leaselex.(*LeaseLex).Errorf("unknown lease detail: %s %s %s %s;", $1, $2, $3, $4)
+			return 1
 		}
 	}
 
@@ -111,14 +116,33 @@ lease_detail:
 	{
 		switch {
 		case $1 == "iaaddr":
-			addr := &DHCPv6LeaseAddr{IP: net.ParseIP($2)}
+			ip, err := netip.ParseAddr($2)
+			if err != nil {
+				Leaselex.(*LeaseLex).Errorf("lease detail iaaddr parse: %v", err)
+				return 1
+			}
+			addr := &DHCPv6LeaseAddr{IP: ip}
+			for _, opt := range $4 {
+				opt.Apply(addr)
+			}
+			$$ = (*DHCPv6LeaseOptionAddr)(addr)
+
+		// iaprefix 2001:db8:1::/56 {
+		case $1 == "iaprefix":
+			prefix, err := netip.ParsePrefix($2)
+			if err != nil {
+				Leaselex.(*LeaseLex).Errorf("lease detail iaprefix parse: %v", err)
+				return 1
+			}
+			addr := &DHCPv6LeaseAddr{IP: prefix.Addr(), PrefixLen: prefix.Bits()}
 			for _, opt := range $4 {
 				opt.Apply(addr)
 			}
 			$$ = (*DHCPv6LeaseOptionAddr)(addr)
 
 		default:
-			log.Fatalf("unknown lease detail: %s %s %s %s;\n", $1, $2, $3, $4)
+			Leaselex.(*LeaseLex).Errorf("unknown lease detail: %s %s %s %s;", $1, $2, $3, $4)
+			return 1
 		}
 	};
 
@@ -134,7 +158,8 @@ lease_addr_detail:
 		case $1 == "preferred-life":
 			i, err := strconv.Atoi($2)
 			if err != nil {
-				log.Fatalf("lease addr preferred-life parse int: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease addr preferred-life parse int: %v", err)
+				return 1
 			}
 			$$ = DHCPv6LeaseAddrOptionPreferredLife(i)
 
@@ -142,12 +167,14 @@ lease_addr_detail:
 		case $1 == "max-life":
 			i, err := strconv.Atoi($2)
 			if err != nil {
-				log.Fatalf("lease addr max-life parse int: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease addr max-life parse int: %v", err)
+				return 1
 			}
 			$$ = DHCPv6LeaseAddrOptionMaxLife(i)
 
 		default:
-			log.Fatalf("unknown lease addr detail: %s %s;\n", $1, $2)
+			Leaselex.(*LeaseLex).Errorf("unknown lease addr detail: %s %s;", $1, $2)
+			return 1
 		}
 	}
 
@@ -160,7 +187,8 @@ lease_addr_detail:
 			$$ = DHCPv6LeaseAddrOptionBindingState($3)
 
 		default:
-			log.Fatalf("unknown lease addr detail: %s %s %s;\n", $1, $2, $3)
+			Leaselex.(*LeaseLex).Errorf("unknown lease addr detail: %s %s %s;", $1, $2, $3)
+			return 1
 		}
 	}
 	
@@ -173,13 +201,15 @@ lease_addr_detail:
 			// default db-time-format is weekday year/month/day hour:minute:second
 			t, err := time.Parse("2006/01/02 15:04:05", $3 + " " + $4)
 			if err != nil {
-				log.Fatalf("lease addr detail ends: %v\n", err)
+				Leaselex.(*LeaseLex).Errorf("lease addr detail ends: %v", err)
+				return 1
 			}
 			opt := DHCPv6LeaseAddrOptionEnds(t)
 			$$ = &opt
 
 		default:
-			log.Fatalf("unknown lease addr detail: %s %s %s %s;\n", $1, $2, $3, $4)
+			Leaselex.(*LeaseLex).Errorf("unknown lease addr detail: %s %s %s %s;", $1, $2, $3, $4)
+			return 1
 		}
 	};
 
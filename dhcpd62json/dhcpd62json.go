@@ -1,20 +1,52 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	dhcpd "github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/follow"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonv2"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/tabular"
 )
 
 var leaseFileFlag = flag.String("f", "", "Path to dhcpd.leases file")
 var outputFileFlag = flag.String("o", "", "Path to write ouput")
+var schemaFlag = flag.String("schema", "v1", "JSON schema of the output, v1 or v2")
+var formatFlag = flag.String("format", "json", "Output format, json, csv or tsv")
+var columnsFlag = flag.String("columns", strings.Join(tabular.DefaultColumns, ","), "Comma separated columns of csv and tsv output, see the tabular package")
+var followFlag = flag.Bool("follow", false, "Follow the lease file, writing an event for each change to the binding of an address or prefix")
 
 func main() {
 	flag.Parse()
 
+	switch *schemaFlag {
+	case "v1", jsonv2.Schema:
+	default:
+		log.Fatalf("unknown schema: %s\n", *schemaFlag)
+	}
+	columns, err := tabular.ParseColumns(*columnsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var comma rune
+	switch *formatFlag {
+	case "json":
+	case "csv":
+		comma = ','
+	case "tsv":
+		comma = '\t'
+	default:
+		log.Fatalf("unknown format: %s\n", *formatFlag)
+	}
+
 	outputFile := os.Stdout
 	if *outputFileFlag != "" {
 		f, err := os.Create(*outputFileFlag)
@@ -23,6 +55,17 @@ func main() {
 		}
 		outputFile = f
 	}
+	if *followFlag {
+		if *leaseFileFlag == "" {
+			log.Fatal("-follow requires a lease file, -f")
+		}
+		if *formatFlag != "json" {
+			log.Fatal("-follow writes JSON")
+		}
+		followLeases(outputFile)
+		return
+	}
+
 	leasesFile := os.Stdin
 	if *leaseFileFlag != "" {
 		f, err := os.Open(*leaseFileFlag)
@@ -32,10 +75,68 @@ func main() {
 		leasesFile = f
 	}
 
-	for lease := range dhcpd.Parse(leasesFile) {
-		err := json.NewEncoder(outputFile).Encode(lease)
+	leases, errc := dhcpd.Parse(leasesFile)
+	var table *tabular.Writer
+	if comma != 0 {
+		table = tabular.NewWriter(outputFile, comma, columns)
+	}
+	for lease := range leases {
+		if table != nil {
+			if err := table.DHCPv6(lease); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		var v interface{} = lease
+		if *schemaFlag == jsonv2.Schema {
+			v = jsonv2.FromDHCPv6Lease(lease)
+		}
+		err := json.NewEncoder(outputFile).Encode(v)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
+	if table != nil {
+		if err := table.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// A follow.DHCPv6Event in the v2 schema
+type v2Event struct {
+	Type     follow.EventType        `json:"type"`
+	Lease    *jsonv2.DHCPv6Lease     `json:"lease"`
+	Addr     *jsonv2.DHCPv6LeaseAddr `json:"addr"`
+	Previous *jsonv2.DHCPv6LeaseAddr `json:"previous,omitempty"`
+}
+
+func followLeases(outputFile io.Writer) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	events, errc := follow.DHCPv6(ctx, *leaseFileFlag, time.Second)
+	enc := json.NewEncoder(outputFile)
+	for event := range events {
+		var v interface{} = event
+		if *schemaFlag == jsonv2.Schema {
+			v2 := v2Event{
+				Type:  event.Type,
+				Lease: jsonv2.FromDHCPv6Lease(event.Lease),
+				Addr:  jsonv2.FromDHCPv6LeaseAddr(event.Addr),
+			}
+			if event.Previous != nil {
+				v2.Previous = jsonv2.FromDHCPv6LeaseAddr(event.Previous)
+			}
+			v = v2
+		}
+		if err := enc.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := <-errc; err != nil {
+		log.Fatal(err)
+	}
 }
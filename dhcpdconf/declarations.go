@@ -0,0 +1,303 @@
+package dhcpdconf
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/lex"
+)
+
+// Declarations are what may appear at the top-level or within any of the
+// scoping declarations, dhcpd doesn't enforce much more nesting than that.
+type Declarations struct {
+	SharedNetworks []*SharedNetwork `json:"shared-networks,omitempty"`
+	Subnets        []*Subnet        `json:"subnets,omitempty"`
+	Pools          []*Pool          `json:"pools,omitempty"`
+	Ranges         []*Range         `json:"ranges,omitempty"`
+	Prefixes       []*Prefix6       `json:"prefixes,omitempty"`
+	Hosts          []*Host          `json:"hosts,omitempty"`
+	Groups         []*Group         `json:"groups,omitempty"`
+	// Anything else, e.g. option, class or if statements
+	Parameters []*Statement `json:"parameters,omitempty"`
+}
+
+type Config struct {
+	Declarations
+}
+
+// shared-network "name" { ... }
+type SharedNetwork struct {
+	Pos  lex.Pos `json:"-"`
+	Name string  `json:"name"`
+	Declarations
+}
+
+// subnet 192.168.1.0 netmask 255.255.255.0 { ... }
+// subnet6 2001:db8::/64 { ... }
+type Subnet struct {
+	Pos    lex.Pos      `json:"-"`
+	Prefix netip.Prefix `json:"prefix"`
+	Declarations
+}
+
+// pool { ... } or pool6 { ... }
+type Pool struct {
+	Pos   lex.Pos `json:"-"`
+	Pool6 bool    `json:"pool6,omitempty"`
+	Declarations
+}
+
+// group { ... }
+type Group struct {
+	Pos lex.Pos `json:"-"`
+	Declarations
+}
+
+// range [dynamic-bootp] 192.168.1.100 [192.168.1.200];
+// range6 2001:db8::100 2001:db8::200;
+// range6 2001:db8::/64 [temporary];
+type Range struct {
+	Pos          lex.Pos    `json:"-"`
+	Start        netip.Addr `json:"start"`
+	End          netip.Addr `json:"end"`
+	DynamicBOOTP bool       `json:"dynamic-bootp,omitempty"`
+	Temporary    bool       `json:"temporary,omitempty"`
+}
+
+func (r *Range) Contains(addr netip.Addr) bool {
+	return r.Start.Compare(addr) <= 0 && addr.Compare(r.End) <= 0
+}
+
+// prefix6 2001:db8:0:100:: 2001:db8:0:f00:: /56;
+type Prefix6 struct {
+	Pos   lex.Pos    `json:"-"`
+	Start netip.Addr `json:"start"`
+	End   netip.Addr `json:"end"`
+	Bits  int        `json:"bits"`
+}
+
+func (p *Prefix6) Contains(addr netip.Addr) bool {
+	return p.Start.Compare(addr) <= 0 && addr.Compare(p.End) <= 0
+}
+
+// host name { hardware ethernet ...; fixed-address ...; }
+type Host struct {
+	Pos              lex.Pos  `json:"-"`
+	Name             string   `json:"name"`
+	HardwareEthernet string   `json:"hardware-ethernet,omitempty"`
+	FixedAddresses   []string `json:"fixed-addresses,omitempty"`
+	FixedAddresses6  []string `json:"fixed-addresses6,omitempty"`
+	// host-identifier option dhcp6.client-id 00:01:00:01:...;
+	ClientID   string       `json:"client-id,omitempty"`
+	Parameters []*Statement `json:"parameters,omitempty"`
+}
+
+func (d *Declarations) declare(stmts []*Statement) error {
+	for _, stmt := range stmts {
+		// e.g. a bare { }
+		if len(stmt.Words) == 0 {
+			return fmt.Errorf("at %s: block without a keyword", stmt.Pos)
+		}
+		if err := d.declareOne(stmt); err != nil {
+			return fmt.Errorf("at %s: %s: %w", stmt.Pos, stmt.Words[0], err)
+		}
+	}
+	return nil
+}
+
+func (d *Declarations) declareOne(stmt *Statement) error {
+	if stmt.Block == nil {
+		switch stmt.Words[0] {
+		case "range", "range6":
+			r, err := parseRange(stmt)
+			if err != nil {
+				return err
+			}
+			d.Ranges = append(d.Ranges, r)
+		case "prefix6":
+			p, err := parsePrefix6(stmt)
+			if err != nil {
+				return err
+			}
+			d.Prefixes = append(d.Prefixes, p)
+		default:
+			d.Parameters = append(d.Parameters, stmt)
+		}
+		return nil
+	}
+
+	switch stmt.Words[0] {
+	case "shared-network":
+		sn := &SharedNetwork{Pos: stmt.Pos, Name: stmt.arg(0)}
+		d.SharedNetworks = append(d.SharedNetworks, sn)
+		return sn.declare(stmt.Block)
+
+	case "subnet":
+		// subnet 192.168.1.0 netmask 255.255.255.0
+		if len(stmt.Words) != 4 || stmt.Words[2] != "netmask" {
+			return fmt.Errorf("expected subnet address netmask mask")
+		}
+		addr, err := netip.ParseAddr(stmt.Words[1])
+		if err != nil {
+			return err
+		}
+		mask, err := netip.ParseAddr(stmt.Words[3])
+		if err != nil {
+			return err
+		}
+		bits, total := net.IPMask(mask.AsSlice()).Size()
+		if total == 0 {
+			return fmt.Errorf("non-contiguous netmask %s", mask)
+		}
+		sn := &Subnet{Pos: stmt.Pos, Prefix: netip.PrefixFrom(addr, bits)}
+		d.Subnets = append(d.Subnets, sn)
+		return sn.declare(stmt.Block)
+
+	case "subnet6":
+		prefix, err := netip.ParsePrefix(stmt.arg(0))
+		if err != nil {
+			return err
+		}
+		sn := &Subnet{Pos: stmt.Pos, Prefix: prefix}
+		d.Subnets = append(d.Subnets, sn)
+		return sn.declare(stmt.Block)
+
+	case "pool", "pool6":
+		p := &Pool{Pos: stmt.Pos, Pool6: stmt.Words[0] == "pool6"}
+		d.Pools = append(d.Pools, p)
+		return p.declare(stmt.Block)
+
+	case "group":
+		g := &Group{Pos: stmt.Pos}
+		d.Groups = append(d.Groups, g)
+		return g.declare(stmt.Block)
+
+	case "host":
+		h, err := parseHost(stmt)
+		if err != nil {
+			return err
+		}
+		d.Hosts = append(d.Hosts, h)
+
+	default:
+		d.Parameters = append(d.Parameters, stmt)
+	}
+	return nil
+}
+
+func parseRange(stmt *Statement) (*Range, error) {
+	r := &Range{Pos: stmt.Pos}
+	args := stmt.Words[1:]
+	if len(args) > 0 && args[0] == "dynamic-bootp" {
+		r.DynamicBOOTP = true
+		args = args[1:]
+	}
+	if len(args) > 0 && args[len(args)-1] == "temporary" {
+		r.Temporary = true
+		args = args[:len(args)-1]
+	}
+	switch len(args) {
+	case 1:
+		// range6 2001:db8::/64; or range 192.168.1.100;
+		if strings.Contains(args[0], "/") {
+			prefix, err := netip.ParsePrefix(args[0])
+			if err != nil {
+				return nil, err
+			}
+			r.Start = prefix.Masked().Addr()
+			r.End = lastAddr(prefix)
+			return r, nil
+		}
+		addr, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		r.Start, r.End = addr, addr
+	case 2:
+		var err error
+		r.Start, err = netip.ParseAddr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		r.End, err = netip.ParseAddr(args[1])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected one or two addresses but found %d", len(args))
+	}
+	if r.End.Less(r.Start) {
+		return nil, fmt.Errorf("range end %s before start %s", r.End, r.Start)
+	}
+	return r, nil
+}
+
+func parsePrefix6(stmt *Statement) (*Prefix6, error) {
+	if len(stmt.Words) != 4 || !strings.HasPrefix(stmt.Words[3], "/") {
+		return nil, fmt.Errorf("expected prefix6 start end /bits")
+	}
+	start, err := netip.ParseAddr(stmt.Words[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := netip.ParseAddr(stmt.Words[2])
+	if err != nil {
+		return nil, err
+	}
+	var bits int
+	if _, err := fmt.Sscanf(stmt.Words[3], "/%d", &bits); err != nil {
+		return nil, fmt.Errorf("parse prefix length %s: %w", stmt.Words[3], err)
+	}
+	return &Prefix6{Pos: stmt.Pos, Start: start, End: end, Bits: bits}, nil
+}
+
+func parseHost(stmt *Statement) (*Host, error) {
+	h := &Host{Pos: stmt.Pos, Name: stmt.arg(0)}
+	for _, s := range stmt.Block {
+		switch {
+		case len(s.Words) == 0:
+			return nil, fmt.Errorf("at %s: block without a keyword", s.Pos)
+		case s.Words[0] == "hardware" && len(s.Words) == 3:
+			h.HardwareEthernet = s.Words[2]
+		case s.Words[0] == "fixed-address":
+			h.FixedAddresses = append(h.FixedAddresses, addressList(s.Words[1:])...)
+		case s.Words[0] == "fixed-address6":
+			h.FixedAddresses6 = append(h.FixedAddresses6, addressList(s.Words[1:])...)
+		case s.Words[0] == "host-identifier" && len(s.Words) == 4 && s.Words[2] == "dhcp6.client-id":
+			h.ClientID = s.Words[3]
+		default:
+			h.Parameters = append(h.Parameters, s)
+		}
+	}
+	return h, nil
+}
+
+// fixed-address 192.168.1.10, 192.168.1.11;
+func addressList(words []string) []string {
+	var addrs []string
+	for _, w := range words {
+		for _, a := range strings.Split(w, ",") {
+			if a != "" {
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	return addrs
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Masked().Addr().AsSlice()
+	bits := prefix.Bits()
+	for i := range b {
+		if bits >= 8 {
+			bits -= 8
+			continue
+		}
+		b[i] |= 0xff >> bits
+		bits = 0
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,105 @@
+package dhcpdconf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/lex"
+)
+
+// dhcpd.conf is a tree of statements, each either ending in a semicolon or
+// opening a block of further statements, see dhcpd.conf(5). It is parsed
+// in two passes: first into that generic tree, then into typed declarations
+// for the scopes we care about. Everything else is kept as a Statement.
+
+type Statement struct {
+	Pos   lex.Pos      `json:"-"`
+	Words []string     `json:"words"`
+	Block []*Statement `json:"block,omitempty"`
+}
+
+func (s *Statement) String() string {
+	return strings.Join(s.Words, " ")
+}
+
+// arg returns the i-th word following the keyword, without quotes
+func (s *Statement) arg(i int) string {
+	if i+1 >= len(s.Words) {
+		return ""
+	}
+	return strings.Trim(s.Words[i+1], "\"")
+}
+
+type parser struct {
+	tokens chan lex.Token
+}
+
+func (p *parser) statements(nested bool) ([]*Statement, error) {
+	var stmts []*Statement
+	cur := &Statement{}
+	for token := range p.tokens {
+		if len(cur.Words) == 0 {
+			cur.Pos = token.Pos
+		}
+		switch token.Typ {
+		case lex.ItemError:
+			return nil, fmt.Errorf("at %s: %s", token.Pos, token.Val)
+		case lex.ItemSemicolon:
+			if len(cur.Words) != 0 {
+				stmts = append(stmts, cur)
+			}
+			cur = &Statement{}
+		case lex.ItemBeginBlock:
+			block, err := p.statements(true)
+			if err != nil {
+				return nil, err
+			}
+			cur.Block = block
+			if cur.Block == nil {
+				cur.Block = []*Statement{}
+			}
+			stmts = append(stmts, cur)
+			cur = &Statement{}
+		case lex.ItemEndBlock:
+			if !nested {
+				return nil, fmt.Errorf("at %s: unexpected }", token.Pos)
+			}
+			if len(cur.Words) != 0 {
+				return nil, fmt.Errorf("at %s: expected ; after %s", cur.Pos, cur)
+			}
+			return stmts, nil
+		default:
+			cur.Words = append(cur.Words, token.Val)
+		}
+	}
+	if nested {
+		return nil, fmt.Errorf("unexpected end of file, expected }")
+	}
+	if len(cur.Words) != 0 {
+		return nil, fmt.Errorf("at %s: expected ; after %s", cur.Pos, cur)
+	}
+	return stmts, nil
+}
+
+// ParseStatements parses input into the generic statement tree
+func ParseStatements(input io.Reader) ([]*Statement, error) {
+	p := &parser{tokens: lex.Lex(input)}
+	stmts, err := p.statements(false)
+	// Let the lexer run to completion so it doesn't block forever
+	for range p.tokens {
+	}
+	return stmts, err
+}
+
+func Parse(input io.Reader) (*Config, error) {
+	stmts, err := ParseStatements(input)
+	if err != nil {
+		return nil, fmt.Errorf("parse statements: %w", err)
+	}
+	conf := &Config{}
+	if err := conf.declare(stmts); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
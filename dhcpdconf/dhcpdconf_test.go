@@ -0,0 +1,133 @@
+package dhcpdconf
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const conf = `# dhcpd.conf
+option domain-name "heavy.computer";
+default-lease-time 600;
+authoritative;
+
+shared-network "home" {
+  subnet 192.168.1.0 netmask 255.255.255.0 {
+    option routers 192.168.1.1;
+    pool {
+      deny unknown-clients;
+      range 192.168.1.100 192.168.1.199;
+    }
+    range dynamic-bootp 192.168.1.200 192.168.1.250;
+  }
+}
+
+subnet6 2001:db8::/64 {
+  range6 2001:db8::100 2001:db8::1ff;
+  range6 2001:db8::/64 temporary;
+  prefix6 2001:db8:0:100:: 2001:db8:0:f00:: /56;
+}
+
+group {
+  host wopr {
+    hardware ethernet 8c:dc:d4:2b:ec:6c;
+    fixed-address 192.168.1.10, 192.168.1.11;
+  }
+  host printer {
+    host-identifier option dhcp6.client-id 00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75;
+    fixed-address6 2001:db8::10;
+  }
+}
+
+class "printers" {
+  match if substring (option vendor-class-identifier, 0, 4) = "HPJD";
+}
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("parse conf: %v", err)
+	}
+
+	if len(c.SharedNetworks) != 1 || c.SharedNetworks[0].Name != "home" {
+		t.Fatalf("expected shared-network home but was %+v", c.SharedNetworks)
+	}
+	subnet := c.SharedNetworks[0].Subnets[0]
+	if subnet.Prefix != netip.MustParsePrefix("192.168.1.0/24") {
+		t.Errorf("expected subnet 192.168.1.0/24 but was %s", subnet.Prefix)
+	}
+	if len(subnet.Pools) != 1 || len(subnet.Pools[0].Ranges) != 1 {
+		t.Errorf("expected one pool with one range but was %+v", subnet.Pools)
+	}
+	if len(subnet.Ranges) != 1 || !subnet.Ranges[0].DynamicBOOTP {
+		t.Errorf("expected one dynamic-bootp range but was %+v", subnet.Ranges)
+	}
+
+	if len(c.Subnets) != 1 || len(c.Subnets[0].Ranges) != 2 || len(c.Subnets[0].Prefixes) != 1 {
+		t.Fatalf("expected subnet6 with two ranges and a prefix6 but was %+v", c.Subnets)
+	}
+	temporary := c.Subnets[0].Ranges[1]
+	if !temporary.Temporary || temporary.End != netip.MustParseAddr("2001:db8::ffff:ffff:ffff:ffff") {
+		t.Errorf("expected temporary range to span the /64 but was %+v", temporary)
+	}
+
+	hosts := c.Groups[0].Hosts
+	if len(hosts) != 2 {
+		t.Fatalf("expected two hosts but was %d", len(hosts))
+	}
+	if hosts[0].HardwareEthernet != "8c:dc:d4:2b:ec:6c" || len(hosts[0].FixedAddresses) != 2 {
+		t.Errorf("unexpected host %+v", hosts[0])
+	}
+	if hosts[1].ClientID != "00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75" {
+		t.Errorf("unexpected host client-id %s", hosts[1].ClientID)
+	}
+
+	if len(c.Parameters) != 4 {
+		t.Errorf("expected four top-level parameters but was %d", len(c.Parameters))
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c, err := Parse(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("parse conf: %v", err)
+	}
+
+	scope, ok := c.Lookup(netip.MustParseAddr("192.168.1.150"))
+	if !ok || scope.Pool == nil || scope.Range == nil || scope.SharedNetwork == nil {
+		t.Errorf("expected address to be in a pool range but was %+v", scope)
+	}
+
+	scope, ok = c.Lookup(netip.MustParseAddr("192.168.1.5"))
+	if !ok || scope.Subnet == nil || scope.Range != nil {
+		t.Errorf("expected address to be in the subnet only but was %+v", scope)
+	}
+
+	scope, ok = c.Lookup(netip.MustParseAddr("2001:db8:0:200::"))
+	if !ok || scope.Prefix6 == nil {
+		t.Errorf("expected delegated prefix to be in the prefix6 but was %+v", scope)
+	}
+
+	if _, ok := c.Lookup(netip.MustParseAddr("10.0.0.1")); ok {
+		t.Error("expected address outside any subnet not to be found")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("subnet 192.168.1.0 netmask 255.255.255.0 {\n  range 192.168.1.100\n}\n"))
+	if err == nil {
+		t.Fatal("expected missing semicolon to fail")
+	}
+
+	// Blocks without a keyword
+	for _, input := range []string{
+		"subnet 10.0.0.0 netmask 255.0.0.0 {\n  { }\n}\n",
+		"host a {\n  { }\n}\n",
+	} {
+		_, err := Parse(strings.NewReader(input))
+		if err == nil || !strings.Contains(err.Error(), "at 2:3: block without a keyword") {
+			t.Errorf("%q: expected an error at 2:3, got %v", input, err)
+		}
+	}
+}
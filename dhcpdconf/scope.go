@@ -0,0 +1,74 @@
+package dhcpdconf
+
+import "net/netip"
+
+// Scope locates a range or prefix6 within the declarations enclosing it,
+// any of which may be nil, e.g. when a range is declared directly within a
+// subnet rather than a pool.
+type Scope struct {
+	SharedNetwork *SharedNetwork
+	Subnet        *Subnet
+	Pool          *Pool
+	Range         *Range
+	Prefix6       *Prefix6
+}
+
+// Walk calls fn for every subnet, and then for every range and prefix6 within
+// it, in the order they are declared. Ranges and prefixes declared outside of
+// any subnet are visited too.
+func (c *Config) Walk(fn func(scope Scope)) {
+	c.walk(Scope{}, fn)
+}
+
+func (d *Declarations) walk(scope Scope, fn func(scope Scope)) {
+	for _, r := range d.Ranges {
+		s := scope
+		s.Range = r
+		fn(s)
+	}
+	for _, p := range d.Prefixes {
+		s := scope
+		s.Prefix6 = p
+		fn(s)
+	}
+	for _, p := range d.Pools {
+		s := scope
+		s.Pool = p
+		p.walk(s, fn)
+	}
+	for _, sn := range d.Subnets {
+		s := scope
+		s.Subnet = sn
+		fn(s)
+		sn.walk(s, fn)
+	}
+	for _, sn := range d.SharedNetworks {
+		s := scope
+		s.SharedNetwork = sn
+		sn.walk(s, fn)
+	}
+	for _, g := range d.Groups {
+		g.walk(scope, fn)
+	}
+}
+
+// Lookup finds the range or prefix6 an address was leased from, or failing
+// that, the subnet it belongs to. Delegated prefixes are looked up by their
+// first address, as they don't fall within the subnet6 declaring them.
+func (c *Config) Lookup(addr netip.Addr) (Scope, bool) {
+	var res Scope
+	var found, inRange bool
+	c.Walk(func(scope Scope) {
+		if inRange {
+			return
+		}
+		switch {
+		case scope.Range != nil && scope.Range.Contains(addr),
+			scope.Prefix6 != nil && scope.Prefix6.Contains(addr):
+			res, found, inRange = scope, true, true
+		case scope.Range == nil && scope.Prefix6 == nil && !found && scope.Subnet.Prefix.Contains(addr):
+			res, found = scope, true
+		}
+	})
+	return res, found
+}
@@ -0,0 +1,165 @@
+// Package dnsmasq reads dnsmasq's lease file as the leases of dhcpd, so both
+// servers can be shown together. The file holds a line per v4 lease:
+//
+//	1640471677 8c:dc:d4:2b:ec:6c 192.168.1.107 wopr 01:8c:dc:d4:2b:ec:6c
+//
+// of when it expires, the MAC, the address, the hostname and the client
+// identifier, either of the last two * when unknown. Then, if there are v6
+// leases, a line with dnsmasq's DUID and a line per v6 lease:
+//
+//	duid 00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75
+//	1640471677 3500453822 2001:db8::1 wopr 00:03:00:01:20:c9:d0:a4:af:be
+//
+// of when it expires, the IAID prefixed by T for a temporary address, the
+// address, the hostname and the client's DUID. dnsmasq rewrites the file
+// whenever a lease changes, dropping those which expire, so its leases are
+// all active.
+package dnsmasq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+// lines calls v4 or v6 with the fields of each lease's line
+func lines(input io.Reader, v4, v6 func(fields []string) error) error {
+	s := bufio.NewScanner(input)
+	var line int
+	inV6 := false
+	for s.Scan() {
+		line++
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "duid" {
+			inV6 = true
+			continue
+		}
+		if len(fields) < 5 {
+			return fmt.Errorf("line %d: expected 5 fields, got %d", line, len(fields))
+		}
+		var err error
+		if inV6 {
+			err = v6(fields)
+		} else {
+			err = v4(fields)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return s.Err()
+}
+
+// expires is when the lease ends, or nil if it never does
+func expires(s string) (*time.Time, error) {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expiry: %w", err)
+	}
+	if secs == 0 {
+		return nil, nil
+	}
+	t := time.Unix(secs, 0).UTC()
+	return &t, nil
+}
+
+// optional is the field, or empty if it is *
+func optional(s string) string {
+	if s == "*" {
+		return ""
+	}
+	return s
+}
+
+// ParseDHCPv4 reads the v4 leases of a dnsmasq.leases file
+func ParseDHCPv4(input io.Reader) (<-chan *dhcpd.DHCPv4Lease, <-chan error) {
+	leases := make(chan *dhcpd.DHCPv4Lease)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(leases)
+		errc <- lines(input, func(fields []string) error {
+			ends, err := expires(fields[0])
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(fields[2])
+			if err != nil {
+				return err
+			}
+			lease := &dhcpd.DHCPv4Lease{
+				IP:             ip,
+				Ends:           ends,
+				BindingState:   "active",
+				ClientHostname: optional(fields[3]),
+			}
+			// Other hardware types are prefixed by their type, e.g. 06-
+			if mac, err := net.ParseMAC(fields[1]); err == nil {
+				lease.HardwareEthernet = mac.String()
+			}
+			if uid, err := duid.ParseHex(optional(fields[4])); err == nil && len(uid) > 0 {
+				dhcpd.DHCPv4LeaseOptionUID(uid).Apply(lease)
+			}
+			leases <- lease
+			return nil
+		}, func([]string) error { return nil })
+	}()
+	return leases, errc
+}
+
+// ParseDHCPv6 reads the v6 leases of a dnsmasq.leases file, a lease of each
+// address. IAIDs are given in dhcpd's byte order, little-endian. dnsmasq
+// doesn't delegate prefixes, and dhcpd's v6 leases have no hostname.
+func ParseDHCPv6(input io.Reader) (<-chan *dhcpd6.DHCPv6Lease, <-chan error) {
+	leases := make(chan *dhcpd6.DHCPv6Lease)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(leases)
+		errc <- lines(input, func([]string) error { return nil }, func(fields []string) error {
+			ends, err := expires(fields[0])
+			if err != nil {
+				return err
+			}
+			lease := &dhcpd6.DHCPv6Lease{
+				Type: dhcpd6.DHCPv6LeaseTypeNonTemporary,
+				IAID: make([]byte, 4),
+			}
+			iaid := fields[1]
+			if strings.HasPrefix(iaid, "T") {
+				lease.Type = dhcpd6.DHCPv6LeaseTypeTemporary
+				iaid = iaid[1:]
+			}
+			n, err := strconv.ParseUint(iaid, 10, 32)
+			if err != nil {
+				return fmt.Errorf("iaid: %w", err)
+			}
+			binary.LittleEndian.PutUint32(lease.IAID, uint32(n))
+			ip, err := netip.ParseAddr(fields[2])
+			if err != nil {
+				return err
+			}
+			lease.Addrs = []*dhcpd6.DHCPv6LeaseAddr{{IP: ip, BindingState: "active", Ends: ends}}
+			// A * or a DUID of an unknown type leaves the lease without one
+			if d, err := duid.ParseDUIDString(fields[4]); err == nil {
+				lease.DUID = d
+			}
+			leases <- lease
+			return nil
+		})
+	}()
+	return leases, errc
+}
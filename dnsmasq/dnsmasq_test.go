@@ -0,0 +1,69 @@
+package dnsmasq
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+const leases = `1640471677 8c:dc:d4:2b:ec:6c 192.168.1.107 wopr 01:8c:dc:d4:2b:ec:6c
+0 06-00:11:22:33:44:55 192.168.1.108 * *
+duid 00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75
+1640471677 3500453822 2001:db8::22c9:d0ff:fea4:afbe wopr 00:03:00:01:20:c9:d0:a4:af:be
+1640471677 T1 2001:db8::1234 * 00:04:4c:4c:45:44:00:32:51:10:80:34:b2:c0:4f:54:4e:32
+`
+
+func TestParseDHCPv4(t *testing.T) {
+	ch, errc := ParseDHCPv4(strings.NewReader(leases))
+	var res []*dhcpd.DHCPv4Lease
+	for lease := range ch {
+		res = append(res, lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 leases, got %d", len(res))
+	}
+	ends := time.Date(2021, time.December, 25, 22, 34, 37, 0, time.UTC)
+	lease := res[0]
+	if lease.IP != netip.MustParseAddr("192.168.1.107") || lease.HardwareEthernet != "8c:dc:d4:2b:ec:6c" ||
+		lease.ClientHostname != "wopr" || lease.BindingState != "active" || !lease.Ends.Equal(ends) || lease.ClientID == nil {
+		t.Errorf("unexpected lease %+v", lease)
+	}
+	if lease := res[1]; lease.Ends != nil || lease.HardwareEthernet != "" || lease.ClientHostname != "" || lease.UID != nil {
+		t.Errorf("expected a lease without an end, MAC, hostname or client identifier, got %+v", lease)
+	}
+}
+
+func TestParseDHCPv6(t *testing.T) {
+	ch, errc := ParseDHCPv6(strings.NewReader(leases))
+	var res []*dhcpd6.DHCPv6Lease
+	for lease := range ch {
+		res = append(res, lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 leases, got %d", len(res))
+	}
+	lease := res[0]
+	// As dhcpd writes it, see the dhcpd6 tests
+	if lease.Type != dhcpd6.DHCPv6LeaseTypeNonTemporary || string(lease.IAID) != "\276\257\244\320" ||
+		lease.DUID == nil || lease.DUID.LL == nil || lease.Addrs[0].IP != netip.MustParseAddr("2001:db8::22c9:d0ff:fea4:afbe") {
+		t.Errorf("unexpected lease %+v", lease)
+	}
+	// A DUID-UUID isn't understood, but the lease is still kept
+	if lease := res[1]; lease.Type != dhcpd6.DHCPv6LeaseTypeTemporary || lease.DUID != nil {
+		t.Errorf("expected a temporary lease without a DUID, got %+v", lease)
+	}
+
+	if _, errc := ParseDHCPv6(strings.NewReader("duid 00:01\n1640471677 x 2001:db8::1 * *\n")); <-errc == nil {
+		t.Error("expected an error for a malformed IAID")
+	}
+}
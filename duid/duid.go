@@ -2,8 +2,10 @@ package duid
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/cptaffe/isc-dhcpd-lease-parser/enterprisenumbers"
@@ -67,9 +69,14 @@ type IAIDDUID struct {
 	DUID *DUID  `json:"duid"`
 }
 
-var duidEpoch = time.Date(2000, time.December, 30, 0, 0, 0, 0, time.UTC)
+// DUID-LLT time is in seconds since midnight (UTC), January 1, 2000, see
+// RFC 8415 section 11.2
+var duidEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
 
 func ParseDUID(duid []byte) (*DUID, error) {
+	if len(duid) < 4 {
+		return nil, fmt.Errorf("duid too short: %d bytes", len(duid))
+	}
 	duidtype := DUIDType(binary.BigEndian.Uint16(duid[0:2]))
 	res := &DUID{
 		Type: duidtype,
@@ -77,8 +84,11 @@ func ParseDUID(duid []byte) (*DUID, error) {
 
 	switch duidtype {
 	case DUIDTypeLLT:
+		if len(duid) < 8 {
+			return nil, fmt.Errorf("duid-llt too short: %d bytes", len(duid))
+		}
 		hwtype := HardwareType(binary.BigEndian.Uint16(duid[2:4]))
-		time := duidEpoch.Add(time.Duration(binary.BigEndian.Uint32(duid[4:8])))
+		time := duidEpoch.Add(time.Duration(binary.BigEndian.Uint32(duid[4:8])) * time.Second)
 		mac := net.HardwareAddr(duid[8:])
 		res.LLT = &DUIDLLT{
 			HardwareType: hwtype,
@@ -87,6 +97,9 @@ func ParseDUID(duid []byte) (*DUID, error) {
 		}
 
 	case DUIDTypeEN:
+		if len(duid) < 6 {
+			return nil, fmt.Errorf("duid-en too short: %d bytes", len(duid))
+		}
 		en := enterprisenumbers.EN(binary.BigEndian.Uint32(duid[2:6]))
 		// EN can be anything, in the case of the HP JetDirect 635n it is a MAC
 		hwaddr := net.HardwareAddr(duid[6:])
@@ -114,8 +127,61 @@ func ParseDUID(duid []byte) (*DUID, error) {
 	return res, nil
 }
 
+// ParseDUIDString parses the colon separated hex form produced by DUID.String
+func ParseDUIDString(s string) (*DUID, error) {
+	b, err := ParseHex(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode duid hex: %w", err)
+	}
+	return ParseDUID(b)
+}
+
+// Bytes re-encodes the DUID in its wire format
+func (d *DUID) Bytes() []byte {
+	var b []byte
+	switch {
+	case d.LLT != nil:
+		b = make([]byte, 8)
+		binary.BigEndian.PutUint16(b[2:4], uint16(d.LLT.HardwareType))
+		binary.BigEndian.PutUint32(b[4:8], uint32(d.LLT.Time.Sub(duidEpoch)/time.Second))
+		b = append(b, parseHex(d.LLT.HardwareAddr)...)
+	case d.EN != nil:
+		b = make([]byte, 6)
+		binary.BigEndian.PutUint32(b[2:6], uint32(d.EN.EN))
+		b = append(b, parseHex(d.EN.HardwareAddr)...)
+	case d.LL != nil:
+		b = make([]byte, 4)
+		binary.BigEndian.PutUint16(b[2:4], uint16(d.LL.HardwareType))
+		b = append(b, parseHex(d.LL.HardwareAddr)...)
+	default:
+		b = make([]byte, 2)
+	}
+	binary.BigEndian.PutUint16(b[0:2], uint16(d.Type))
+	return b
+}
+
+// String is the DUID as colon separated hex, as Kea and most tooling display it
+func (d *DUID) String() string {
+	return net.HardwareAddr(d.Bytes()).String()
+}
+
+// ParseHex is the inverse of net.HardwareAddr.String, which unlike
+// net.ParseMAC works for identifiers of any length, such as client ids
+func ParseHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+}
+
+// parseHex is ParseHex of an address already checked by ParseDUID
+func parseHex(s string) []byte {
+	b, _ := ParseHex(s)
+	return b
+}
+
 // TODO: Respect IA_NA/TA/PD
 func ParseIAIDDUID(combined []byte) (*IAIDDUID, error) {
+	if len(combined) < 4 {
+		return nil, fmt.Errorf("iaid too short: %d bytes", len(combined))
+	}
 	duid := combined[4:]
 
 	res, err := ParseDUID(duid)
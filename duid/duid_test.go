@@ -2,6 +2,7 @@ package duid
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cptaffe/isc-dhcpd-lease-parser/octalstr"
 )
@@ -44,3 +45,39 @@ func TestParseOctal(t *testing.T) {
 		t.Errorf("expected MAC to be %s but was %s", expectedMAC, duid.LL.HardwareAddr)
 	}
 }
+
+func TestDUIDString(t *testing.T) {
+	in := "00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75"
+
+	duid, err := ParseDUIDString(in)
+	if err != nil {
+		t.Fatalf("parse duid string: %v", err)
+	}
+
+	if duid.LLT == nil {
+		t.Fatalf("expected LLT but was %s", duid.Type)
+	}
+
+	if got := duid.String(); got != in {
+		t.Errorf("expected round trip to be %s but was %s", in, got)
+	}
+}
+
+func TestDUIDLLTTime(t *testing.T) {
+	for in, expected := range map[string]string{
+		"00:01:00:01:00:00:00:00:00:0c:29:2c:ef:75": "2000-01-01T00:00:00Z",
+		"00:01:00:01:00:00:00:3c:00:0c:29:2c:ef:75": "2000-01-01T00:01:00Z",
+		"00:01:00:01:29:59:63:9c:00:0c:29:2c:ef:75": "2021-12-25T05:07:40Z",
+	} {
+		duid, err := ParseDUIDString(in)
+		if err != nil {
+			t.Fatalf("parse duid string: %v", err)
+		}
+		if got := duid.LLT.Time.Format(time.RFC3339); got != expected {
+			t.Errorf("%s: expected time to be %s but was %s", in, expected, got)
+		}
+		if got := duid.String(); got != in {
+			t.Errorf("expected round trip to be %s but was %s", in, got)
+		}
+	}
+}
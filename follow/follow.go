@@ -0,0 +1,214 @@
+// Package follow tails a lease file as dhcpd appends to it, and reports how
+// each appended lease changes the binding of its address.
+package follow
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+type EventType string
+
+const (
+	// An address is bound to a client it wasn't already bound to
+	EventNew EventType = "new"
+	// A client's binding is extended
+	EventRenewed EventType = "renewed"
+	// A client gives up its binding
+	EventReleased EventType = "released"
+	// A binding ends without being renewed
+	EventExpired EventType = "expired"
+	// Any other change of binding state, e.g. expired to free
+	EventStateChange EventType = "state-change"
+)
+
+type DHCPv4Event struct {
+	Type     EventType          `json:"type"`
+	Lease    *dhcpd.DHCPv4Lease `json:"lease"`
+	Previous *dhcpd.DHCPv4Lease `json:"previous,omitempty"`
+}
+
+// DHCPv6Event is the change to one address or prefix of a lease
+type DHCPv6Event struct {
+	Type     EventType               `json:"type"`
+	Lease    *dhcpd6.DHCPv6Lease     `json:"lease"`
+	Addr     *dhcpd6.DHCPv6LeaseAddr `json:"addr"`
+	Previous *dhcpd6.DHCPv6LeaseAddr `json:"previous,omitempty"`
+}
+
+// binding is what decides the type of an event
+type binding struct {
+	state  string
+	client string
+	ends   *time.Time
+}
+
+// classify describes the change from the previous binding of an address,
+// if any, to the latest one. Repeats of the same binding aren't events.
+func classify(prev *binding, cur binding) (EventType, bool) {
+	if prev == nil {
+		if cur.state == "active" {
+			return EventNew, true
+		}
+		return EventStateChange, true
+	}
+	switch {
+	case cur.state == "active" && (prev.state != "active" || prev.client != cur.client):
+		return EventNew, true
+	case cur.state == "active":
+		if !timeEqual(prev.ends, cur.ends) {
+			return EventRenewed, true
+		}
+	case cur.state == prev.state:
+	case cur.state == "released":
+		return EventReleased, true
+	case cur.state == "expired":
+		return EventExpired, true
+	default:
+		return EventStateChange, true
+	}
+	return "", false
+}
+
+func timeEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// DHCPv4 follows the dhcpd.leases file at path, checking it for changes
+// every interval, until ctx is done. The leases already in the file are
+// read without events, only later changes are reported. Once the events
+// channel is closed, the error channel receives why, which is nil if ctx
+// is done.
+func DHCPv4(ctx context.Context, path string, interval time.Duration) (<-chan DHCPv4Event, <-chan error) {
+	events := make(chan DHCPv4Event)
+	errc := make(chan error, 1)
+	bindings := map[netip.Addr]*dhcpd.DHCPv4Lease{}
+	update := func(chunk []byte, emit bool) error {
+		leases, parseErrc := dhcpd.Parse(bytes.NewReader(chunk))
+		for lease := range leases {
+			prev := bindings[lease.IP]
+			bindings[lease.IP] = lease
+			if !emit {
+				continue
+			}
+			var prevBinding *binding
+			if prev != nil {
+				b := dhcpv4Binding(prev)
+				prevBinding = &b
+			}
+			typ, ok := classify(prevBinding, dhcpv4Binding(lease))
+			if !ok {
+				continue
+			}
+			select {
+			case events <- DHCPv4Event{Type: typ, Lease: lease, Previous: prev}:
+			case <-ctx.Done():
+				// Stop emitting, but let the parse finish
+				emit = false
+			}
+		}
+		return <-parseErrc
+	}
+	go func() {
+		err := follow(ctx, path, interval, update)
+		close(events)
+		errc <- err
+	}()
+	return events, errc
+}
+
+func dhcpv4Binding(lease *dhcpd.DHCPv4Lease) binding {
+	return binding{
+		state:  lease.BindingState,
+		client: lease.HardwareEthernet + "/" + hex.EncodeToString(lease.UID),
+		ends:   lease.Ends,
+	}
+}
+
+// DHCPv6 follows the dhcpd6.leases file at path as DHCPv4 does, with an
+// event per address or prefix of each lease.
+func DHCPv6(ctx context.Context, path string, interval time.Duration) (<-chan DHCPv6Event, <-chan error) {
+	events := make(chan DHCPv6Event)
+	errc := make(chan error, 1)
+	bindings := map[netip.Prefix]*dhcpd6.DHCPv6LeaseAddr{}
+	clients := map[netip.Prefix]string{}
+	update := func(chunk []byte, emit bool) error {
+		leases, parseErrc := dhcpd6.Parse(bytes.NewReader(chunk))
+		for lease := range leases {
+			client := dhcpv6Client(lease)
+			for _, addr := range lease.Addrs {
+				prefix := addr.Prefix()
+				prev, prevClient := bindings[prefix], clients[prefix]
+				bindings[prefix], clients[prefix] = addr, client
+				if !emit {
+					continue
+				}
+				var prevBinding *binding
+				if prev != nil {
+					prevBinding = &binding{state: prev.BindingState, client: prevClient, ends: prev.Ends}
+				}
+				typ, ok := classify(prevBinding, binding{state: addr.BindingState, client: client, ends: addr.Ends})
+				if !ok {
+					continue
+				}
+				select {
+				case events <- DHCPv6Event{Type: typ, Lease: lease, Addr: addr, Previous: prev}:
+				case <-ctx.Done():
+					emit = false
+				}
+			}
+		}
+		return <-parseErrc
+	}
+	go func() {
+		err := follow(ctx, path, interval, update)
+		close(events)
+		errc <- err
+	}()
+	return events, errc
+}
+
+// The client of a v6 lease is its identity association
+func dhcpv6Client(lease *dhcpd6.DHCPv6Lease) string {
+	client := fmt.Sprintf("%s/%x", lease.Type, lease.IAID)
+	if lease.DUID != nil {
+		client += "/" + lease.DUID.String()
+	}
+	return client
+}
+
+// follow passes update the complete statements appended to the file at
+// path, or the whole file when it is replaced, until ctx is done. Events
+// are emitted for all but the first read.
+func follow(ctx context.Context, path string, interval time.Duration, update func(chunk []byte, emit bool) error) error {
+	t := &tailer{path: path}
+	defer t.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		chunk, err := t.read()
+		if err != nil {
+			return err
+		}
+		if len(chunk) != 0 {
+			if err := update(chunk, !first); err != nil {
+				return fmt.Errorf("parse %s: %w", path, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
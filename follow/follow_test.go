@@ -0,0 +1,129 @@
+package follow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComplete(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		n     int
+	}{
+		{"", 0},
+		{"# comment; {\n", 0},
+		{"authoring-byte-order little-endian;\n", 35},
+		{"lease 10.0.0.1 {\n  binding state active;\n", 0},
+		{"lease 10.0.0.1 {\n  binding state active;\n}\nlease 10.0.0.2 {", 42},
+		{"lease 10.0.0.1 {\n  client-hostname \"}\";\n}\n", 41},
+	} {
+		if n := complete([]byte(tt.input)); n != tt.n {
+			t.Errorf("complete(%q) = %d, expected %d", tt.input, n, tt.n)
+		}
+	}
+}
+
+func lease(ip, state, mac, ends string) string {
+	return fmt.Sprintf(`lease %s {
+  ends 6 2021/12/25 %s;
+  binding state %s;
+  hardware ethernet %s;
+}
+`, ip, ends, state, mac)
+}
+
+func TestDHCPv4(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dhcpd.leases")
+	if err := os.WriteFile(path, []byte(lease("10.0.0.1", "active", "00:00:00:00:00:01", "22:00:00")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, errc := DHCPv4(ctx, path, 10*time.Millisecond)
+
+	appendFile := func(s string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(typ EventType, ip string) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Type != typ || event.Lease.IP.String() != ip {
+				t.Fatalf("expected %s of %s, got %s of %s", typ, ip, event.Type, event.Lease.IP)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %s of %s, got nothing", typ, ip)
+		}
+	}
+
+	// The lease already in the file isn't an event, its renewal is
+	time.Sleep(50 * time.Millisecond)
+	appendFile(lease("10.0.0.1", "active", "00:00:00:00:00:01", "23:00:00"))
+	expect(EventRenewed, "10.0.0.1")
+
+	// A lease written in parts is only parsed once it is whole
+	l := lease("10.0.0.2", "active", "00:00:00:00:00:02", "23:00:00")
+	appendFile(l[:20])
+	time.Sleep(50 * time.Millisecond)
+	appendFile(l[20:])
+	expect(EventNew, "10.0.0.2")
+
+	appendFile(lease("10.0.0.1", "released", "00:00:00:00:00:01", "23:00:00"))
+	expect(EventReleased, "10.0.0.1")
+
+	// Rewriting the file resyncs, only the changed lease is an event
+	tmp := filepath.Join(dir, "dhcpd.leases.new")
+	rewrite := "authoring-byte-order little-endian;\n" +
+		lease("10.0.0.1", "active", "00:00:00:00:00:03", "23:30:00") +
+		lease("10.0.0.2", "active", "00:00:00:00:00:02", "23:00:00")
+	if err := os.WriteFile(tmp, []byte(rewrite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventNew, "10.0.0.1")
+
+	cancel()
+	for range events {
+	}
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ends := time.Date(2021, 12, 25, 22, 0, 0, 0, time.UTC)
+	later := ends.Add(time.Hour)
+	for _, tt := range []struct {
+		prev *binding
+		cur  binding
+		typ  EventType
+		ok   bool
+	}{
+		{nil, binding{state: "active", client: "a"}, EventNew, true},
+		{&binding{state: "active", client: "a", ends: &ends}, binding{state: "active", client: "a", ends: &ends}, "", false},
+		{&binding{state: "active", client: "a", ends: &ends}, binding{state: "active", client: "a", ends: &later}, EventRenewed, true},
+		{&binding{state: "active", client: "a"}, binding{state: "active", client: "b"}, EventNew, true},
+		{&binding{state: "active", client: "a"}, binding{state: "expired", client: "a"}, EventExpired, true},
+		{&binding{state: "active", client: "a"}, binding{state: "released", client: "a"}, EventReleased, true},
+		{&binding{state: "expired", client: "a"}, binding{state: "free", client: "a"}, EventStateChange, true},
+	} {
+		typ, ok := classify(tt.prev, tt.cur)
+		if typ != tt.typ || ok != tt.ok {
+			t.Errorf("classify(%+v, %+v) = %s, %t, expected %s, %t", tt.prev, tt.cur, typ, ok, tt.typ, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package follow
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// tailer reads what is appended to a file, in whole top-level statements,
+// and notices when the file is replaced. dhcpd rewrites the lease file by
+// writing dhcpd.leases.<pid> and renaming it over dhcpd.leases, after
+// moving the old one to dhcpd.leases~.
+type tailer struct {
+	path    string
+	f       *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte // an incomplete statement at the end of the file
+}
+
+// read returns the complete statements appended since the last read. If
+// the file was replaced or truncated they are those of the whole new file.
+// A file which doesn't exist yet is treated as empty.
+func (t *tailer) read() ([]byte, error) {
+	info, err := os.Stat(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.f == nil || !os.SameFile(t.info, info) || info.Size() < t.offset {
+		t.close()
+		f, err := os.Open(t.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Stat the file we opened, the path may have been replaced again
+		if info, err = f.Stat(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		t.f, t.info, t.offset, t.partial = f, info, 0, nil
+	}
+
+	if _, err := t.f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(t.f)
+	if err != nil {
+		return nil, err
+	}
+	t.offset += int64(len(b))
+	t.partial = append(t.partial, b...)
+
+	n := complete(t.partial)
+	chunk := t.partial[:n]
+	t.partial = append([]byte(nil), t.partial[n:]...)
+	return chunk, nil
+}
+
+func (t *tailer) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+}
+
+// complete is the length of the prefix of b made up of whole top-level
+// statements, those ending in a semicolon or a closing brace. It follows
+// the lexer: comments run from # to the end of the line, and strings from
+// one double quote to the next.
+func complete(b []byte) int {
+	var n, depth int
+	var inString, inComment bool
+	for i, c := range b {
+		switch {
+		case inComment:
+			inComment = c != '\n'
+		case inString:
+			inString = c != '"'
+		case c == '#':
+			inComment = true
+		case c == '"':
+			inString = true
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				n = i + 1
+			}
+		case c == ';':
+			if depth == 0 {
+				n = i + 1
+			}
+		}
+	}
+	return n
+}
@@ -2,7 +2,6 @@ module github.com/cptaffe/isc-dhcpd-lease-parser
 
 go 1.18
 
-require (
-	github.com/adjust/goautoneg v0.0.0-20150426214442-d788f35a0315 // indirect
-	golang.org/x/tools v0.1.8 // indirect
-)
+require github.com/adjust/goautoneg v0.0.0-20150426214442-d788f35a0315
+
+require golang.org/x/tools v0.1.8 // indirect
@@ -0,0 +1,134 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config is read from a JSON file, e.g.
+//
+//	{
+//	  "pool-threshold": 90,
+//	  "seen-file": "/var/lib/dhcp-httpd/seen",
+//	  "dead-letter-file": "/var/log/dhcp-httpd/dead-letter.jsonl",
+//	  "hooks": [
+//	    {"name": "slack", "events": ["new-mac"], "url": "https://hooks.slack.com/services/...", "rate-limit": 10},
+//	    {"name": "quarantine", "events": ["abandoned"], "command": ["/usr/local/bin/quarantine"]}
+//	  ]
+//	}
+type Config struct {
+	Hooks []*Hook `json:"hooks"`
+	// Percent utilization of a pool above which pool-threshold fires, 0 never
+	PoolThreshold float64 `json:"pool-threshold,omitempty"`
+	// Remembers the MACs seen across restarts, otherwise only those in the
+	// lease files at startup are known
+	SeenFile string `json:"seen-file,omitempty"`
+	// Events which couldn't be delivered are appended here, or logged
+	DeadLetterFile string `json:"dead-letter-file,omitempty"`
+}
+
+// Hook delivers events either by POSTing them as JSON to a URL or by running
+// a command with the JSON on its stdin.
+type Hook struct {
+	Name    string            `json:"name"`
+	Events  []EventType       `json:"events,omitempty"` // all when empty
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Command []string          `json:"command,omitempty"`
+	Timeout Duration          `json:"timeout,omitempty"` // of each attempt
+	// Attempts before the event is dead-lettered, retries are delayed by
+	// RetryDelay doubling each time
+	Attempts   int      `json:"attempts,omitempty"`
+	RetryDelay Duration `json:"retry-delay,omitempty"`
+	// Deliveries per minute, 0 is unlimited. Events wait their turn.
+	RateLimit int `json:"rate-limit,omitempty"`
+}
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultAttempts   = 3
+	defaultRetryDelay = time.Second
+)
+
+func (h *Hook) wants(typ EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, t := range h.Events {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hook) validate() error {
+	if h.Name == "" {
+		return errors.New("hook without a name")
+	}
+	if (h.URL == "") == (len(h.Command) == 0) {
+		return fmt.Errorf("hook %s: expected one of url or command", h.Name)
+	}
+	for _, t := range h.Events {
+		switch t {
+		case EventNewMAC, EventHostnameChange, EventPoolThreshold, EventAbandoned:
+		default:
+			return fmt.Errorf("hook %s: unknown event %s", h.Name, t)
+		}
+	}
+	if h.Timeout == 0 {
+		h.Timeout = Duration(defaultTimeout)
+	}
+	if h.Attempts == 0 {
+		h.Attempts = defaultAttempts
+	}
+	if h.RetryDelay == 0 {
+		h.RetryDelay = Duration(defaultRetryDelay)
+	}
+	return nil
+}
+
+// ParseConfig decodes and validates a configuration, filling in defaults
+func ParseConfig(b []byte) (*Config, error) {
+	var conf Config
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, err
+	}
+	for _, h := range conf.Hooks {
+		if err := h.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &conf, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// Duration is a time.Duration written as a string, e.g. "1.5s"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
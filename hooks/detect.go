@@ -0,0 +1,211 @@
+// Package hooks runs automation when the leases change in ways worth acting
+// on, see Detector for which, by POSTing to webhooks or running commands.
+package hooks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/follow"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/stats"
+)
+
+type EventType string
+
+const (
+	// A MAC which was never seen before is leased an address
+	EventNewMAC EventType = "new-mac"
+	// A client asks for a lease with a different hostname than before
+	EventHostnameChange EventType = "hostname-change"
+	// A pool's utilization rises above the configured threshold
+	EventPoolThreshold EventType = "pool-threshold"
+	// dhcpd abandons an address, usually because something else answered
+	// a ping for it
+	EventAbandoned EventType = "abandoned"
+)
+
+// Event is the JSON payload delivered to hooks
+type Event struct {
+	Type             EventType           `json:"type"`
+	Time             time.Time           `json:"time"`
+	HardwareAddr     string              `json:"hwaddr,omitempty"`
+	Vendor           string              `json:"vendor,omitempty"`
+	Hostname         string              `json:"hostname,omitempty"`
+	PreviousHostname string              `json:"previous-hostname,omitempty"`
+	DHCPv4Lease      *dhcpd.DHCPv4Lease  `json:"v4-lease,omitempty"`
+	DHCPv6Lease      *dhcpd6.DHCPv6Lease `json:"v6-lease,omitempty"`
+	Pool             *stats.Usage        `json:"pool,omitempty"`
+}
+
+// Detector derives hook events from the changes to the leases reported by
+// the follow package, and from pool statistics. It is safe for concurrent use.
+type Detector struct {
+	mu        sync.Mutex
+	threshold float64
+	seen      map[string]bool
+	seenFile  *os.File
+	hostnames map[string]string // by MAC
+	above     map[string]bool   // pools above the threshold, by range
+}
+
+// NewDetector loads the MACs seen before from conf.SeenFile, if any, and
+// appends those seen for the first time to it.
+func NewDetector(conf *Config) (*Detector, error) {
+	d := &Detector{
+		threshold: conf.PoolThreshold,
+		seen:      map[string]bool{},
+		hostnames: map[string]string{},
+		above:     map[string]bool{},
+	}
+	if conf.SeenFile == "" {
+		return d, nil
+	}
+	f, err := os.Open(conf.SeenFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if mac, err := net.ParseMAC(s.Text()); err == nil {
+				d.seen[mac.String()] = true
+			}
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("read %s: %w", conf.SeenFile, err)
+		}
+	}
+	d.seenFile, err = os.OpenFile(conf.SeenFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Detector) Close() error {
+	if d.seenFile != nil {
+		return d.seenFile.Close()
+	}
+	return nil
+}
+
+// Seed records the MACs and hostnames of the existing leases, given in file
+// order, without events.
+func (d *Detector) Seed(v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range v4leases {
+		if mac, ok := inventory.DHCPv4HardwareAddr(&v4leases[i]); ok {
+			d.see(mac)
+			if v4leases[i].ClientHostname != "" {
+				d.hostnames[mac.String()] = v4leases[i].ClientHostname
+			}
+		}
+	}
+	for i := range v6leases {
+		if mac, ok := inventory.DHCPv6HardwareAddr(&v6leases[i]); ok {
+			d.see(mac)
+		}
+	}
+}
+
+// see records mac, reporting whether it is new
+func (d *Detector) see(mac net.HardwareAddr) bool {
+	if d.seen[mac.String()] {
+		return false
+	}
+	d.seen[mac.String()] = true
+	if d.seenFile != nil {
+		// Losing one means a repeated event after a restart, not worth failing over
+		fmt.Fprintln(d.seenFile, mac)
+	}
+	return true
+}
+
+func (d *Detector) DHCPv4(e follow.DHCPv4Event) []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lease := e.Lease
+	now := time.Now()
+	newEvent := func(typ EventType) Event {
+		return Event{Type: typ, Time: now, Hostname: lease.ClientHostname, DHCPv4Lease: lease}
+	}
+	var events []Event
+	mac, ok := inventory.DHCPv4HardwareAddr(lease)
+	if ok {
+		if d.see(mac) {
+			events = append(events, newEvent(EventNewMAC))
+		}
+		prev := d.hostnames[mac.String()]
+		if lease.ClientHostname != "" && prev != "" && lease.ClientHostname != prev {
+			event := newEvent(EventHostnameChange)
+			event.PreviousHostname = prev
+			events = append(events, event)
+		}
+		if lease.ClientHostname != "" {
+			d.hostnames[mac.String()] = lease.ClientHostname
+		}
+	}
+	// follow doesn't report repeats of the same state
+	if lease.BindingState == "abandoned" {
+		events = append(events, newEvent(EventAbandoned))
+	}
+	if ok {
+		for i := range events {
+			events[i].HardwareAddr = mac.String()
+			events[i].Vendor = inventory.Vendor(mac)
+		}
+	}
+	return events
+}
+
+func (d *Detector) DHCPv6(e follow.DHCPv6Event) []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	var events []Event
+	mac, ok := inventory.DHCPv6HardwareAddr(e.Lease)
+	if ok && d.see(mac) {
+		events = append(events, Event{Type: EventNewMAC, Time: now, DHCPv6Lease: e.Lease})
+	}
+	if e.Addr.BindingState == "abandoned" {
+		events = append(events, Event{Type: EventAbandoned, Time: now, DHCPv6Lease: e.Lease})
+	}
+	if ok {
+		for i := range events {
+			events[i].HardwareAddr = mac.String()
+			events[i].Vendor = inventory.Vendor(mac)
+		}
+	}
+	return events
+}
+
+// Pools reports the pools which rose above the threshold since the last
+// call. A pool must fall below it again to be reported again.
+func (d *Detector) Pools(pools []*stats.Usage) []Event {
+	if d.threshold == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var events []Event
+	for _, u := range pools {
+		key := fmt.Sprintf("%s %s-%s/%d", u.Site, u.Start, u.End, u.PrefixLen)
+		above := u.Utilization() >= d.threshold
+		if above && !d.above[key] {
+			events = append(events, Event{Type: EventPoolThreshold, Time: time.Now(), Pool: u})
+		}
+		d.above[key] = above
+	}
+	return events
+}
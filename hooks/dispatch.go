@@ -0,0 +1,220 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Events waiting on a slow hook beyond this are dead-lettered
+const queueSize = 256
+
+// Dispatcher delivers events to the hooks which want them, each hook in
+// order and independently of the others.
+type Dispatcher struct {
+	workers    []*worker
+	deadLetter *deadLetter
+	wg         sync.WaitGroup
+}
+
+type worker struct {
+	hook    *Hook
+	queue   chan Event
+	limiter *limiter
+	client  *http.Client
+}
+
+func NewDispatcher(conf *Config) (*Dispatcher, error) {
+	d := &Dispatcher{deadLetter: &deadLetter{}}
+	if conf.DeadLetterFile != "" {
+		f, err := os.OpenFile(conf.DeadLetterFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		d.deadLetter.f = f
+	}
+	for _, h := range conf.Hooks {
+		w := &worker{
+			hook:   h,
+			queue:  make(chan Event, queueSize),
+			client: &http.Client{Timeout: time.Duration(h.Timeout)},
+		}
+		if h.RateLimit > 0 {
+			w.limiter = newLimiter(h.RateLimit, time.Minute)
+		}
+		d.workers = append(d.workers, w)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.run(w)
+		}()
+	}
+	return d, nil
+}
+
+// Dispatch queues the event for delivery, it doesn't wait on the hooks
+func (d *Dispatcher) Dispatch(e Event) {
+	for _, w := range d.workers {
+		if !w.hook.wants(e.Type) {
+			continue
+		}
+		select {
+		case w.queue <- e:
+		default:
+			d.deadLetter.write(w.hook, e, fmt.Errorf("queue full"))
+		}
+	}
+}
+
+// Close delivers the queued events and stops. Dispatch mustn't be called
+// after.
+func (d *Dispatcher) Close() error {
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.wg.Wait()
+	return d.deadLetter.close()
+}
+
+func (d *Dispatcher) run(w *worker) {
+	for e := range w.queue {
+		if w.limiter != nil {
+			time.Sleep(w.limiter.reserve(time.Now()))
+		}
+		delay := time.Duration(w.hook.RetryDelay)
+		var err error
+		for attempt := 1; ; attempt++ {
+			if err = w.deliver(e); err == nil {
+				break
+			}
+			if attempt >= w.hook.Attempts {
+				d.deadLetter.write(w.hook, e, err)
+				break
+			}
+			log.Printf("hook %s: attempt %d: %v\n", w.hook.Name, attempt, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+func (w *worker) deliver(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if w.hook.URL != "" {
+		return w.post(payload)
+	}
+	return w.exec(e, payload)
+}
+
+func (w *worker) post(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.hook.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post %s: %s", w.hook.URL, resp.Status)
+	}
+	return nil
+}
+
+// exec runs the command with the event on stdin and its type in HOOK_EVENT
+func (w *worker) exec(e Event, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(w.hook.Timeout))
+	defer cancel()
+	cmd := exec.CommandContext(ctx, w.hook.Command[0], w.hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), "HOOK_EVENT="+string(e.Type))
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w, stderr: %s", w.hook.Command[0], err, stderr.String())
+	}
+	return nil
+}
+
+// limiter is a token bucket of n tokens refilled over per
+type limiter struct {
+	n      float64
+	per    time.Duration
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(n int, per time.Duration) *limiter {
+	return &limiter{n: float64(n), per: per, tokens: float64(n)}
+}
+
+// reserve takes a token, returning how long to wait until it's available
+func (l *limiter) reserve(now time.Time) time.Duration {
+	if !l.last.IsZero() {
+		l.tokens += l.n * float64(now.Sub(l.last)) / float64(l.per)
+		if l.tokens > l.n {
+			l.tokens = l.n
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.per) / l.n)
+}
+
+// deadLetter records the events which couldn't be delivered as JSON lines
+type deadLetter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+type deadLetterEntry struct {
+	Time  time.Time `json:"time"`
+	Hook  string    `json:"hook"`
+	Error string    `json:"error"`
+	Event Event     `json:"event"`
+}
+
+func (dl *deadLetter) write(h *Hook, e Event, err error) {
+	entry := deadLetterEntry{Time: time.Now(), Hook: h.Name, Error: err.Error(), Event: e}
+	b, merr := json.Marshal(entry)
+	if merr != nil {
+		log.Printf("hook %s: dead letter: %v\n", h.Name, merr)
+		return
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.f == nil {
+		log.Printf("hook %s: dead letter: %s\n", h.Name, b)
+		return
+	}
+	if _, werr := dl.f.Write(append(b, '\n')); werr != nil {
+		log.Printf("hook %s: dead letter: %v: %s\n", h.Name, werr, b)
+	}
+}
+
+func (dl *deadLetter) close() error {
+	if dl.f != nil {
+		return dl.f.Close()
+	}
+	return nil
+}
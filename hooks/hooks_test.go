@@ -0,0 +1,225 @@
+package hooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/follow"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/stats"
+)
+
+// receiver records the events POSTed to it, failing the first n requests
+type receiver struct {
+	mu     sync.Mutex
+	fail   int
+	events []Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail > 0 {
+		rc.fail--
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	var e Event
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc.events = append(rc.events, e)
+}
+
+func TestWebhook(t *testing.T) {
+	rc := &receiver{fail: 2}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	deadLetterFile := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	conf, err := ParseConfig([]byte(`{
+		"dead-letter-file": "` + deadLetterFile + `",
+		"hooks": [
+			{"name": "receiver", "events": ["new-mac"], "url": "` + srv.URL + `", "retry-delay": "1ms"},
+			{"name": "broken", "url": "http://127.0.0.1:0/", "attempts": 1}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDispatcher(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(Event{Type: EventNewMAC, HardwareAddr: "8c:dc:d4:2b:ec:6c"})
+	d.Dispatch(Event{Type: EventAbandoned})
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delivered on the third attempt, and only the event it wants
+	if len(rc.events) != 1 || rc.events[0].HardwareAddr != "8c:dc:d4:2b:ec:6c" {
+		t.Errorf("expected the new-mac event, got %+v", rc.events)
+	}
+
+	// Both events fail the broken hook
+	f, err := os.Open(deadLetterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var types []EventType
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var entry deadLetterEntry
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Hook != "broken" || entry.Error == "" {
+			t.Errorf("unexpected dead letter %+v", entry)
+		}
+		types = append(types, entry.Event.Type)
+	}
+	if len(types) != 2 || types[0] != EventNewMAC || types[1] != EventAbandoned {
+		t.Errorf("expected both events dead-lettered, got %v", types)
+	}
+}
+
+func TestExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	hook := &Hook{
+		Name:    "exec",
+		Command: []string{"sh", "-c", `echo "$HOOK_EVENT" > ` + out + ` && cat >> ` + out},
+	}
+	if err := hook.validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDispatcher(&Config{Hooks: []*Hook{hook}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Dispatch(Event{Type: EventHostnameChange, Hostname: "wopr"})
+	d.Close()
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(b), "\n", 2)
+	if lines[0] != "hostname-change" || !strings.Contains(lines[1], `"hostname":"wopr"`) {
+		t.Errorf("unexpected output %q", b)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, time.Minute)
+	now := time.Now()
+	for i, expected := range []time.Duration{0, 0, 30 * time.Second, time.Minute} {
+		if d := l.reserve(now); d != expected {
+			t.Errorf("reservation %d: expected %s, got %s", i, expected, d)
+		}
+	}
+	// Refilled after waiting out the reservations
+	if d := l.reserve(now.Add(2 * time.Minute)); d != 0 {
+		t.Errorf("expected no wait after refill, got %s", d)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, input := range []string{
+		`{"hooks": [{"url": "http://localhost/"}]}`,
+		`{"hooks": [{"name": "both", "url": "http://localhost/", "command": ["true"]}]}`,
+		`{"hooks": [{"name": "neither"}]}`,
+		`{"hooks": [{"name": "unknown", "url": "http://localhost/", "events": ["lease"]}]}`,
+		`{"hooks": [{"name": "duration", "url": "http://localhost/", "timeout": "soon"}]}`,
+	} {
+		if _, err := ParseConfig([]byte(input)); err == nil {
+			t.Errorf("expected error parsing %s", input)
+		}
+	}
+	conf, err := ParseConfig([]byte(`{"hooks": [{"name": "defaults", "url": "http://localhost/"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := conf.Hooks[0]; h.Attempts != defaultAttempts || time.Duration(h.Timeout) != defaultTimeout {
+		t.Errorf("expected defaults, got %+v", h)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	seenFile := filepath.Join(t.TempDir(), "seen")
+	if err := os.WriteFile(seenFile, []byte("00:00:00:00:00:01\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDetector(&Config{PoolThreshold: 50, SeenFile: seenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.Seed([]dhcpd.DHCPv4Lease{{HardwareEthernet: "00:00:00:00:00:02", ClientHostname: "wopr"}}, nil)
+
+	lease := func(mac, hostname, state string) follow.DHCPv4Event {
+		return follow.DHCPv4Event{Lease: &dhcpd.DHCPv4Lease{
+			IP:               netip.MustParseAddr("10.0.0.1"),
+			HardwareEthernet: mac,
+			ClientHostname:   hostname,
+			BindingState:     state,
+		}}
+	}
+	for _, tt := range []struct {
+		event    follow.DHCPv4Event
+		expected []EventType
+	}{
+		{lease("00:00:00:00:00:01", "", "active"), nil},
+		{lease("00:00:00:00:00:02", "wopr", "active"), nil},
+		{lease("00:00:00:00:00:02", "joshua", "active"), []EventType{EventHostnameChange}},
+		{lease("00:00:00:00:00:03", "", "active"), []EventType{EventNewMAC}},
+		{lease("00:00:00:00:00:03", "", "abandoned"), []EventType{EventAbandoned}},
+	} {
+		var types []EventType
+		for _, e := range d.DHCPv4(tt.event) {
+			types = append(types, e.Type)
+		}
+		if strings.Join(eventStrings(types), ",") != strings.Join(eventStrings(tt.expected), ",") {
+			t.Errorf("%+v: expected %v, got %v", tt.event.Lease, tt.expected, types)
+		}
+	}
+
+	// The new MAC is remembered
+	b, err := os.ReadFile(seenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "00:00:00:00:00:03") {
+		t.Errorf("expected the new MAC in the seen file, got %q", b)
+	}
+
+	pool := &stats.Usage{Start: netip.MustParseAddr("10.0.0.1"), End: netip.MustParseAddr("10.0.0.10"), Total: 10}
+	for i, tt := range []struct {
+		active   uint64
+		expected int
+	}{{4, 0}, {6, 1}, {7, 0}, {2, 0}, {5, 1}} {
+		pool.Active = tt.active
+		if events := d.Pools([]*stats.Usage{pool}); len(events) != tt.expected {
+			t.Errorf("pools %d: expected %d events, got %d", i, tt.expected, len(events))
+		}
+	}
+}
+
+func eventStrings(types []EventType) []string {
+	var s []string
+	for _, t := range types {
+		s = append(s, string(t))
+	}
+	return s
+}
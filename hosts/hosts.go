@@ -0,0 +1,282 @@
+// Package hosts names the clients currently bound to an address so they can
+// be resolved without DDNS: as /etc/hosts and /etc/ethers lines, or as BIND
+// forward and reverse zone fragments to $INCLUDE.
+package hosts
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// Host is a named client and the addresses currently bound to it. Only v4
+// leases carry a hostname, so a client's v6 addresses are found by its MAC or
+// by the DUID it sends as its v4 client identifier.
+type Host struct {
+	Name         string           // lowercase, fully qualified if a domain is known, without the trailing dot
+	HardwareAddr net.HardwareAddr // nil if the client has none
+	Addrs        []netip.Addr
+	revNames     map[netip.Addr]string // from ddns-rev-name
+	client       string
+	seq          int // of the client's newest lease, later is newer
+}
+
+// Policy decides which of the clients claiming the same name keep it
+type Policy string
+
+const (
+	// Newest names only the client most recently leased an address
+	Newest Policy = "newest"
+	// Suffix names the others after the end of their MAC, e.g. wopr-2bec6c
+	Suffix Policy = "suffix"
+	// Omit names none of them
+	Omit Policy = "omit"
+)
+
+// ParsePolicy reads a Policy by name
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case Newest, Suffix, Omit:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %s", s)
+}
+
+// Conflict is a name claimed by several clients, newest first
+type Conflict struct {
+	Name    string
+	Clients []string // MACs, or client identifiers as hex
+	Policy  Policy
+}
+
+func (c *Conflict) String() string {
+	return fmt.Sprintf("%s claimed by %s, kept by %s", c.Name, strings.Join(c.Clients, ", "), c.Policy)
+}
+
+// Options of Collect
+type Options struct {
+	// Domain qualifies names without one, e.g. a client-hostname
+	Domain   string
+	Conflict Policy
+}
+
+// Collect names the clients of the current binding of each address, the
+// newest lease of it in file order, if that is active. A client is named by
+// its ddns-fwd-name, or else its client-hostname made a valid DNS name.
+func Collect(v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease, opts Options) ([]*Host, []*Conflict) {
+	domain := Hostname(opts.Domain)
+	hosts := map[string]*Host{}
+	byMAC := map[string]*Host{}
+	byDUID := map[string]*Host{}
+
+	for _, i := range current(len(v4leases), func(i int) (netip.Addr, string) {
+		return v4leases[i].IP, v4leases[i].BindingState
+	}) {
+		lease := &v4leases[i]
+		name := lease.DDNSFwdName
+		if name == "" {
+			name = lease.ClientHostname
+		}
+		name = Hostname(name)
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, ".") && domain != "" {
+			name += "." + domain
+		}
+		mac, hasMAC := inventory.DHCPv4HardwareAddr(lease)
+		var d *duid.DUID
+		if lease.ClientID != nil {
+			d = lease.ClientID.DUID()
+		}
+		var client string
+		switch {
+		case hasMAC:
+			client = mac.String()
+		case len(lease.UID) > 0:
+			client = hex.EncodeToString(lease.UID)
+		default:
+			client = lease.IP.String()
+		}
+		h, ok := hosts[client]
+		if !ok {
+			h = &Host{client: client, revNames: map[netip.Addr]string{}}
+			if hasMAC {
+				h.HardwareAddr = mac
+				byMAC[client] = h
+			}
+			hosts[client] = h
+		}
+		if d != nil {
+			byDUID[d.String()] = h
+		}
+		// The newest lease's name wins if the client has changed it
+		h.Name = name
+		h.seq = i
+		h.Addrs = append(h.Addrs, lease.IP)
+		if lease.DDNSRevName != "" {
+			h.revNames[lease.IP] = lease.DDNSRevName
+		}
+	}
+
+	type v6addr struct {
+		lease *dhcpd6.DHCPv6Lease
+		addr  *dhcpd6.DHCPv6LeaseAddr
+	}
+	var addrs []v6addr
+	for i := range v6leases {
+		lease := &v6leases[i]
+		if lease.Type != dhcpd6.DHCPv6LeaseTypeNonTemporary {
+			continue
+		}
+		for _, addr := range lease.Addrs {
+			addrs = append(addrs, v6addr{lease, addr})
+		}
+	}
+	for _, i := range current(len(addrs), func(i int) (netip.Addr, string) {
+		return addrs[i].addr.IP, addrs[i].addr.BindingState
+	}) {
+		lease := addrs[i].lease
+		var h *Host
+		if mac, ok := inventory.DHCPv6HardwareAddr(lease); ok {
+			h = byMAC[mac.String()]
+		}
+		if h == nil && lease.DUID != nil {
+			h = byDUID[lease.DUID.String()]
+		}
+		if h != nil {
+			h.Addrs = append(h.Addrs, addrs[i].addr.IP)
+		}
+	}
+
+	res := make([]*Host, 0, len(hosts))
+	for _, h := range hosts {
+		sort.Slice(h.Addrs, func(i, j int) bool {
+			return h.Addrs[i].Less(h.Addrs[j])
+		})
+		res = append(res, h)
+	}
+	res, conflicts := resolve(res, opts.Conflict)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, conflicts
+}
+
+// current is the index of the newest of the n leases of each address, in
+// file order, if it is active
+func current(n int, lease func(i int) (netip.Addr, string)) []int {
+	newest := map[netip.Addr]int{}
+	for i := 0; i < n; i++ {
+		ip, _ := lease(i)
+		newest[ip] = i
+	}
+	var res []int
+	for i := 0; i < n; i++ {
+		ip, state := lease(i)
+		if newest[ip] != i {
+			continue
+		}
+		switch state {
+		case "active", "bootp":
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+func resolve(hosts []*Host, policy Policy) ([]*Host, []*Conflict) {
+	if policy == "" {
+		policy = Newest
+	}
+	byName := map[string][]*Host{}
+	var names []string
+	for _, h := range hosts {
+		if _, ok := byName[h.Name]; !ok {
+			names = append(names, h.Name)
+		}
+		byName[h.Name] = append(byName[h.Name], h)
+	}
+	sort.Strings(names)
+
+	var res []*Host
+	var conflicts []*Conflict
+	for _, name := range names {
+		claims := byName[name]
+		if len(claims) == 1 {
+			res = append(res, claims[0])
+			continue
+		}
+		sort.Slice(claims, func(i, j int) bool {
+			return claims[i].seq > claims[j].seq
+		})
+		c := &Conflict{Name: name, Policy: policy}
+		for _, h := range claims {
+			c.Clients = append(c.Clients, h.client)
+		}
+		conflicts = append(conflicts, c)
+		switch policy {
+		case Newest:
+			res = append(res, claims[0])
+		case Suffix:
+			res = append(res, claims[0])
+			for _, h := range claims[1:] {
+				h.Name = suffixed(h)
+				res = append(res, h)
+			}
+		}
+	}
+	return res, conflicts
+}
+
+// suffixed appends the end of the client's MAC, or identifier, to the first
+// label of its name
+func suffixed(h *Host) string {
+	id := strings.ReplaceAll(h.client, ":", "")
+	id = strings.ReplaceAll(id, ".", "-")
+	if len(id) > 6 {
+		id = id[len(id)-6:]
+	}
+	label, domain, _ := strings.Cut(h.Name, ".")
+	label += "-" + id
+	if domain != "" {
+		label += "." + domain
+	}
+	return label
+}
+
+// Hostname lowercases s and replaces whatever isn't a letter, digit or
+// hyphen in each of its labels with a hyphen, e.g. "Joe's iPhone" becomes
+// joe-s-iphone. Empty labels and a trailing dot are dropped.
+func Hostname(s string) string {
+	var labels []string
+	for _, label := range strings.Split(strings.ToLower(s), ".") {
+		var b strings.Builder
+		hyphen := false
+		for _, r := range label {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+				b.WriteRune(r)
+				hyphen = false
+			} else if !hyphen {
+				b.WriteByte('-')
+				hyphen = true
+			}
+		}
+		label = strings.Trim(b.String(), "-")
+		if len(label) > 63 {
+			label = strings.TrimRight(label[:63], "-")
+		}
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,120 @@
+package hosts
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+func leases(t *testing.T) ([]dhcpd.DHCPv4Lease, []dhcpd6.DHCPv6Lease) {
+	d, err := duid.ParseDUIDString("00:03:00:01:20:c9:d0:a4:af:be")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4 := []dhcpd.DHCPv4Lease{
+		// Claims wopr too, but leased before it
+		{IP: netip.MustParseAddr("192.168.1.106"), BindingState: "active", HardwareEthernet: "00:aa:bb:cc:dd:ee", DDNSFwdName: "WOPR.example.com.", DDNSRevName: "106.1.168.192.in-addr.arpa."},
+		{IP: netip.MustParseAddr("192.168.1.107"), BindingState: "active", HardwareEthernet: "8c:dc:d4:2b:ec:6c", ClientHostname: "wopr"},
+		{IP: netip.MustParseAddr("192.168.1.108"), BindingState: "active", HardwareEthernet: "20:c9:d0:a4:af:be", ClientHostname: "Joe's iPhone"},
+		// Expired since, so no longer named
+		{IP: netip.MustParseAddr("192.168.1.109"), BindingState: "active", HardwareEthernet: "00:11:22:33:44:55", ClientHostname: "joshua"},
+		{IP: netip.MustParseAddr("192.168.1.109"), BindingState: "free", HardwareEthernet: "00:11:22:33:44:55", ClientHostname: "joshua"},
+		{IP: netip.MustParseAddr("192.168.1.110"), BindingState: "active", HardwareEthernet: "00:aa:bb:cc:dd:ef"},
+	}
+	v6 := []dhcpd6.DHCPv6Lease{
+		{Type: dhcpd6.DHCPv6LeaseTypeNonTemporary, DUID: d, Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8::22c9:d0ff:fea4:afbe"), BindingState: "active"},
+		}},
+		{Type: dhcpd6.DHCPv6LeaseTypePrefixDelegation, DUID: d, Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8:1::"), PrefixLen: 56, BindingState: "active"},
+		}},
+	}
+	return v4, v6
+}
+
+func TestCollect(t *testing.T) {
+	v4, v6 := leases(t)
+	for _, tt := range []struct {
+		policy    Policy
+		hosts     string
+		ethers    string
+		zone      string
+		reverse   string
+		conflicts int
+	}{
+		{
+			policy: Newest,
+			hosts: "192.168.1.108\tjoe-s-iphone.example.com joe-s-iphone\n" +
+				"2001:db8::22c9:d0ff:fea4:afbe\tjoe-s-iphone.example.com joe-s-iphone\n" +
+				"192.168.1.107\twopr.example.com wopr\n",
+			ethers: "20:c9:d0:a4:af:be\tjoe-s-iphone.example.com\n" +
+				"8c:dc:d4:2b:ec:6c\twopr.example.com\n",
+			zone: "joe-s-iphone.example.com.\t300\tIN\tA\t192.168.1.108\n" +
+				"joe-s-iphone.example.com.\t300\tIN\tAAAA\t2001:db8::22c9:d0ff:fea4:afbe\n" +
+				"wopr.example.com.\t300\tIN\tA\t192.168.1.107\n",
+			reverse: "108.1.168.192.in-addr.arpa.\t300\tIN\tPTR\tjoe-s-iphone.example.com.\n" +
+				"e.b.f.a.4.a.e.f.f.f.0.d.9.c.2.2.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\t300\tIN\tPTR\tjoe-s-iphone.example.com.\n" +
+				"107.1.168.192.in-addr.arpa.\t300\tIN\tPTR\twopr.example.com.\n",
+			conflicts: 1,
+		},
+		{
+			policy: Suffix,
+			ethers: "20:c9:d0:a4:af:be\tjoe-s-iphone.example.com\n" +
+				"00:aa:bb:cc:dd:ee\twopr-ccddee.example.com\n" +
+				"8c:dc:d4:2b:ec:6c\twopr.example.com\n",
+			reverse: "108.1.168.192.in-addr.arpa.\t300\tIN\tPTR\tjoe-s-iphone.example.com.\n" +
+				"e.b.f.a.4.a.e.f.f.f.0.d.9.c.2.2.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\t300\tIN\tPTR\tjoe-s-iphone.example.com.\n" +
+				"106.1.168.192.in-addr.arpa.\t300\tIN\tPTR\twopr-ccddee.example.com.\n" +
+				"107.1.168.192.in-addr.arpa.\t300\tIN\tPTR\twopr.example.com.\n",
+			conflicts: 1,
+		},
+		{
+			policy:    Omit,
+			ethers:    "20:c9:d0:a4:af:be\tjoe-s-iphone.example.com\n",
+			conflicts: 1,
+		},
+	} {
+		hosts, conflicts := Collect(v4, v6, Options{Domain: "example.com", Conflict: tt.policy})
+		if len(conflicts) != tt.conflicts {
+			t.Errorf("%s: expected %d conflicts, got %v", tt.policy, tt.conflicts, conflicts)
+		}
+		for _, out := range []struct {
+			name     string
+			write    func(b *bytes.Buffer) error
+			expected string
+		}{
+			{"hosts", func(b *bytes.Buffer) error { return WriteHosts(b, hosts) }, tt.hosts},
+			{"ethers", func(b *bytes.Buffer) error { return WriteEthers(b, hosts) }, tt.ethers},
+			{"zone", func(b *bytes.Buffer) error { return WriteZone(b, hosts, 300) }, tt.zone},
+			{"reverse", func(b *bytes.Buffer) error { return WriteReverseZone(b, hosts, 300) }, tt.reverse},
+		} {
+			if out.expected == "" {
+				continue
+			}
+			var b bytes.Buffer
+			if err := out.write(&b); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != out.expected {
+				t.Errorf("%s %s: expected:\n%s\ngot:\n%s", tt.policy, out.name, out.expected, b.String())
+			}
+		}
+	}
+}
+
+func TestHostname(t *testing.T) {
+	for s, expected := range map[string]string{
+		"wopr":                "wopr",
+		"Joe's iPhone":        "joe-s-iphone",
+		"-wopr-.Example.COM.": "wopr.example.com",
+		"..":                  "",
+	} {
+		if h := Hostname(s); h != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, h)
+		}
+	}
+}
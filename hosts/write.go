@@ -0,0 +1,110 @@
+package hosts
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/internal/errwriter"
+)
+
+// WriteHosts writes a line per address in the format of /etc/hosts, with the
+// first label of a qualified name as an alias
+func WriteHosts(w io.Writer, hosts []*Host) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		names := h.Name
+		if label, _, ok := strings.Cut(h.Name, "."); ok {
+			names += " " + label
+		}
+		for _, ip := range h.Addrs {
+			ew.Printf("%s\t%s\n", ip, names)
+		}
+	}
+	return ew.Err()
+}
+
+// WriteEthers writes a line per client with a MAC in the format of
+// /etc/ethers
+func WriteEthers(w io.Writer, hosts []*Host) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		if h.HardwareAddr != nil {
+			ew.Printf("%s\t%s\n", h.HardwareAddr, h.Name)
+		}
+	}
+	return ew.Err()
+}
+
+// WriteZone writes A and AAAA records. Qualified names are absolute, the rest
+// relative to the zone's origin. The records take the zone's default TTL when
+// ttl is zero.
+func WriteZone(w io.Writer, hosts []*Host, ttl int) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		owner := h.Name
+		if strings.Contains(owner, ".") {
+			owner += "."
+		}
+		for _, ip := range h.Addrs {
+			typ := "A"
+			if ip.Is6() {
+				typ = "AAAA"
+			}
+			ew.Printf("%s\t%sIN\t%s\t%s\n", owner, ttlField(ttl), typ, ip)
+		}
+	}
+	return ew.Err()
+}
+
+// WriteReverseZone writes PTR records owned by the address' ddns-rev-name or
+// else its name under in-addr.arpa or ip6.arpa. Unqualified names can't be
+// the target of a PTR and are skipped.
+func WriteReverseZone(w io.Writer, hosts []*Host, ttl int) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		if !strings.Contains(h.Name, ".") {
+			continue
+		}
+		for _, ip := range h.Addrs {
+			owner := h.revNames[ip]
+			if owner == "" {
+				owner = ReverseName(ip)
+			}
+			if !strings.HasSuffix(owner, ".") {
+				owner += "."
+			}
+			ew.Printf("%s\t%sIN\tPTR\t%s.\n", owner, ttlField(ttl), h.Name)
+		}
+	}
+	return ew.Err()
+}
+
+func ttlField(ttl int) string {
+	if ttl <= 0 {
+		return ""
+	}
+	return strconv.Itoa(ttl) + "\t"
+}
+
+// ReverseName is the name of ip under in-addr.arpa or ip6.arpa, with the
+// trailing dot
+func ReverseName(ip netip.Addr) string {
+	var b strings.Builder
+	if ip.Is4() {
+		a := ip.As4()
+		for i := len(a) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "%d.", a[i])
+		}
+		b.WriteString("in-addr.arpa.")
+		return b.String()
+	}
+	a := ip.As16()
+	for i := len(a) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", a[i]&0xf, a[i]>>4)
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
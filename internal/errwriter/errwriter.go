@@ -0,0 +1,30 @@
+// Package errwriter is shared by the writers of the output formats, which
+// check for an error once after a run of writes rather than after each.
+package errwriter
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writer keeps the first error of a run of writes, skipping those after it
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func New(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// Err is the first error written, if any
+func (w *Writer) Err() error {
+	return w.err
+}
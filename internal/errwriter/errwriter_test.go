@@ -0,0 +1,40 @@
+package errwriter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failing accepts n writes, then fails every write after
+type failing struct {
+	strings.Builder
+	n int
+}
+
+var errFull = errors.New("full")
+
+func (f *failing) Write(b []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errFull
+	}
+	f.n--
+	return f.Builder.Write(b)
+}
+
+func TestWriter(t *testing.T) {
+	f := &failing{n: 1}
+	w := New(f)
+	w.Printf("%s\n", "written")
+	if err := w.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+	w.Printf("%s\n", "failed")
+	w.Printf("%s\n", "skipped")
+	if err := w.Err(); err != errFull {
+		t.Errorf("Err() = %v, want %v", err, errFull)
+	}
+	if got := f.String(); got != "written\n" {
+		t.Errorf("wrote %q, want %q", got, "written\n")
+	}
+}
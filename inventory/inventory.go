@@ -0,0 +1,269 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/macvendors"
+)
+
+// A dual-stack client usually shows up as several unrelated records: a v4
+// lease keyed by its MAC, and one or more v6 leases keyed by a DUID. When the
+// DUID is of the LL or LLT flavor, or the assigned address carries an EUI-64
+// interface identifier, we can recover the MAC and join them back together.
+// Clients following RFC 4361 make this easier by sending their DUID in the v4
+// client identifier too.
+
+// Device is one client as seen across its DHCPv4 and DHCPv6 leases.
+type Device struct {
+	HardwareAddr string         `json:"hwaddr,omitempty"`
+	Vendor       string         `json:"vendor,omitempty"`
+	Hostnames    []string       `json:"hostnames,omitempty"`
+	V4Addrs      []netip.Addr   `json:"v4-addrs,omitempty"`
+	V6Addrs      []netip.Addr   `json:"v6-addrs,omitempty"`
+	Prefixes     []netip.Prefix `json:"prefixes,omitempty"` // delegated via ia-pd
+	DUIDs        []string       `json:"duids,omitempty"`
+}
+
+func (d *Device) addHostname(hostname string) {
+	hostname = strings.TrimSuffix(hostname, ".")
+	if hostname == "" {
+		return
+	}
+	for _, h := range d.Hostnames {
+		if strings.EqualFold(h, hostname) {
+			return
+		}
+	}
+	d.Hostnames = append(d.Hostnames, hostname)
+}
+
+func addIP(ips []netip.Addr, ip netip.Addr) []netip.Addr {
+	if !ip.IsValid() {
+		return ips
+	}
+	i := sort.Search(len(ips), func(i int) bool {
+		return !ips[i].Less(ip)
+	})
+	if i < len(ips) && ips[i] == ip {
+		return ips
+	}
+	ips = append(ips, netip.Addr{})
+	copy(ips[i+1:], ips[i:])
+	ips[i] = ip
+	return ips
+}
+
+func addPrefix(prefixes []netip.Prefix, prefix netip.Prefix) []netip.Prefix {
+	for _, p := range prefixes {
+		if p == prefix {
+			return prefixes
+		}
+	}
+	return append(prefixes, prefix)
+}
+
+func addString(ss []string, s string) []string {
+	for _, t := range ss {
+		if t == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+// Correlate joins v4 and v6 leases into devices by link-layer address, or by
+// DUID when a v4 client identifies itself with one as RFC 4361 suggests.
+// Leases with no recoverable link-layer address join the device of their
+// DUID, or become a device identified only by it.
+func Correlate(v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease) []*Device {
+	devices := map[string]*Device{}
+	duidDevices := map[string]*Device{}
+	// Every device by the DUIDs it is known by, whether or not it has a MAC
+	byDUID := map[string]*Device{}
+
+	device := func(mac net.HardwareAddr, d *duid.DUID) *Device {
+		var key string
+		if d != nil {
+			key = duidString(d)
+		}
+		if mac == nil {
+			if dev, ok := byDUID[key]; ok {
+				return dev
+			}
+			dev := &Device{}
+			if d.EN != nil {
+				dev.Vendor = d.EN.EN.Organization()
+			}
+			duidDevices[key] = dev
+			byDUID[key] = dev
+			return dev
+		}
+		dev, ok := devices[mac.String()]
+		if !ok {
+			dev = &Device{HardwareAddr: mac.String(), Vendor: Vendor(mac)}
+			devices[mac.String()] = dev
+		}
+		if d != nil {
+			if _, ok := byDUID[key]; !ok {
+				byDUID[key] = dev
+			}
+		}
+		return dev
+	}
+
+	for _, lease := range v4leases {
+		mac, ok := DHCPv4HardwareAddr(&lease)
+		var clientDUID *duid.DUID
+		if lease.ClientID != nil {
+			clientDUID = lease.ClientID.DUID()
+		}
+		var d *Device
+		switch {
+		case ok:
+			d = device(mac, clientDUID)
+		case clientDUID != nil:
+			d = device(nil, clientDUID)
+		default:
+			continue
+		}
+		if clientDUID != nil {
+			d.DUIDs = addString(d.DUIDs, duidString(clientDUID))
+		}
+		d.V4Addrs = addIP(d.V4Addrs, lease.IP)
+		d.addHostname(lease.ClientHostname)
+		d.addHostname(lease.DDNSFwdName)
+	}
+
+	for _, lease := range v6leases {
+		mac, ok := DHCPv6HardwareAddr(&lease)
+		var d *Device
+		switch {
+		case ok:
+			d = device(mac, lease.DUID)
+		case lease.DUID != nil:
+			d = device(nil, lease.DUID)
+		default:
+			continue
+		}
+		if lease.DUID != nil {
+			d.DUIDs = addString(d.DUIDs, duidString(lease.DUID))
+		}
+		for _, addr := range lease.Addrs {
+			if addr.PrefixLen != 0 {
+				d.Prefixes = addPrefix(d.Prefixes, addr.Prefix())
+			} else {
+				d.V6Addrs = addIP(d.V6Addrs, addr.IP)
+			}
+		}
+	}
+
+	res := make([]*Device, 0, len(devices)+len(duidDevices))
+	for _, d := range devices {
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].HardwareAddr < res[j].HardwareAddr
+	})
+	anonymous := make([]*Device, 0, len(duidDevices))
+	for _, d := range duidDevices {
+		anonymous = append(anonymous, d)
+	}
+	sort.Slice(anonymous, func(i, j int) bool {
+		return anonymous[i].DUIDs[0] < anonymous[j].DUIDs[0]
+	})
+	return append(res, anonymous...)
+}
+
+// Vendor names the organization a MAC is registered to, "Local" for locally
+// administered addresses or "Private" when the prefix is not in the registry.
+func Vendor(mac net.HardwareAddr) string {
+	if len(mac) == 0 {
+		return ""
+	}
+	if macvendors.IsLocal(mac) {
+		return "Local"
+	}
+	vendor := macvendors.Lookup(mac)
+	if vendor == "" {
+		return "Private"
+	}
+	return vendor
+}
+
+// DHCPv4HardwareAddr is the MAC of the client of a lease, from its hardware
+// ethernet or otherwise its client identifier.
+func DHCPv4HardwareAddr(lease *dhcpd.DHCPv4Lease) (net.HardwareAddr, bool) {
+	if lease.HardwareEthernet != "" {
+		mac, err := net.ParseMAC(lease.HardwareEthernet)
+		if err == nil {
+			return mac, true
+		}
+	}
+	if lease.ClientID != nil {
+		return lease.ClientID.HardwareAddr()
+	}
+	return nil, false
+}
+
+// DHCPv6HardwareAddr is the MAC of the client of a lease, from its DUID or
+// otherwise the EUI-64 interface identifier of one of its addresses.
+func DHCPv6HardwareAddr(lease *dhcpd6.DHCPv6Lease) (net.HardwareAddr, bool) {
+	if lease.DUID != nil {
+		var hwaddr string
+		switch {
+		case lease.DUID.LL != nil:
+			hwaddr = lease.DUID.LL.HardwareAddr
+		case lease.DUID.LLT != nil:
+			hwaddr = lease.DUID.LLT.HardwareAddr
+		}
+		if mac, err := net.ParseMAC(hwaddr); err == nil {
+			return mac, true
+		}
+	}
+	for _, addr := range lease.Addrs {
+		if mac, ok := EUI64HardwareAddr(addr.IP); ok {
+			return mac, true
+		}
+	}
+	return nil, false
+}
+
+// EUI64HardwareAddr recovers the MAC from an IPv6 address whose interface
+// identifier was formed using modified EUI-64, as SLAAC does, see:
+// https://datatracker.ietf.org/doc/html/rfc4291#appendix-A
+func EUI64HardwareAddr(ip netip.Addr) (net.HardwareAddr, bool) {
+	if !ip.Is6() || ip.Is4In6() {
+		return nil, false
+	}
+	b := ip.As16()
+	iid := b[8:]
+	if iid[3] != 0xff || iid[4] != 0xfe {
+		return nil, false
+	}
+	mac := net.HardwareAddr{iid[0] ^ 0x02, iid[1], iid[2], iid[5], iid[6], iid[7]}
+	if bytes.Equal(mac, make(net.HardwareAddr, len(mac))) {
+		return nil, false
+	}
+	return mac, true
+}
+
+func duidString(d *duid.DUID) string {
+	switch {
+	case d.LL != nil:
+		return fmt.Sprintf("%s/%s", d.Type, d.LL.HardwareAddr)
+	case d.LLT != nil:
+		return fmt.Sprintf("%s/%s", d.Type, d.LLT.HardwareAddr)
+	case d.EN != nil:
+		return fmt.Sprintf("%s/%s/%s", d.Type, d.EN.EN, d.EN.HardwareAddr)
+	default:
+		return d.Type.String()
+	}
+}
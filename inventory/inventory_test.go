@@ -0,0 +1,122 @@
+package inventory
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/clientid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+func TestEUI64HardwareAddr(t *testing.T) {
+	mac, ok := EUI64HardwareAddr(netip.MustParseAddr("2001:db8::22c9:d0ff:fea4:afbe"))
+	if !ok {
+		t.Fatal("expected EUI-64 address to yield a MAC")
+	}
+	expectedMAC := "20:c9:d0:a4:af:be"
+	if mac.String() != expectedMAC {
+		t.Errorf("expected MAC to be %s but was %s", expectedMAC, mac)
+	}
+
+	if _, ok := EUI64HardwareAddr(netip.MustParseAddr("2001:db8::1")); ok {
+		t.Error("expected non EUI-64 address to yield no MAC")
+	}
+	if _, ok := EUI64HardwareAddr(netip.MustParseAddr("192.168.1.1")); ok {
+		t.Error("expected IPv4 address to yield no MAC")
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	en := &duid.DUID{Type: duid.DUIDTypeEN, EN: &duid.DUIDEN{EN: 4, HardwareAddr: "01:02"}}
+	v4leases := []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.107"), HardwareEthernet: "20:c9:d0:a4:af:be", ClientHostname: "wopr"},
+		{
+			IP:       netip.MustParseAddr("192.168.1.108"),
+			ClientID: &clientid.ClientID{Kind: clientid.KindHardware, Hardware: &clientid.Hardware{HardwareType: duid.HardwareTypeEthernet, HardwareAddr: "8c:dc:d4:2b:ec:6c"}},
+		},
+		{
+			IP:               netip.MustParseAddr("192.168.1.109"),
+			HardwareEthernet: "b8:27:eb:00:00:01",
+			ClientID:         &clientid.ClientID{Kind: clientid.KindDUID, IAIDDUID: &duid.IAIDDUID{IAID: []byte{0, 0, 0, 1}, DUID: en}},
+		},
+	}
+	v6leases := []dhcpd6.DHCPv6Lease{
+		{
+			Type: dhcpd6.DHCPv6LeaseTypeNonTemporary,
+			DUID: &duid.DUID{Type: duid.DUIDTypeLL, LL: &duid.DUIDLL{HardwareType: duid.HardwareTypeEthernet, HardwareAddr: "20:c9:d0:a4:af:be"}},
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+				{IP: netip.MustParseAddr("2001:db8::1")},
+			},
+		},
+		{
+			Type: dhcpd6.DHCPv6LeaseTypePrefixDelegation,
+			DUID: &duid.DUID{Type: duid.DUIDTypeEN, EN: &duid.DUIDEN{EN: 4, HardwareAddr: "01:02"}},
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+				{IP: netip.MustParseAddr("2001:db8::8edc:d4ff:fe2b:ec6c")},
+				{IP: netip.MustParseAddr("2001:db8:1::"), PrefixLen: 56},
+			},
+		},
+		{
+			Type: dhcpd6.DHCPv6LeaseTypeNonTemporary,
+			DUID: en,
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+				{IP: netip.MustParseAddr("2001:db8::2")},
+			},
+		},
+	}
+
+	devices := Correlate(v4leases, v6leases)
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 devices but was %d", len(devices))
+	}
+
+	wopr := devices[0]
+	if wopr.HardwareAddr != "20:c9:d0:a4:af:be" {
+		t.Errorf("expected first device to be 20:c9:d0:a4:af:be but was %s", wopr.HardwareAddr)
+	}
+	if len(wopr.V4Addrs) != 1 || len(wopr.V6Addrs) != 1 {
+		t.Errorf("expected one v4 and one v6 address but was %v and %v", wopr.V4Addrs, wopr.V6Addrs)
+	}
+	if len(wopr.Hostnames) != 1 || wopr.Hostnames[0] != "wopr" {
+		t.Errorf("expected hostname wopr but was %v", wopr.Hostnames)
+	}
+
+	router := devices[1]
+	if router.HardwareAddr != "8c:dc:d4:2b:ec:6c" {
+		t.Errorf("expected second device to be 8c:dc:d4:2b:ec:6c but was %s", router.HardwareAddr)
+	}
+	if len(router.Prefixes) != 1 || router.Prefixes[0] != netip.MustParsePrefix("2001:db8:1::/56") {
+		t.Errorf("expected delegated prefix 2001:db8:1::/56 but was %v", router.Prefixes)
+	}
+
+	// dhcpd writes the hardware ethernet of an RFC 4361 client too
+	pi := devices[2]
+	if pi.HardwareAddr != "b8:27:eb:00:00:01" {
+		t.Errorf("expected third device to be b8:27:eb:00:00:01 but was %s", pi.HardwareAddr)
+	}
+	if len(pi.DUIDs) != 1 {
+		t.Errorf("expected device to be known by one DUID but was %v", pi.DUIDs)
+	}
+	if len(pi.V4Addrs) != 1 || len(pi.V6Addrs) != 1 {
+		t.Errorf("expected v4 client identifier to join v6 DUID but was %v and %v", pi.V4Addrs, pi.V6Addrs)
+	}
+
+	// Without one the client is known only by its DUID
+	v4leases[2].HardwareEthernet = ""
+	devices = Correlate(v4leases, v6leases)
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 devices but was %d", len(devices))
+	}
+	anonymous := devices[2]
+	if anonymous.HardwareAddr != "" {
+		t.Errorf("expected device without link-layer address but was %s", anonymous.HardwareAddr)
+	}
+	if len(anonymous.DUIDs) != 1 {
+		t.Errorf("expected device to be identified by its DUID but was %v", anonymous.DUIDs)
+	}
+	if len(anonymous.V4Addrs) != 1 || len(anonymous.V6Addrs) != 1 {
+		t.Errorf("expected v4 client identifier to join v6 DUID but was %v and %v", anonymous.V4Addrs, anonymous.V6Addrs)
+	}
+}
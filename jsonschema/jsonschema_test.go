@@ -0,0 +1,141 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+// Leases exercising each kind of client identifier and DUID
+const v4leases = `lease 192.168.1.107 {
+  starts 6 2021/12/25 22:27:49;
+  ends 6 2021/12/25 22:34:37;
+  cltt 6 2021/12/25 22:24:37;
+  binding state active;
+  next binding state free;
+  rewind binding state free;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+  uid "\001\214\334\324+\354l";
+  set vendor-class-identifier = "MSFT 5.0";
+  client-hostname "wopr";
+}
+lease 192.168.1.108 {
+  starts 6 2021/12/25 22:27:49;
+  ends 6 2021/12/25 22:34:37;
+  tstp 6 2021/12/26 05:36:57;
+  binding state free;
+  hardware ethernet 20:c9:d0:a4:af:be;
+  uid "\377\320\244\257\276\000\003\000\001 \311\320\244\257\276";
+}
+lease 192.168.1.109 {
+  binding state backup;
+  uid "joshua";
+}
+`
+
+const v6leases = `ia-na "\276\257\244\320\000\003\000\001 \311\320\244\257\276" {
+  cltt 6 2021/12/25 22:24:37;
+  iaaddr 2001:db8::1:22c9:d0ff:fea4:afbe {
+    binding state active;
+    preferred-life 375;
+    max-life 600;
+    ends 6 2021/12/25 22:34:37;
+  }
+}
+ia-pd "\001\000\000\000\000\001\000\001\034\242\263\200\214\334\324+\354l" {
+  cltt 6 2021/12/25 22:24:37;
+  iaprefix 2001:db8:1::/56 {
+    binding state active;
+    preferred-life 375;
+    max-life 600;
+    ends 6 2021/12/25 22:34:37;
+  }
+}
+ia-ta "\001\000\000\000\000\002\000\000\000\011\014\300\204\323\003\000\011\022" {
+  cltt 6 2021/12/25 22:24:37;
+}
+`
+
+func TestDHCPv4Lease(t *testing.T) {
+	schema := For(dhcpd.DHCPv4Lease{})
+	leases, errc := dhcpd.Parse(strings.NewReader(v4leases))
+	var n int
+	for lease := range leases {
+		n++
+		validate(t, schema, lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 leases, got %d", n)
+	}
+}
+
+func TestDHCPv6Lease(t *testing.T) {
+	schema := For(dhcpd6.DHCPv6Lease{})
+	leases, errc := dhcpd6.Parse(strings.NewReader(v6leases))
+	var n int
+	for lease := range leases {
+		n++
+		validate(t, schema, lease)
+		if lease.DUID != nil {
+			validate(t, For(duid.DUID{}), lease.DUID)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 leases, got %d", n)
+	}
+}
+
+func validate(t *testing.T, schema *Schema, v interface{}) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(b); err != nil {
+		t.Errorf("%s: %v", b, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type outer struct {
+		Count  int               `json:"count"`
+		Inner  *inner            `json:"inner"`
+		Tags   []string          `json:"tags,omitempty"`
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	schema := For(outer{})
+	for _, tt := range []struct {
+		doc string
+		ok  bool
+	}{
+		{`{"count": 1, "inner": {"name": "wopr"}}`, true},
+		{`{"count": 1, "inner": null, "tags": ["a"], "labels": {"a": "b"}}`, true},
+		{`{"inner": null}`, false},
+		{`{"count": 1.5, "inner": null}`, false},
+		{`{"count": 1, "inner": {"name": 1}}`, false},
+		{`{"count": 1, "inner": {}}`, false},
+		{`{"count": 1, "inner": null, "color": "red"}`, false},
+		{`{"count": 1, "inner": null, "labels": {"a": 1}}`, false},
+	} {
+		err := schema.Validate([]byte(tt.doc))
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.doc, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: expected an error", tt.doc)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package jsonschema generates JSON Schemas (draft 2020-12, as OpenAPI 3.1
+// uses) describing what encoding/json produces for Go types, by reflecting
+// over their fields and struct tags, and validates JSON against them.
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema the generator produces
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Type        Types              `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// Either false or a *Schema
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Types is the type keyword, a string when there's one
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = Types{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// Describer is implemented by types which marshal themselves, and so can't
+// be described by reflection.
+type Describer interface {
+	JSONSchema(g *Generator) *Schema
+}
+
+// Generator describes types, defining each named struct once and referring
+// to it elsewhere.
+type Generator struct {
+	// Prefix of references to the definitions
+	RefPrefix string
+	Defs      map[string]*Schema
+	names     map[reflect.Type]string
+}
+
+// NewGenerator refers to definitions by refPrefix, "#/$defs/" when they're
+// to be included in the schema or "#/components/schemas/" in OpenAPI.
+func NewGenerator(refPrefix string) *Generator {
+	return &Generator{RefPrefix: refPrefix, Defs: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// For is a standalone schema of the type of v, with its definitions
+func For(v interface{}) *Schema {
+	g := NewGenerator("#/$defs/")
+	s := g.Schema(reflect.TypeOf(v))
+	s.Schema = Draft
+	s.Defs = g.Defs
+	return s
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	describerType     = reflect.TypeOf((*Describer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schema describes t, which may be null when it's a pointer, slice or map
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		return nullable(g.Schema(t.Elem()))
+	}
+	switch {
+	case t.Implements(describerType):
+		return reflect.Zero(t).Interface().(Describer).JSONSchema(g)
+	case reflect.PtrTo(t).Implements(describerType):
+		return reflect.New(t).Interface().(Describer).JSONSchema(g)
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: Types{"string"}}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nullable(&Schema{Type: Types{"string"}, Format: "byte"})
+		}
+		return nullable(&Schema{Type: Types{"array"}, Items: g.Schema(t.Elem())})
+	case reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return nullable(&Schema{Type: Types{"object"}, AdditionalProperties: g.Schema(t.Elem())})
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.Struct(t)
+		}
+		return g.define(t)
+	}
+	// Interfaces could be anything
+	return &Schema{}
+}
+
+// define refers to the definition of t, adding it the first time
+func (g *Generator) define(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.Defs[name]; taken {
+			// The same name from another package, e.g. jsonv2.DUID
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		g.names[t] = name
+		g.Defs[name] = nil // for recursive types
+		g.Defs[name] = g.Struct(t)
+	}
+	return &Schema{Ref: g.RefPrefix + name}
+}
+
+// Struct describes the fields of t as encoding/json sees them. Fields
+// without omitempty are required, and no others are allowed.
+func (g *Generator) Struct(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}, AdditionalProperties: false}
+	g.fields(s, t)
+	return s
+}
+
+func (g *Generator) fields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			// Embedded structs' fields are promoted
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.Schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// nullable allows null as well, as encoding/json writes nil pointers, slices
+// and maps.
+func nullable(s *Schema) *Schema {
+	switch {
+	case len(s.Type) > 0:
+		for _, t := range s.Type {
+			if t == "null" {
+				return s
+			}
+		}
+		s.Type = append(s.Type, "null")
+		return s
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: Types{"null"}}}}
+	}
+	// Anything includes null
+	return s
+}
@@ -0,0 +1,148 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validate checks the JSON document b against s, resolving references to
+// s.Defs. Only the keywords the generator produces are understood.
+func (s *Schema) Validate(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	return s.validate(s, v, "")
+}
+
+func (s *Schema) validate(root *Schema, v interface{}, at string) error {
+	if s.Ref != "" {
+		name := s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+		def := root.Defs[name]
+		if def == nil {
+			return fmt.Errorf("%s: unknown reference %s", location(at), s.Ref)
+		}
+		if err := def.validate(root, v, at); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		var errs []string
+		for _, alt := range s.AnyOf {
+			err := alt.validate(root, v, at)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if errs != nil {
+			return fmt.Errorf("%s: matches none of: %s", location(at), strings.Join(errs, "; "))
+		}
+	}
+	if len(s.Type) > 0 && !s.Type.match(v) {
+		return fmt.Errorf("%s: expected %s, got %s", location(at), strings.Join(s.Type, " or "), typeOf(v))
+	}
+	switch v := v.(type) {
+	case string:
+		return s.validateFormat(v, at)
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(root, item, fmt.Sprintf("%s/%d", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %s", location(at), name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				switch additional := s.AdditionalProperties.(type) {
+				case bool:
+					if !additional {
+						return fmt.Errorf("%s: unexpected property %s", location(at), name)
+					}
+				case *Schema:
+					prop = additional
+				}
+			}
+			if prop != nil {
+				if err := prop.validate(root, v[name], at+"/"+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateFormat(v string, at string) error {
+	var err error
+	switch s.Format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(v)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: expected %s: %w", location(at), s.Format, err)
+	}
+	return nil
+}
+
+func (t Types) match(v interface{}) bool {
+	actual := typeOf(v)
+	for _, typ := range t {
+		if typ == actual || typ == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil || !strings.ContainsAny(v.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// location is a JSON pointer to the value
+func location(at string) string {
+	if at == "" {
+		return "/"
+	}
+	return at
+}
@@ -0,0 +1,120 @@
+package jsonv2
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonschema"
+)
+
+func TestDHCPv4Lease(t *testing.T) {
+	starts := time.Date(2021, time.December, 25, 22, 27, 49, 0, time.UTC)
+	lease := &dhcpd.DHCPv4Lease{
+		IP:               netip.MustParseAddr("192.168.1.107"),
+		Starts:           &starts,
+		HardwareEthernet: "8C:DC:D4:2B:EC:6C",
+		UID:              []byte{1, 0x8c, 0xdc, 0xd4, 0x2b, 0xec, 0x6c},
+	}
+
+	b, err := json.Marshal(FromDHCPv4Lease(lease))
+	if err != nil {
+		t.Fatalf("marshal lease: %v", err)
+	}
+
+	expected := `{"ip":"192.168.1.107","starts":"2021-12-25T22:27:49Z","hardware-ethernet":"8c:dc:d4:2b:ec:6c","uid":"018cdcd42bec6c"}`
+	if string(b) != expected {
+		t.Errorf("expected %s but was %s", expected, b)
+	}
+
+	var roundtrip DHCPv4Lease
+	if err := json.Unmarshal(b, &roundtrip); err != nil {
+		t.Fatalf("unmarshal lease: %v", err)
+	}
+	if net.HardwareAddr(roundtrip.HardwareEthernet).String() != "8c:dc:d4:2b:ec:6c" {
+		t.Errorf("expected hardware ethernet to round trip but was %v", roundtrip.HardwareEthernet)
+	}
+
+	lease.HardwareEthernet = "not a mac"
+	if v2 := FromDHCPv4Lease(lease); v2.HardwareEthernet != nil || len(v2.Warnings) != 1 || v2.IP != lease.IP {
+		t.Errorf("expected malformed hardware ethernet to be left out with a warning, got %+v", v2)
+	}
+}
+
+func TestDHCPv6Lease(t *testing.T) {
+	d, err := duid.ParseDUIDString("00:03:00:01:20:c9:d0:a4:af:be")
+	if err != nil {
+		t.Fatalf("parse duid: %v", err)
+	}
+	lease := &dhcpd6.DHCPv6Lease{
+		Type: dhcpd6.DHCPv6LeaseTypeNonTemporary,
+		IAID: []byte{0xbe, 0xaf, 0xa4, 0xd0},
+		DUID: d,
+	}
+
+	b, err := json.Marshal(FromDHCPv6Lease(lease))
+	if err != nil {
+		t.Fatalf("marshal lease: %v", err)
+	}
+
+	for _, field := range []string{
+		`"iaid":"beafa4d0"`,
+		`"duid":"00:03:00:01:20:c9:d0:a4:af:be"`,
+		`"type":"DUID-LL"`,
+		`"hwaddr":"20:c9:d0:a4:af:be"`,
+	} {
+		if !strings.Contains(string(b), field) {
+			t.Errorf("expected %s in %s", field, b)
+		}
+	}
+
+	var roundtrip DHCPv6Lease
+	if err := json.Unmarshal(b, &roundtrip); err != nil {
+		t.Fatalf("unmarshal lease: %v", err)
+	}
+	if roundtrip.DUID == nil || roundtrip.DUID.LL == nil || roundtrip.DUID.LL.HardwareAddr != "20:c9:d0:a4:af:be" {
+		t.Errorf("expected duid to round trip but was %+v", roundtrip.DUID)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	starts := time.Date(2021, time.December, 25, 22, 27, 49, 0, time.UTC)
+	v4lease := FromDHCPv4Lease(&dhcpd.DHCPv4Lease{
+		IP:               netip.MustParseAddr("192.168.1.107"),
+		Starts:           &starts,
+		HardwareEthernet: "8c:dc:d4:2b:ec:6c",
+		UID:              []byte{0xff, 0xd0, 0xa4, 0xaf, 0xbe, 0, 3, 0, 1, 0x20, 0xc9, 0xd0, 0xa4, 0xaf, 0xbe},
+	})
+	leases := Leases{Schema: Schema, DHCPv4Leases: []*DHCPv4Lease{v4lease}}
+	for _, s := range []string{
+		"00:03:00:01:20:c9:d0:a4:af:be",
+		"00:01:00:01:1c:a2:b3:80:8c:dc:d4:2b:ec:6c",
+		"00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12",
+	} {
+		d, err := duid.ParseDUIDString(s)
+		if err != nil {
+			t.Fatalf("parse duid: %v", err)
+		}
+		leases.DHCPv6Leases = append(leases.DHCPv6Leases, FromDHCPv6Lease(&dhcpd6.DHCPv6Lease{
+			Type:  dhcpd6.DHCPv6LeaseTypeNonTemporary,
+			IAID:  []byte{0xbe, 0xaf, 0xa4, 0xd0},
+			DUID:  d,
+			CLTT:  &starts,
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{{IP: netip.MustParseAddr("2001:db8::1"), Ends: &starts}},
+		}))
+	}
+
+	b, err := json.Marshal(leases)
+	if err != nil {
+		t.Fatalf("marshal leases: %v", err)
+	}
+	if err := jsonschema.For(leases).Validate(b); err != nil {
+		t.Errorf("%s: %v", b, err)
+	}
+}
@@ -0,0 +1,145 @@
+package jsonv2
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/clientid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+const Schema = "v2"
+
+type ClientID struct {
+	Hex      Hex                `json:"hex"`
+	Kind     clientid.Kind      `json:"kind"`
+	Hardware *clientid.Hardware `json:"hardware,omitempty"`
+	IAID     Hex                `json:"iaid,omitempty"`
+	DUID     *DUID              `json:"duid,omitempty"`
+	Text     string             `json:"text,omitempty"`
+}
+
+type DHCPv4Lease struct {
+	IP                    netip.Addr `json:"ip"`
+	Starts                *Time      `json:"starts,omitempty"`
+	Ends                  *Time      `json:"ends,omitempty"`
+	TSTP                  *Time      `json:"tstp,omitempty"`
+	TSFP                  *Time      `json:"tsfp,omitempty"`
+	ATSFP                 *Time      `json:"atsfp,omitempty"`
+	CLTT                  *Time      `json:"cltt,omitempty"`
+	BindingState          string     `json:"binding-state,omitempty"`
+	NextBindingState      string     `json:"next-binding-state,omitempty"`
+	RewindBindingState    string     `json:"rewind-binding-state,omitempty"`
+	HardwareEthernet      MAC        `json:"hardware-ethernet,omitempty"`
+	ClientHostname        string     `json:"client-hostname,omitempty"`
+	UID                   Hex        `json:"uid,omitempty"`
+	ClientID              *ClientID  `json:"client-id,omitempty"`
+	VendorClassIdentifier string     `json:"vendor-class-identifier,omitempty"`
+	DDNSFwdName           string     `json:"ddns-fwd-name,omitempty"`
+	DDNSTxt               string     `json:"ddns-txt,omitempty"`
+	DDNSRevName           string     `json:"ddns-rev-name,omitempty"`
+	Source                string     `json:"source,omitempty"`
+	Site                  string     `json:"site,omitempty"`
+	// Of the fields which couldn't be converted, and are left out
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type DHCPv6LeaseAddr struct {
+	IP            netip.Addr `json:"ip"`
+	PrefixLen     int        `json:"prefix-len,omitempty"`
+	BindingState  string     `json:"binding-state,omitempty"`
+	PreferredLife int        `json:"preferred-life,omitempty"`
+	MaxLife       int        `json:"max-life,omitempty"`
+	Ends          *Time      `json:"ends,omitempty"`
+}
+
+type DHCPv6Lease struct {
+	Type   dhcpd6.DHCPv6LeaseType `json:"type"`
+	IAID   Hex                    `json:"iaid"`
+	DUID   *DUID                  `json:"duid"`
+	CLTT   *Time                  `json:"cltt,omitempty"`
+	Addrs  []*DHCPv6LeaseAddr     `json:"addrs,omitempty"`
+	Source string                 `json:"source,omitempty"`
+	Site   string                 `json:"site,omitempty"`
+}
+
+// Leases is the versioned envelope for a collection of leases
+type Leases struct {
+	Schema       string         `json:"schema"`
+	DHCPv4Leases []*DHCPv4Lease `json:"v4-leases"`
+	DHCPv6Leases []*DHCPv6Lease `json:"v6-leases"`
+}
+
+// FromDHCPv4Lease converts a lease to the v2 schema. A malformed hardware
+// address, which dhcpd accepts, is left out with a warning.
+func FromDHCPv4Lease(lease *dhcpd.DHCPv4Lease) *DHCPv4Lease {
+	res := &DHCPv4Lease{
+		IP:                    lease.IP,
+		Starts:                newTime(lease.Starts),
+		Ends:                  newTime(lease.Ends),
+		TSTP:                  newTime(lease.TSTP),
+		TSFP:                  newTime(lease.TSFP),
+		ATSFP:                 newTime(lease.ATSFP),
+		CLTT:                  newTime(lease.CLTT),
+		BindingState:          lease.BindingState,
+		NextBindingState:      lease.NextBindingState,
+		RewindBindingState:    lease.RewindBindingState,
+		ClientHostname:        lease.ClientHostname,
+		UID:                   Hex(lease.UID),
+		VendorClassIdentifier: lease.VendorClassIdentifier,
+		DDNSFwdName:           lease.DDNSFwdName,
+		DDNSTxt:               lease.DDNSTxt,
+		DDNSRevName:           lease.DDNSRevName,
+		Source:                lease.Source,
+		Site:                  lease.Site,
+	}
+	if lease.HardwareEthernet != "" {
+		hw, err := net.ParseMAC(lease.HardwareEthernet)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("hardware ethernet: %v", err))
+		} else {
+			res.HardwareEthernet = MAC(hw)
+		}
+	}
+	if cid := lease.ClientID; cid != nil {
+		res.ClientID = &ClientID{
+			Hex:      Hex(lease.UID),
+			Kind:     cid.Kind,
+			Hardware: cid.Hardware,
+			Text:     cid.Text,
+		}
+		if cid.IAIDDUID != nil {
+			res.ClientID.IAID = Hex(cid.IAIDDUID.IAID)
+			res.ClientID.DUID = (*DUID)(cid.IAIDDUID.DUID)
+		}
+	}
+	return res
+}
+
+func FromDHCPv6Lease(lease *dhcpd6.DHCPv6Lease) *DHCPv6Lease {
+	res := &DHCPv6Lease{
+		Type:   lease.Type,
+		IAID:   Hex(lease.IAID),
+		DUID:   (*DUID)(lease.DUID),
+		CLTT:   newTime(lease.CLTT),
+		Source: lease.Source,
+		Site:   lease.Site,
+	}
+	for _, addr := range lease.Addrs {
+		res.Addrs = append(res.Addrs, FromDHCPv6LeaseAddr(addr))
+	}
+	return res
+}
+
+func FromDHCPv6LeaseAddr(addr *dhcpd6.DHCPv6LeaseAddr) *DHCPv6LeaseAddr {
+	return &DHCPv6LeaseAddr{
+		IP:            addr.IP,
+		PrefixLen:     addr.PrefixLen,
+		BindingState:  addr.BindingState,
+		PreferredLife: addr.PreferredLife,
+		MaxLife:       addr.MaxLife,
+		Ends:          newTime(addr.Ends),
+	}
+}
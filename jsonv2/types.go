@@ -0,0 +1,137 @@
+package jsonv2
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/enterprisenumbers"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/jsonschema"
+)
+
+// The v1 schema is whatever encoding/json does with the lease model, which
+// means base64 for bytes and whichever zone a time happened to be parsed in.
+// These types give each value a single textual form instead.
+
+// MAC serializes as colon separated hex, e.g. 8c:dc:d4:2b:ec:6c
+type MAC net.HardwareAddr
+
+func (m MAC) MarshalText() ([]byte, error) {
+	return []byte(net.HardwareAddr(m).String()), nil
+}
+
+func (m *MAC) UnmarshalText(text []byte) error {
+	hw, err := net.ParseMAC(string(text))
+	if err != nil {
+		return err
+	}
+	*m = MAC(hw)
+	return nil
+}
+
+// Hex serializes as unseparated hex, e.g. 018cdcd42bec6c
+type Hex []byte
+
+func (h Hex) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+func (h *Hex) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*h = Hex(b)
+	return nil
+}
+
+// Time serializes as RFC 3339 in UTC, e.g. 2021-12-25T22:27:49Z
+type Time time.Time
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t).UTC().Format(time.RFC3339))
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	*t = Time(parsed.UTC())
+	return nil
+}
+
+// JSONSchema describes the encoding of Time, see the jsonschema package
+func (t Time) JSONSchema(g *jsonschema.Generator) *jsonschema.Schema {
+	return &jsonschema.Schema{Type: jsonschema.Types{"string"}, Format: "date-time"}
+}
+
+func newTime(t *time.Time) *Time {
+	if t == nil {
+		return nil
+	}
+	return (*Time)(t)
+}
+
+// DUID serializes as its canonical colon separated hex alongside the fields
+// decoded from it, only the former is needed to unmarshal it.
+type DUID duid.DUID
+
+type duidJSON struct {
+	DUID         string               `json:"duid"`
+	Type         string               `json:"type"`
+	HardwareType duid.HardwareType    `json:"hwtype,omitempty"`
+	HardwareAddr string               `json:"hwaddr,omitempty"`
+	Time         *Time                `json:"time,omitempty"`
+	EN           enterprisenumbers.EN `json:"en,omitempty"`
+	Organization string               `json:"organization,omitempty"`
+}
+
+func (d *DUID) MarshalJSON() ([]byte, error) {
+	dd := (*duid.DUID)(d)
+	j := duidJSON{
+		DUID: dd.String(),
+		Type: dd.Type.String(),
+	}
+	switch {
+	case dd.LLT != nil:
+		t := Time(dd.LLT.Time)
+		j.HardwareType = dd.LLT.HardwareType
+		j.HardwareAddr = dd.LLT.HardwareAddr
+		j.Time = &t
+	case dd.EN != nil:
+		j.EN = dd.EN.EN
+		j.Organization = dd.EN.EN.Organization()
+		j.HardwareAddr = dd.EN.HardwareAddr
+	case dd.LL != nil:
+		j.HardwareType = dd.LL.HardwareType
+		j.HardwareAddr = dd.LL.HardwareAddr
+	}
+	return json.Marshal(j)
+}
+
+// JSONSchema describes the encoding of DUID, see the jsonschema package
+func (d *DUID) JSONSchema(g *jsonschema.Generator) *jsonschema.Schema {
+	return g.Struct(reflect.TypeOf(duidJSON{}))
+}
+
+func (d *DUID) UnmarshalJSON(b []byte) error {
+	var j duidJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	parsed, err := duid.ParseDUIDString(j.DUID)
+	if err != nil {
+		return fmt.Errorf("parse duid %s: %w", j.DUID, err)
+	}
+	*d = DUID(*parsed)
+	return nil
+}
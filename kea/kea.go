@@ -0,0 +1,362 @@
+// Package kea converts leases to the CSV files of Kea's memfile lease
+// backend, kea-leases4.csv and kea-leases6.csv, so clients keep their
+// addresses when moving from ISC dhcpd to Kea, and reads them back as the
+// leases of dhcpd so both servers can be shown together. See:
+// https://kea.readthedocs.io/en/latest/arm/dhcp4-srv.html#memfile-basic-storage-for-leases
+package kea
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/internal/errwriter"
+)
+
+// The columns of the lease files as of Kea 2.0, whose schema later versions
+// upgrade when loading them
+var (
+	DHCPv4Header = []string{"address", "hwaddr", "client_id", "valid_lifetime", "expire", "subnet_id", "fqdn_fwd", "fqdn_rev", "hostname", "state", "user_context"}
+	DHCPv6Header = []string{"address", "duid", "valid_lifetime", "expire", "subnet_id", "pref_lifetime", "lease_type", "iaid", "prefix_len", "fqdn_fwd", "fqdn_rev", "hostname", "hwaddr", "state", "user_context", "hwtype", "hwaddr_source"}
+)
+
+// Kea's lease states
+const (
+	stateDefault          = 0
+	stateDeclined         = 1
+	stateExpiredReclaimed = 2
+)
+
+// infinite is Kea's valid lifetime of a lease which never expires
+const infinite = 0xffffffff
+
+// hwaddrSourceDUID says a v6 lease's MAC was taken from the client's DUID
+const hwaddrSourceDUID = 2
+
+// state is the Kea state of the binding state of a lease. Free and backup
+// addresses aren't leases to Kea, so aren't converted.
+func state(bindingState string) (int, bool) {
+	switch bindingState {
+	case "active", "bootp":
+		return stateDefault, true
+	case "abandoned":
+		return stateDeclined, true
+	case "expired", "released":
+		return stateExpiredReclaimed, true
+	}
+	return 0, false
+}
+
+// Skipped counts the leases which weren't converted, by why
+type Skipped struct {
+	// Free and backup addresses, which aren't leases to Kea
+	Unleased int
+	// v6 leases without a DUID, which Kea requires
+	WithoutDUID int
+}
+
+func (s Skipped) String() string {
+	return fmt.Sprintf("%d free or backup addresses, %d v6 leases without a DUID", s.Unleased, s.WithoutDUID)
+}
+
+// Subnets maps addresses to the ids of Kea subnets
+type Subnets struct {
+	conf *dhcpdconf.Config
+	ids  map[netip.Prefix]int
+}
+
+// NewSubnets numbers the subnets of conf from 1 in the order Walk visits
+// them, as Kea requires subnets to have ids. conf may be nil, leaving ids to
+// Set.
+func NewSubnets(conf *dhcpdconf.Config) *Subnets {
+	s := &Subnets{conf: conf, ids: map[netip.Prefix]int{}}
+	if conf == nil {
+		return s
+	}
+	conf.Walk(func(scope dhcpdconf.Scope) {
+		if scope.Range == nil && scope.Prefix6 == nil && scope.Subnet != nil {
+			if _, ok := s.ids[scope.Subnet.Prefix]; !ok {
+				s.ids[scope.Subnet.Prefix] = len(s.ids) + 1
+			}
+		}
+	})
+	return s
+}
+
+// Set gives a subnet the id of the Kea subnet it becomes
+func (s *Subnets) Set(prefix netip.Prefix, id int) {
+	s.ids[prefix.Masked()] = id
+}
+
+// Parse sets the ids of subnets from a comma separated list of
+// prefix=id, e.g. 192.168.1.0/24=1,2001:db8::/64=2
+func (s *Subnets) Parse(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		prefix, id, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("subnet id %s: expected prefix=id", pair)
+		}
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return fmt.Errorf("subnet id %s: %w", pair, err)
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 1 {
+			return fmt.Errorf("subnet id %s: expected a positive integer id", pair)
+		}
+		s.Set(p, n)
+	}
+	return nil
+}
+
+// ID is the id of the subnet of addr, from the subnet of dhcpd.conf it was
+// leased from or otherwise the longest prefix containing it, or 0 if unknown.
+// Delegated prefixes are looked up by their first address.
+func (s *Subnets) ID(addr netip.Addr) int {
+	if s.conf != nil {
+		if scope, ok := s.conf.Lookup(addr); ok && scope.Subnet != nil {
+			if id, ok := s.ids[scope.Subnet.Prefix.Masked()]; ok {
+				return id
+			}
+		}
+	}
+	var id, bits int
+	for prefix, n := range s.ids {
+		if prefix.Contains(addr) && (id == 0 || prefix.Bits() > bits) {
+			id, bits = n, prefix.Bits()
+		}
+	}
+	return id
+}
+
+// escape replaces the commas of a value with the entity Kea unescapes, its
+// CSV files having no quoting
+func escape(s string) string {
+	return strings.ReplaceAll(s, ",", "&#x2c")
+}
+
+// row writes a line of the CSV file
+func row(ew *errwriter.Writer, values []string) {
+	ew.Printf("%s\n", strings.Join(values, ","))
+}
+
+func boolean(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// unix is the time in seconds since the epoch, or 0 if it is unknown
+func unix(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// WriteDHCPv4 writes kea-leases4.csv from the leases, given in file order,
+// converting the newest lease of each address. An address' lifetime is from
+// when the lease starts until it ends, which dhcpd omits when it never does.
+// The newest leases which aren't converted are counted. A lease without a
+// subnet id is an error, Kea requiring one.
+func WriteDHCPv4(w io.Writer, leases []dhcpd.DHCPv4Lease, subnets *Subnets) (Skipped, error) {
+	var skipped Skipped
+	ew := errwriter.New(w)
+	row(ew, DHCPv4Header)
+	for _, i := range newest(len(leases), func(i int) netip.Addr { return leases[i].IP }) {
+		lease := &leases[i]
+		st, ok := state(lease.BindingState)
+		if !ok {
+			skipped.Unleased++
+			continue
+		}
+		subnetID := subnets.ID(lease.IP)
+		if subnetID == 0 {
+			return skipped, fmt.Errorf("lease %s: no subnet id", lease.IP)
+		}
+		var hwaddr string
+		if mac, err := net.ParseMAC(lease.HardwareEthernet); err == nil {
+			hwaddr = mac.String()
+		}
+		var clientID string
+		if len(lease.UID) > 0 {
+			clientID = net.HardwareAddr(lease.UID).String()
+		}
+		cltt := lease.CLTT
+		if lease.Starts != nil {
+			cltt = lease.Starts
+		}
+		var valid int64 = infinite
+		var expire time.Time
+		switch {
+		case lease.Ends != nil && cltt != nil:
+			valid = int64(lease.Ends.Sub(*cltt) / time.Second)
+			expire = *lease.Ends
+		case lease.Ends != nil:
+			valid = 0
+			expire = *lease.Ends
+		case cltt != nil:
+			expire = cltt.Add(infinite * time.Second)
+		}
+		hostname := strings.TrimSuffix(lease.DDNSFwdName, ".")
+		if hostname == "" {
+			hostname = lease.ClientHostname
+		}
+		row(ew, []string{
+			lease.IP.String(),
+			hwaddr,
+			clientID,
+			strconv.FormatInt(valid, 10),
+			unix(expire),
+			strconv.Itoa(subnetID),
+			boolean(lease.DDNSFwdName != ""),
+			boolean(lease.DDNSRevName != ""),
+			escape(hostname),
+			strconv.Itoa(st),
+			"",
+		})
+	}
+	return skipped, ew.Err()
+}
+
+// WriteDHCPv6 writes kea-leases6.csv from the leases, given in file order,
+// converting the newest binding of each address and delegated prefix. The
+// newest bindings which aren't converted are counted. A binding without a
+// subnet id is an error, Kea requiring one.
+func WriteDHCPv6(w io.Writer, leases []dhcpd6.DHCPv6Lease, subnets *Subnets) (Skipped, error) {
+	type binding struct {
+		lease *dhcpd6.DHCPv6Lease
+		addr  *dhcpd6.DHCPv6LeaseAddr
+	}
+	var bindings []binding
+	for i := range leases {
+		for _, addr := range leases[i].Addrs {
+			bindings = append(bindings, binding{&leases[i], addr})
+		}
+	}
+
+	var skipped Skipped
+	ew := errwriter.New(w)
+	row(ew, DHCPv6Header)
+	for _, i := range newest(len(bindings), func(i int) netip.Prefix { return bindings[i].addr.Prefix() }) {
+		lease, addr := bindings[i].lease, bindings[i].addr
+		st, ok := state(addr.BindingState)
+		switch {
+		case !ok:
+			skipped.Unleased++
+			continue
+		case lease.DUID == nil:
+			skipped.WithoutDUID++
+			continue
+		}
+		subnetID := subnets.ID(addr.IP)
+		if subnetID == 0 {
+			return skipped, fmt.Errorf("lease %s: no subnet id", addr.Prefix())
+		}
+		var leaseType string
+		switch lease.Type {
+		case dhcpd6.DHCPv6LeaseTypeNonTemporary:
+			leaseType = "0"
+		case dhcpd6.DHCPv6LeaseTypeTemporary:
+			leaseType = "1"
+		case dhcpd6.DHCPv6LeaseTypePrefixDelegation:
+			leaseType = "2"
+		}
+		prefixLen := addr.PrefixLen
+		if prefixLen == 0 {
+			prefixLen = 128
+		}
+		var expire time.Time
+		switch {
+		case addr.Ends != nil:
+			expire = *addr.Ends
+		case lease.CLTT != nil:
+			expire = lease.CLTT.Add(time.Duration(addr.MaxLife) * time.Second)
+		}
+		var hwaddr string
+		var hwaddrSource int
+		if hwaddr = duidHardwareAddr(lease); hwaddr != "" {
+			hwaddrSource = hwaddrSourceDUID
+		}
+		row(ew, []string{
+			addr.IP.String(),
+			lease.DUID.String(),
+			strconv.Itoa(addr.MaxLife),
+			unix(expire),
+			strconv.Itoa(subnetID),
+			strconv.Itoa(addr.PreferredLife),
+			leaseType,
+			strconv.FormatUint(uint64(iaid(lease)), 10),
+			strconv.Itoa(prefixLen),
+			"0",
+			"0",
+			"",
+			hwaddr,
+			strconv.Itoa(st),
+			"",
+			"1", // Ethernet
+			strconv.Itoa(hwaddrSource),
+		})
+	}
+	return skipped, ew.Err()
+}
+
+// iaid decodes the IAID of a lease, which dhcpd writes in its own byte
+// order, see authoring-byte-order in dhcpd.leases(5). It's assumed to be
+// little-endian when the file doesn't say.
+func iaid(lease *dhcpd6.DHCPv6Lease) uint32 {
+	if len(lease.IAID) != 4 {
+		return 0
+	}
+	if lease.ByteOrder == "big-endian" {
+		return binary.BigEndian.Uint32(lease.IAID)
+	}
+	return binary.LittleEndian.Uint32(lease.IAID)
+}
+
+// duidHardwareAddr is the MAC of a DUID-LL or DUID-LLT
+func duidHardwareAddr(lease *dhcpd6.DHCPv6Lease) string {
+	var hwaddr string
+	switch {
+	case lease.DUID.LL != nil:
+		hwaddr = lease.DUID.LL.HardwareAddr
+	case lease.DUID.LLT != nil:
+		hwaddr = lease.DUID.LLT.HardwareAddr
+	}
+	mac, err := net.ParseMAC(hwaddr)
+	if err != nil {
+		return ""
+	}
+	return mac.String()
+}
+
+// newest is the index of the newest of the n leases of each address, or
+// prefix, in the order they first appear
+func newest[K comparable](n int, key func(i int) K) []int {
+	index := map[K]int{}
+	var order []K
+	for i := 0; i < n; i++ {
+		k := key(i)
+		if _, ok := index[k]; !ok {
+			order = append(order, k)
+		}
+		index[k] = i
+	}
+	res := make([]int, 0, len(order))
+	for _, k := range order {
+		res = append(res, index[k])
+	}
+	return res
+}
@@ -0,0 +1,221 @@
+package kea
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+)
+
+const conf = `subnet 10.0.0.0 netmask 255.255.255.0 {
+  range 10.0.0.100 10.0.0.200;
+}
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.109;
+}
+subnet6 2001:db8::/64 {
+  range6 2001:db8::/64;
+  prefix6 2001:db8:1:: 2001:db8:f:: /56;
+}
+`
+
+func TestWriteDHCPv4(t *testing.T) {
+	c, err := dhcpdconf.Parse(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnets := NewSubnets(c)
+	starts := time.Date(2021, time.December, 25, 22, 27, 49, 0, time.UTC)
+	ends := starts.Add(10 * time.Minute)
+	leases := []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.107"), Starts: &starts, Ends: &ends, BindingState: "free", HardwareEthernet: "8c:dc:d4:2b:ec:6c"},
+		{IP: netip.MustParseAddr("192.168.1.107"), Starts: &starts, Ends: &ends, BindingState: "active", HardwareEthernet: "8c:dc:d4:2b:ec:6c", UID: []byte{1, 0x8c, 0xdc, 0xd4, 0x2b, 0xec, 0x6c}, ClientHostname: "wopr, the computer", DDNSFwdName: "wopr.example.com."},
+		{IP: netip.MustParseAddr("192.168.1.108"), Starts: &starts, BindingState: "abandoned", ClientHostname: "joe, the phone"},
+		{IP: netip.MustParseAddr("192.168.1.109"), BindingState: "free"},
+		{IP: netip.MustParseAddr("10.0.0.100"), Starts: &starts, Ends: &ends, BindingState: "expired"},
+	}
+	var b bytes.Buffer
+	skipped, err := WriteDHCPv4(&b, leases, subnets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.Unleased != 1 {
+		t.Errorf("expected 192.168.1.109 to be skipped, got %+v", skipped)
+	}
+	expected := `address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state,user_context
+192.168.1.107,8c:dc:d4:2b:ec:6c,01:8c:dc:d4:2b:ec:6c,600,1640471869,2,1,0,wopr.example.com,0,
+192.168.1.108,,,4294967295,5935438564,2,0,0,joe&#x2c the phone,1,
+10.0.0.100,,,600,1640471869,1,0,0,,2,
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	leases = append(leases, dhcpd.DHCPv4Lease{IP: netip.MustParseAddr("172.16.0.1"), Starts: &starts, Ends: &ends, BindingState: "active"})
+	if _, err := WriteDHCPv4(&b, leases, subnets); err == nil || err.Error() != "lease 172.16.0.1: no subnet id" {
+		t.Errorf("expected an error for a lease outside the subnets, got %v", err)
+	}
+}
+
+func TestWriteDHCPv6(t *testing.T) {
+	c, err := dhcpdconf.Parse(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnets := NewSubnets(c)
+	// Overridden
+	if err := subnets.Parse("2001:db8::/64=64"); err != nil {
+		t.Fatal(err)
+	}
+	leases, errc := dhcpd6.Parse(strings.NewReader(`authoring-byte-order little-endian;
+ia-na "\276\257\244\320\000\003\000\001 \311\320\244\257\276" {
+  cltt 6 2021/12/25 22:24:37;
+  iaaddr 2001:db8::22c9:d0ff:fea4:afbe {
+    binding state active;
+    preferred-life 375;
+    max-life 600;
+    ends 6 2021/12/25 22:34:37;
+  }
+}
+ia-pd "\001\000\000\000\000\002\000\000\000\011\014\300\204\323\003\000\011\022" {
+  cltt 6 2021/12/25 22:24:37;
+  iaprefix 2001:db8:1::/56 {
+    binding state released;
+    preferred-life 375;
+    max-life 600;
+    ends 6 2021/12/25 22:34:37;
+  }
+}
+`))
+	var v6leases []dhcpd6.DHCPv6Lease
+	for lease := range leases {
+		v6leases = append(v6leases, *lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	// A lease without a DUID, which Kea can't take
+	v6leases = append(v6leases, dhcpd6.DHCPv6Lease{
+		Type:  dhcpd6.DHCPv6LeaseTypeNonTemporary,
+		Addrs: []*dhcpd6.DHCPv6LeaseAddr{{IP: netip.MustParseAddr("2001:db8::1"), BindingState: "active"}},
+	})
+	var b bytes.Buffer
+	skipped, err := WriteDHCPv6(&b, v6leases, subnets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.WithoutDUID != 1 {
+		t.Errorf("expected 2001:db8::1 to be skipped, got %+v", skipped)
+	}
+	expected := `address,duid,valid_lifetime,expire,subnet_id,pref_lifetime,lease_type,iaid,prefix_len,fqdn_fwd,fqdn_rev,hostname,hwaddr,state,user_context,hwtype,hwaddr_source
+2001:db8::22c9:d0ff:fea4:afbe,00:03:00:01:20:c9:d0:a4:af:be,600,1640471677,64,375,0,3500453822,128,0,0,,20:c9:d0:a4:af:be,0,,1,2
+2001:db8:1::,00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12,600,1640471677,64,375,2,1,56,0,0,,,2,,1,0
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestIAIDByteOrder(t *testing.T) {
+	input := `authoring-byte-order big-endian;
+ia-na "\000\000\000\001\000\003\000\001 \311\320\244\257\276" {
+  cltt 6 2021/12/25 22:24:37;
+}
+`
+	leases, errc := dhcpd6.Parse(strings.NewReader(input))
+	var lease *dhcpd6.DHCPv6Lease
+	for l := range leases {
+		lease = l
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if lease.ByteOrder != "big-endian" || iaid(lease) != 1 {
+		t.Errorf("expected a big-endian IAID of 1, got %s %d", lease.ByteOrder, iaid(lease))
+	}
+	lease.ByteOrder = ""
+	if iaid(lease) != 1<<24 {
+		t.Errorf("expected a little-endian IAID of %d without a byte order, got %d", 1<<24, iaid(lease))
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	subnets := NewSubnets(nil)
+	if err := subnets.Parse("10.0.0.0/8=1, 10.1.0.0/16=2"); err != nil {
+		t.Fatal(err)
+	}
+	if id := subnets.ID(netip.MustParseAddr("10.1.2.3")); id != 2 {
+		t.Errorf("expected the longest prefix's id 2, got %d", id)
+	}
+	if id := subnets.ID(netip.MustParseAddr("192.168.1.1")); id != 0 {
+		t.Errorf("expected no id, got %d", id)
+	}
+	for _, spec := range []string{"10.0.0.0/8", "10.0.0.0/8=0", "10.0.0.0=1"} {
+		if err := subnets.Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := `address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state,user_context,pool_id
+192.168.1.107,8c:dc:d4:2b:ec:6c,01:8c:dc:d4:2b:ec:6c,600,1640471869,2,1,0,wopr&#x2c the computer,0,,0
+192.168.1.108,,,4294967295,5935438564,2,0,0,,1,,0
+`
+	leases, errc := ParseDHCPv4(strings.NewReader(input))
+	var v4leases []dhcpd.DHCPv4Lease
+	for lease := range leases {
+		v4leases = append(v4leases, *lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(v4leases) != 2 {
+		t.Fatalf("expected 2 leases, got %d", len(v4leases))
+	}
+	lease := v4leases[0]
+	starts := time.Date(2021, time.December, 25, 22, 27, 49, 0, time.UTC)
+	if lease.IP != netip.MustParseAddr("192.168.1.107") || lease.HardwareEthernet != "8c:dc:d4:2b:ec:6c" ||
+		lease.ClientHostname != "wopr, the computer" || lease.DDNSFwdName != lease.ClientHostname ||
+		lease.BindingState != "active" || !lease.Starts.Equal(starts) || !lease.Ends.Equal(starts.Add(10*time.Minute)) {
+		t.Errorf("unexpected lease %+v", lease)
+	}
+	if lease.ClientID == nil || lease.ClientID.Hardware == nil {
+		t.Errorf("expected a hardware client identifier, got %+v", lease.ClientID)
+	}
+	if lease := v4leases[1]; lease.Ends != nil || lease.BindingState != "abandoned" {
+		t.Errorf("expected an abandoned lease which never ends, got %+v", lease)
+	}
+
+	// The files this package writes read back as the leases written
+	input = `address,duid,valid_lifetime,expire,subnet_id,pref_lifetime,lease_type,iaid,prefix_len,fqdn_fwd,fqdn_rev,hostname,hwaddr,state,user_context,hwtype,hwaddr_source
+2001:db8::22c9:d0ff:fea4:afbe,00:03:00:01:20:c9:d0:a4:af:be,600,1640471677,64,375,0,3500453822,128,0,0,,20:c9:d0:a4:af:be,0,,1,2
+2001:db8:1::,00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12,600,1640471677,64,375,2,1,56,0,0,,,2,,1,0
+`
+	v6, errc := ParseDHCPv6(strings.NewReader(input))
+	var v6leases []dhcpd6.DHCPv6Lease
+	for lease := range v6 {
+		v6leases = append(v6leases, *lease)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	subnets := NewSubnets(nil)
+	subnets.Set(netip.MustParsePrefix("2001:db8::/32"), 64)
+	if _, err := WriteDHCPv6(&b, v6leases, subnets); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != input {
+		t.Errorf("expected:\n%s\ngot:\n%s", input, b.String())
+	}
+
+	if _, errc := ParseDHCPv4(strings.NewReader("192.168.1.107,8c:dc:d4:2b:ec:6c\n")); <-errc == nil {
+		t.Error("expected an error for a file without a header")
+	}
+}
@@ -0,0 +1,207 @@
+package kea
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+// Kea appends to its lease file as dhcpd does, so later rows for an address
+// supersede earlier ones. Periodically its lease file cleanup (LFC) moves the
+// file aside to path.2 and compacts it with the previous result into path.1,
+// so a complete picture is path.1, path.2 and path, in that order.
+
+// Files are the lease files of Kea's memfile at path which exist, oldest
+// first
+func Files(path string) []string {
+	var res []string
+	for _, p := range []string{path + ".1", path + ".2", path} {
+		if _, err := os.Stat(p); err == nil {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// bindingState is the binding state dhcpd would give a lease in a Kea state
+func bindingState(state string) string {
+	switch state {
+	case "0":
+		return "active"
+	case "1":
+		return "abandoned"
+	case "2":
+		return "expired"
+	case "3":
+		return "released"
+	}
+	return state
+}
+
+// rows reads the CSV rows of a lease file by the names of its header's
+// columns, which vary between Kea versions
+func rows(input io.Reader, row func(get func(column string) string) error) error {
+	s := bufio.NewScanner(input)
+	s.Buffer(nil, 1<<20)
+	var columns map[string]int
+	var line int
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		values := strings.Split(text, ",")
+		if columns == nil {
+			columns = map[string]int{}
+			for i, name := range values {
+				columns[name] = i
+			}
+			if _, ok := columns["address"]; !ok {
+				return fmt.Errorf("line %d: expected a header with an address column", line)
+			}
+			continue
+		}
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(values) {
+				return ""
+			}
+			return strings.ReplaceAll(values[i], "&#x2c", ",")
+		}
+		if err := row(get); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return s.Err()
+}
+
+// lifetime is when the lease was last granted or renewed and when it
+// expires, Kea storing the latter and the valid lifetime
+func lifetime(get func(string) string) (cltt, expire *time.Time, valid int, err error) {
+	valid, err = strconv.Atoi(get("valid_lifetime"))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("valid_lifetime: %w", err)
+	}
+	secs, err := strconv.ParseInt(get("expire"), 10, 64)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("expire: %w", err)
+	}
+	e := time.Unix(secs, 0).UTC()
+	c := e.Add(-time.Duration(valid) * time.Second)
+	if uint32(valid) == infinite {
+		// The lease never ends, as dhcpd would write it
+		return &c, nil, valid, nil
+	}
+	return &c, &e, valid, nil
+}
+
+// ParseDHCPv4 reads the leases of a kea-leases4.csv file
+func ParseDHCPv4(input io.Reader) (<-chan *dhcpd.DHCPv4Lease, <-chan error) {
+	leases := make(chan *dhcpd.DHCPv4Lease)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(leases)
+		errc <- rows(input, func(get func(string) string) error {
+			ip, err := netip.ParseAddr(get("address"))
+			if err != nil {
+				return err
+			}
+			cltt, ends, _, err := lifetime(get)
+			if err != nil {
+				return err
+			}
+			lease := &dhcpd.DHCPv4Lease{
+				IP:             ip,
+				Starts:         cltt,
+				Ends:           ends,
+				CLTT:           cltt,
+				BindingState:   bindingState(get("state")),
+				ClientHostname: get("hostname"),
+			}
+			if mac, err := net.ParseMAC(get("hwaddr")); err == nil {
+				lease.HardwareEthernet = mac.String()
+			}
+			if uid, err := duid.ParseHex(get("client_id")); err == nil && len(uid) > 0 {
+				dhcpd.DHCPv4LeaseOptionUID(uid).Apply(lease)
+			}
+			if get("fqdn_fwd") == "1" {
+				lease.DDNSFwdName = lease.ClientHostname
+			}
+			leases <- lease
+			return nil
+		})
+	}()
+	return leases, errc
+}
+
+// ParseDHCPv6 reads the leases of a kea-leases6.csv file, a lease of each
+// address or prefix. IAIDs are given in dhcpd's byte order, little-endian.
+func ParseDHCPv6(input io.Reader) (<-chan *dhcpd6.DHCPv6Lease, <-chan error) {
+	leases := make(chan *dhcpd6.DHCPv6Lease)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(leases)
+		errc <- rows(input, func(get func(string) string) error {
+			ip, err := netip.ParseAddr(get("address"))
+			if err != nil {
+				return err
+			}
+			cltt, ends, valid, err := lifetime(get)
+			if err != nil {
+				return err
+			}
+			iaid, err := strconv.ParseUint(get("iaid"), 10, 32)
+			if err != nil {
+				return fmt.Errorf("iaid: %w", err)
+			}
+			preferred, _ := strconv.Atoi(get("pref_lifetime"))
+			addr := &dhcpd6.DHCPv6LeaseAddr{
+				IP:            ip,
+				BindingState:  bindingState(get("state")),
+				PreferredLife: preferred,
+				MaxLife:       valid,
+				Ends:          ends,
+			}
+			lease := &dhcpd6.DHCPv6Lease{
+				IAID:  make([]byte, 4),
+				CLTT:  cltt,
+				Addrs: []*dhcpd6.DHCPv6LeaseAddr{addr},
+			}
+			binary.LittleEndian.PutUint32(lease.IAID, uint32(iaid))
+			switch get("lease_type") {
+			case "0":
+				lease.Type = dhcpd6.DHCPv6LeaseTypeNonTemporary
+			case "1":
+				lease.Type = dhcpd6.DHCPv6LeaseTypeTemporary
+			case "2":
+				lease.Type = dhcpd6.DHCPv6LeaseTypePrefixDelegation
+				if addr.PrefixLen, err = strconv.Atoi(get("prefix_len")); err != nil {
+					return fmt.Errorf("prefix_len: %w", err)
+				}
+			default:
+				return fmt.Errorf("unknown lease_type %s", get("lease_type"))
+			}
+			// Clients with DUIDs of other types are still kept, without one
+			if d, err := duid.ParseDUIDString(get("duid")); err == nil {
+				lease.DUID = d
+			}
+			leases <- lease
+			return nil
+		})
+	}()
+	return leases, errc
+}
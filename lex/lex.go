@@ -8,6 +8,7 @@ import (
 	"unicode"
 )
 
+// Pos is where a token begins, counting lines and characters from 0
 type Pos struct {
 	Line int
 	Char int
@@ -17,6 +18,11 @@ func (p Pos) String() string {
 	return fmt.Sprintf("%d:%d", p.Line+1, p.Char+1)
 }
 
+// MarshalText writes the position as String does, line:char from 1
+func (p Pos) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
 type Token struct {
 	Pos Pos
 	Typ ItemType // The type of this item.
@@ -40,7 +46,8 @@ const (
 type Lexer struct {
 	Input  *bufio.Reader
 	Tokens chan Token
-	Pos    Pos
+	Pos    Pos // of the next rune
+	Start  Pos // of the token being lexed
 }
 
 func (l *Lexer) Next() (rune, error) {
@@ -57,8 +64,9 @@ func (l *Lexer) Next() (rune, error) {
 	return r, nil
 }
 
+// Emit sends a token which began at Start
 func (l *Lexer) Emit(typ ItemType, val string) {
-	l.Tokens <- Token{Typ: typ, Val: val, Pos: l.Pos}
+	l.Tokens <- Token{Typ: typ, Val: val, Pos: l.Start}
 }
 
 func Lex(input io.Reader) chan Token {
@@ -70,16 +78,17 @@ func Lex(input io.Reader) chan Token {
 		if err := l.lex(); err != nil {
 			l.Emit(ItemError, err.Error())
 		}
+		close(l.Tokens)
 	}()
 	return l.Tokens
 }
 
 func (l *Lexer) lex() error {
 	for {
+		l.Start = l.Pos
 		r, err := l.Next()
 		if err != nil {
 			if err == io.EOF {
-				close(l.Tokens)
 				return nil
 			}
 			return fmt.Errorf("lexing top-level: %w", err)
@@ -156,6 +165,7 @@ func (l *Lexer) lexWord(r rune) error {
 	}
 
 	for {
+		pos := l.Pos
 		r, err := l.Next()
 		if err != nil {
 			return fmt.Errorf("lexing word: %w", err)
@@ -166,14 +176,17 @@ func (l *Lexer) lexWord(r rune) error {
 			return nil
 		case r == ';':
 			emit()
+			l.Start = pos
 			l.Emit(ItemSemicolon, ";")
 			return nil
 		case r == '{':
 			emit()
+			l.Start = pos
 			l.Emit(ItemBeginBlock, "{")
 			return nil
 		case r == '=':
 			emit()
+			l.Start = pos
 			l.Emit(ItemAssign, "=")
 			return nil
 		}
@@ -0,0 +1,190 @@
+// Package lint checks a dhcpd.leases file for problems which parse, but which
+// dhcpd shouldn't have written or an operator should know of, e.g. two
+// clients bound to one address at once.
+package lint
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/lex"
+)
+
+// Checks, which name the findings
+const (
+	CheckOverlap           = "overlap"            // two clients bound to an address at once
+	CheckEndsBeforeStarts  = "ends-before-starts" // a lease which ends before it starts
+	CheckOutOfRange        = "out-of-range"       // an address outside the ranges of dhcpd.conf
+	CheckDuplicateHostname = "duplicate-hostname" // a hostname held by several clients
+	CheckManyLeases        = "many-leases"        // a client holding many addresses
+	CheckMalformedMAC      = "malformed-mac"      // a hardware ethernet address which doesn't parse
+	CheckUnknown           = "unknown-directive"  // a directive the parser doesn't know
+)
+
+type Finding struct {
+	Pos     lex.Pos `json:"pos"`
+	Check   string  `json:"check"`
+	Message string  `json:"message"`
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Pos, f.Message, f.Check)
+}
+
+type Options struct {
+	// The ranges leases must fall within, unchecked if nil
+	Conf *dhcpdconf.Config
+	// The most active leases a client may hold, unchecked if 0
+	MaxLeases int
+	// Leases which ended before now aren't active. Defaults to the time of
+	// the check.
+	Now time.Time
+}
+
+// Lint parses input leniently and checks its declarations. The findings
+// before a syntax error, which stops the parse, are returned along with it.
+func Lint(input io.Reader, opts Options) ([]Finding, error) {
+	var res []dhcpd.Declaration
+	declarations, errc := dhcpd.ParseLenient(input)
+	for d := range declarations {
+		res = append(res, d)
+	}
+	return Check(res, opts), <-errc
+}
+
+// Check reports the problems of the declarations of a lease file, in file
+// order
+func Check(declarations []dhcpd.Declaration, opts Options) []Finding {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	var res []Finding
+	report := func(pos lex.Pos, check, format string, args ...interface{}) {
+		res = append(res, Finding{Pos: pos, Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// The latest lease of each address while it's active, and its latest
+	// lease
+	bound := map[netip.Addr]*dhcpd.DHCPv4Lease{}
+	current := map[netip.Addr]*dhcpd.DHCPv4Lease{}
+	var order []netip.Addr
+	outside := map[netip.Addr]bool{}
+	for _, d := range declarations {
+		switch d := d.(type) {
+		case *dhcpd.Unknown:
+			report(d.Pos, CheckUnknown, "%s", d)
+		case *dhcpd.DynamicHost:
+			if d.HardwareEthernet != "" && !validMAC(d.HardwareEthernet) {
+				report(d.Pos, CheckMalformedMAC, "host %s: malformed hardware ethernet %s", d.Name, d.HardwareEthernet)
+			}
+		case *dhcpd.DHCPv4Lease:
+			if d.HardwareEthernet != "" && !validMAC(d.HardwareEthernet) {
+				report(d.Pos, CheckMalformedMAC, "lease %s: malformed hardware ethernet %s", d.IP, d.HardwareEthernet)
+			}
+			if d.Starts != nil && d.Ends != nil && d.Ends.Before(*d.Starts) {
+				report(d.Pos, CheckEndsBeforeStarts, "lease %s: ends %s before it starts %s", d.IP, d.Ends.Format(time.RFC3339), d.Starts.Format(time.RFC3339))
+			}
+			if opts.Conf != nil && !outside[d.IP] {
+				if scope, ok := opts.Conf.Lookup(d.IP); !ok || scope.Range == nil {
+					outside[d.IP] = true
+					report(d.Pos, CheckOutOfRange, "lease %s: outside the ranges of dhcpd.conf", d.IP)
+				}
+			}
+			if d.BindingState == "active" {
+				if prev := bound[d.IP]; prev != nil && !sameClient(prev, d) && overlaps(prev, d) {
+					report(d.Pos, CheckOverlap, "lease %s: bound to %s while still bound to %s at %s", d.IP, client(d), client(prev), prev.Pos)
+				}
+				bound[d.IP] = d
+			} else {
+				// Released, expired or otherwise ended before its ends
+				delete(bound, d.IP)
+			}
+			if _, ok := current[d.IP]; !ok {
+				order = append(order, d.IP)
+			}
+			current[d.IP] = d
+		}
+	}
+
+	// Of the bindings which hold now
+	hostnames := map[string][]*dhcpd.DHCPv4Lease{}
+	macs := map[string][]*dhcpd.DHCPv4Lease{}
+	var names, clients []string
+	for _, ip := range order {
+		lease := current[ip]
+		if lease.BindingState != "active" || (lease.Ends != nil && lease.Ends.Before(opts.Now)) {
+			continue
+		}
+		if mac := strings.ToLower(lease.HardwareEthernet); mac != "" {
+			if len(macs[mac]) == 0 {
+				clients = append(clients, mac)
+			}
+			macs[mac] = append(macs[mac], lease)
+		}
+		if name := strings.ToLower(lease.ClientHostname); name != "" {
+			if len(hostnames[name]) == 0 {
+				names = append(names, name)
+			}
+			hostnames[name] = append(hostnames[name], lease)
+		}
+	}
+	for _, name := range names {
+		leases := hostnames[name]
+		first := leases[0]
+		for _, lease := range leases[1:] {
+			if !strings.EqualFold(lease.HardwareEthernet, first.HardwareEthernet) {
+				report(lease.Pos, CheckDuplicateHostname, "lease %s: hostname %s of %s is also held by %s at %s", lease.IP, lease.ClientHostname, client(lease), client(first), first.Pos)
+			}
+		}
+	}
+	for _, mac := range clients {
+		if leases := macs[mac]; opts.MaxLeases > 0 && len(leases) > opts.MaxLeases {
+			report(leases[0].Pos, CheckManyLeases, "%s holds %d active leases, more than %d", mac, len(leases), opts.MaxLeases)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].Pos, res[j].Pos
+		return a.Line < b.Line || (a.Line == b.Line && a.Char < b.Char)
+	})
+	return res
+}
+
+// validMAC reports whether a hardware ethernet address is six colon
+// separated octets
+func validMAC(s string) bool {
+	mac, err := net.ParseMAC(s)
+	return err == nil && len(mac) == 6
+}
+
+// sameClient reports whether two leases are of one client, by its hardware
+// address or else its client identifier
+func sameClient(a, b *dhcpd.DHCPv4Lease) bool {
+	if a.HardwareEthernet != "" || b.HardwareEthernet != "" {
+		return strings.EqualFold(a.HardwareEthernet, b.HardwareEthernet)
+	}
+	return string(a.UID) == string(b.UID)
+}
+
+// overlaps reports whether a lease ends after the next of its address
+// starts. Leases without times are taken to hold forever.
+func overlaps(prev, next *dhcpd.DHCPv4Lease) bool {
+	return prev.Ends == nil || next.Starts == nil || prev.Ends.After(*next.Starts)
+}
+
+func client(lease *dhcpd.DHCPv4Lease) string {
+	switch {
+	case lease.HardwareEthernet != "":
+		return lease.HardwareEthernet
+	case len(lease.UID) != 0:
+		return fmt.Sprintf("uid %x", lease.UID)
+	}
+	return "an unknown client"
+}
@@ -0,0 +1,138 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+)
+
+const leasesFile = `authoring-byte-order little-endian;
+db-time-format local;
+
+lease 10.0.0.100 {
+  starts 1 2021/12/27 09:00:00;
+  ends 1 2021/12/27 10:00:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+  client-hostname "wopr";
+}
+lease 10.0.0.100 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 10:30:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6d;
+  client-hostname "wopr";
+}
+lease 10.0.0.101 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 09:00:00;
+  binding state free;
+  hardware ethernet 8c:dc:d4:2b:ec;
+}
+lease 10.0.0.102 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 10:30:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+  client-hostname "wopr";
+  option agent.circuit-id eth0;
+}
+lease 10.0.1.5 {
+  starts 1 2021/12/27 09:30:00;
+  ends 1 2021/12/27 10:30:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+`
+
+func TestLint(t *testing.T) {
+	conf, err := dhcpdconf.Parse(strings.NewReader(`
+subnet 10.0.0.0 netmask 255.255.0.0 {
+  range 10.0.0.100 10.0.0.199;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := Lint(strings.NewReader(leasesFile), Options{
+		Conf:      conf,
+		MaxLeases: 1,
+		Now:       time.Date(2021, time.December, 27, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Pos.String()+" "+f.Check)
+	}
+	expected := []string{
+		"2:1 unknown-directive",
+		"11:1 overlap",
+		"18:1 malformed-mac",
+		"18:1 ends-before-starts",
+		// 10.0.0.100 was rebound, so the hostname is held by its new client
+		"24:1 duplicate-hostname",
+		"24:1 many-leases",
+		"30:3 unknown-directive",
+		"32:1 out-of-range",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestLintEndsNever(t *testing.T) {
+	input := `lease 10.0.0.100 {
+  starts 1 2021/12/27 09:00:00;
+  ends never;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+lease 10.0.0.100 {
+  starts 1 2030/12/27 09:00:00;
+  ends 1 2030/12/27 10:00:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6d;
+}
+`
+	findings, err := Lint(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Check != CheckOverlap || findings[0].Pos.String() != "7:1" {
+		t.Errorf("expected an overlap at 7:1, got %v", findings)
+	}
+}
+
+func TestLintRebound(t *testing.T) {
+	// Released early and bound to another client before it would have ended
+	input := `lease 10.0.0.100 {
+  starts 1 2021/12/27 09:00:00;
+  ends 1 2021/12/27 10:00:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+lease 10.0.0.100 {
+  starts 1 2021/12/27 09:00:00;
+  ends 1 2021/12/27 09:10:00;
+  binding state free;
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+}
+lease 10.0.0.100 {
+  starts 1 2021/12/27 09:20:00;
+  ends 1 2021/12/27 10:20:00;
+  binding state active;
+  hardware ethernet 8c:dc:d4:2b:ec:6d;
+}
+`
+	findings, err := Lint(strings.NewReader(input), Options{Now: time.Date(2021, time.December, 27, 9, 30, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
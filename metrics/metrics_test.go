@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Family("dhcpd_leases", TypeGauge, "Leases by binding state")
+	w.Sample("dhcpd_leases", 3, Label{"family", "v4"}, Label{"state", "active"})
+	w.Family("dhcpd_leases_vendor_class", TypeGauge, "Active leases by vendor class")
+	w.Sample("dhcpd_leases_vendor_class", 1, Label{"vendor_class", "say \"hi\"\\\n"})
+	w.Family("dhcpd_pool_size", TypeGauge, "Addresses in the pool")
+	w.Sample("dhcpd_pool_size", 18446744073709551615)
+	if err := w.Err(); err != nil {
+		t.Fatalf("write metrics: %v", err)
+	}
+
+	expected := `# HELP dhcpd_leases Leases by binding state
+# TYPE dhcpd_leases gauge
+dhcpd_leases{family="v4",state="active"} 3
+# HELP dhcpd_leases_vendor_class Active leases by vendor class
+# TYPE dhcpd_leases_vendor_class gauge
+dhcpd_leases_vendor_class{vendor_class="say \"hi\"\\\n"} 1
+# HELP dhcpd_pool_size Addresses in the pool
+# TYPE dhcpd_pool_size gauge
+dhcpd_pool_size 1.8446744073709552e+19
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\nbut was:\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,340 @@
+// Package query selects, sorts and pages leases as described by URL query
+// parameters, e.g. ?state=active&subnet=10.0.0.0/24&sort=-ends&limit=100
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// Query is parsed from the parameters:
+//
+//	family   4 or 6, both by default
+//	state    binding states, repeated or comma separated
+//	mac      client MAC, from the hardware address or DUID
+//	hostname glob matched case insensitively, v4 leases only
+//	subnet   prefix containing the address
+//	vendor   substring of the vendor of the MAC, case insensitive
+//	source   names of the lease files, repeated or comma separated
+//	site     sites of the lease files, same as source
+//	since    RFC 3339 time, or a duration before now like 24h
+//	until    same as since
+//	sort     JSON field names, comma separated, descending when prefixed by -
+//	limit    leases of each family per page
+//	cursor   the next cursor of the previous page
+//
+// since and until bound when the lease was last granted or renewed, its
+// starts for v4 and cltt for v6. A v6 lease matches state and subnet when
+// any of its addresses does. Fields of v6 addresses, like ends, sort by the
+// lease's first address.
+type Query struct {
+	Family       int
+	States       []string
+	HardwareAddr net.HardwareAddr
+	Hostname     string
+	Subnet       netip.Prefix
+	Vendor       string
+	Sources      []string
+	Sites        []string
+	Since        time.Time
+	Until        time.Time
+	Sort         []Key
+	Limit        int
+	cursor       *cursor
+	relative     bool
+}
+
+// Key is a field to sort by
+type Key struct {
+	Field      string
+	Descending bool
+}
+
+func (k Key) String() string {
+	if k.Descending {
+		return "-" + k.Field
+	}
+	return k.Field
+}
+
+// Parse reads a query, relative durations are before now
+func Parse(values url.Values, now time.Time) (*Query, error) {
+	var q Query
+	if s := values.Get("family"); s != "" {
+		switch s {
+		case "4":
+			q.Family = 4
+		case "6":
+			q.Family = 6
+		default:
+			return nil, fmt.Errorf("family: expected 4 or 6, got %s", s)
+		}
+	}
+	q.States = list(values["state"])
+	if s := values.Get("mac"); s != "" {
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return nil, fmt.Errorf("mac: %w", err)
+		}
+		q.HardwareAddr = mac
+	}
+	if s := values.Get("hostname"); s != "" {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("hostname: %w", err)
+		}
+		q.Hostname = strings.ToLower(s)
+	}
+	if s := values.Get("subnet"); s != "" {
+		subnet, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("subnet: %w", err)
+		}
+		q.Subnet = subnet.Masked()
+	}
+	q.Vendor = strings.ToLower(values.Get("vendor"))
+	q.Sources = list(values["source"])
+	q.Sites = list(values["site"])
+	var err error
+	var relative bool
+	if q.Since, relative, err = parseTime(values.Get("since"), now); err != nil {
+		return nil, fmt.Errorf("since: %w", err)
+	}
+	q.relative = relative
+	if q.Until, relative, err = parseTime(values.Get("until"), now); err != nil {
+		return nil, fmt.Errorf("until: %w", err)
+	}
+	q.relative = q.relative || relative
+	for _, s := range list(values["sort"]) {
+		key := Key{Field: strings.TrimPrefix(s, "-"), Descending: strings.HasPrefix(s, "-")}
+		if v4Fields[key.Field] == nil && v6Fields[key.Field] == nil {
+			return nil, fmt.Errorf("sort: unknown field %s", key.Field)
+		}
+		q.Sort = append(q.Sort, key)
+	}
+	if s := values.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			return nil, fmt.Errorf("limit: expected a positive number, got %s", s)
+		}
+	}
+	if s := values.Get("cursor"); s != "" {
+		if q.cursor, err = decodeCursor(s); err != nil {
+			return nil, fmt.Errorf("cursor: %w", err)
+		}
+		if q.cursor.Sort != q.sortString() {
+			return nil, fmt.Errorf("cursor: sorted by %q, not %q", q.cursor.Sort, q.sortString())
+		}
+	}
+	return &q, nil
+}
+
+// list splits comma separated values
+func list(values []string) []string {
+	var res []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+// parseTime reports whether the time is relative to now
+func parseTime(s string, now time.Time) (time.Time, bool, error) {
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected an RFC 3339 time or a duration, got %s", s)
+	}
+	return now.Add(-d), true, nil
+}
+
+// Relative reports whether the query depends on when it was parsed, as
+// with since=24h
+func (q *Query) Relative() bool {
+	return q.relative
+}
+
+func (q *Query) sortString() string {
+	keys := make([]string, 0, len(q.Sort))
+	for _, k := range q.Sort {
+		keys = append(keys, k.String())
+	}
+	return strings.Join(keys, ",")
+}
+
+// Result is a page of leases
+type Result struct {
+	DHCPv4Leases []dhcpd.DHCPv4Lease
+	DHCPv6Leases []dhcpd6.DHCPv6Lease
+	// Cursor of the next page, empty on the last
+	Next string
+}
+
+// Apply selects a page of the leases, given in file order. They're
+// returned newest first unless sorted otherwise, ties broken the same way.
+func (q *Query) Apply(v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease) (*Result, error) {
+	var res Result
+	var next cursor
+	var v4after, v6after *position
+	if q.cursor != nil {
+		// An exhausted family has no position
+		if v4after = q.cursor.V4; v4after == nil {
+			v4leases = nil
+		}
+		if v6after = q.cursor.V6; v6after == nil {
+			v6leases = nil
+		}
+	}
+	var err error
+	res.DHCPv4Leases, next.V4, err = page(q, v4leases, q.matchDHCPv4, v4Fields, v4after)
+	if err != nil {
+		return nil, err
+	}
+	res.DHCPv6Leases, next.V6, err = page(q, v6leases, q.matchDHCPv6, v6Fields, v6after)
+	if err != nil {
+		return nil, err
+	}
+	if next.V4 != nil || next.V6 != nil {
+		next.Sort = q.sortString()
+		if res.Next, err = next.encode(); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+func (q *Query) matchDHCPv4(lease *dhcpd.DHCPv4Lease) bool {
+	if q.Family == 6 {
+		return false
+	}
+	if len(q.States) > 0 && !contains(q.States, lease.BindingState) {
+		return false
+	}
+	if !q.matchSource(lease.Source, lease.Site) {
+		return false
+	}
+	if q.Subnet.IsValid() && !q.Subnet.Contains(lease.IP) {
+		return false
+	}
+	if q.Hostname != "" {
+		if ok, _ := path.Match(q.Hostname, strings.ToLower(lease.ClientHostname)); !ok {
+			return false
+		}
+	}
+	mac, ok := inventory.DHCPv4HardwareAddr(lease)
+	return q.matchHardwareAddr(mac, ok) && q.matchTime(lease.Starts)
+}
+
+func (q *Query) matchDHCPv6(lease *dhcpd6.DHCPv6Lease) bool {
+	if q.Family == 4 || q.Hostname != "" {
+		return false
+	}
+	if !q.matchSource(lease.Source, lease.Site) {
+		return false
+	}
+	if len(q.States) > 0 || q.Subnet.IsValid() {
+		var found bool
+		for _, addr := range lease.Addrs {
+			if len(q.States) > 0 && !contains(q.States, addr.BindingState) {
+				continue
+			}
+			if q.Subnet.IsValid() && !q.Subnet.Overlaps(addr.Prefix()) {
+				continue
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	mac, ok := inventory.DHCPv6HardwareAddr(lease)
+	return q.matchHardwareAddr(mac, ok) && q.matchTime(lease.CLTT)
+}
+
+func (q *Query) matchSource(source, site string) bool {
+	return (len(q.Sources) == 0 || contains(q.Sources, source)) && (len(q.Sites) == 0 || contains(q.Sites, site))
+}
+
+func (q *Query) matchHardwareAddr(mac net.HardwareAddr, ok bool) bool {
+	if q.HardwareAddr != nil && (!ok || !bytes.Equal(q.HardwareAddr, mac)) {
+		return false
+	}
+	if q.Vendor != "" && (!ok || !strings.Contains(strings.ToLower(inventory.Vendor(mac)), q.Vendor)) {
+		return false
+	}
+	return true
+}
+
+func (q *Query) matchTime(t *time.Time) bool {
+	if q.Since.IsZero() && q.Until.IsZero() {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	return !t.Before(q.Since) && (q.Until.IsZero() || !t.After(q.Until))
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// cursor is the position of the last lease of each family on a page, so the
+// next page starts after it even as leases are added. It is opaque to
+// clients, and only valid until dhcpd rewrites the lease file.
+type cursor struct {
+	Sort string    `json:"sort"`
+	V4   *position `json:"v4,omitempty"`
+	V6   *position `json:"v6,omitempty"`
+}
+
+type position struct {
+	Key   []json.RawMessage `json:"key"`
+	Index int               `json:"index"` // in the file
+}
+
+func (c *cursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
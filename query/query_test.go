@@ -0,0 +1,197 @@
+package query
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+var now = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func v4lease(ip, state, mac, hostname string, starts time.Time) dhcpd.DHCPv4Lease {
+	return dhcpd.DHCPv4Lease{
+		IP:               netip.MustParseAddr(ip),
+		Starts:           &starts,
+		BindingState:     state,
+		HardwareEthernet: mac,
+		ClientHostname:   hostname,
+	}
+}
+
+func tagged(lease dhcpd.DHCPv4Lease, source, site string) dhcpd.DHCPv4Lease {
+	lease.Source, lease.Site = source, site
+	return lease
+}
+
+// In file order, oldest first
+var v4leases = []dhcpd.DHCPv4Lease{
+	v4lease("10.0.0.3", "free", "00:00:00:00:00:03", "", now.Add(-72*time.Hour)),
+	v4lease("10.0.0.1", "active", "00:00:00:00:00:01", "wopr", now.Add(-48*time.Hour)),
+	tagged(v4lease("10.0.1.2", "active", "00:00:00:00:00:02", "Joshua", now.Add(-24*time.Hour)), "dc2-a", "dc2"),
+	v4lease("10.0.0.2", "active", "00:00:00:00:00:04", "falken", now.Add(-time.Hour)),
+}
+
+var v6leases = []dhcpd6.DHCPv6Lease{{
+	Type:  dhcpd6.DHCPv6LeaseTypeNonTemporary,
+	Addrs: []*dhcpd6.DHCPv6LeaseAddr{{IP: netip.MustParseAddr("fd00::1"), BindingState: "active"}},
+}}
+
+func ips(leases []dhcpd.DHCPv4Lease) []string {
+	var res []string
+	for _, lease := range leases {
+		res = append(res, lease.IP.String())
+	}
+	return res
+}
+
+func TestApply(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		expected []string
+		v6       int
+	}{
+		{"", []string{"10.0.0.2", "10.0.1.2", "10.0.0.1", "10.0.0.3"}, 1},
+		{"state=active", []string{"10.0.0.2", "10.0.1.2", "10.0.0.1"}, 1},
+		{"state=free,expired", []string{"10.0.0.3"}, 0},
+		{"family=4", []string{"10.0.0.2", "10.0.1.2", "10.0.0.1", "10.0.0.3"}, 0},
+		{"family=6", nil, 1},
+		{"mac=00:00:00:00:00:01", []string{"10.0.0.1"}, 0},
+		{"hostname=j*", []string{"10.0.1.2"}, 0},
+		{"subnet=10.0.0.0/24", []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"}, 0},
+		{"subnet=fd00::/64", nil, 1},
+		{"source=dc2-a,dc3-a", []string{"10.0.1.2"}, 0},
+		{"site=dc2", []string{"10.0.1.2"}, 0},
+		{"since=36h", []string{"10.0.0.2", "10.0.1.2"}, 0},
+		{"until=2022-02-28T12:00:00Z", []string{"10.0.1.2", "10.0.0.1", "10.0.0.3"}, 0},
+		{"sort=ip", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.1.2"}, 1},
+		{"sort=-binding-state,client-hostname", []string{"10.0.0.3", "10.0.1.2", "10.0.0.2", "10.0.0.1"}, 1},
+	} {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(values, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		res, err := q.Apply(v4leases, v6leases)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := ips(res.DHCPv4Leases); !equal(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+		if len(res.DHCPv6Leases) != tt.v6 {
+			t.Errorf("%s: expected %d v6 leases, got %d", tt.query, tt.v6, len(res.DHCPv6Leases))
+		}
+		if res.Next != "" {
+			t.Errorf("%s: unexpected next page", tt.query)
+		}
+	}
+}
+
+func TestSortTimes(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	leases := []dhcpd.DHCPv4Lease{
+		// Later, though its String sorts first
+		v4lease("10.0.0.1", "active", "", "", now.In(est).Add(time.Hour)),
+		v4lease("10.0.0.2", "active", "", "", now),
+	}
+	for _, tt := range []struct {
+		query    string
+		expected []string
+	}{
+		{"sort=starts", []string{"10.0.0.2", "10.0.0.1"}},
+		{"sort=-starts", []string{"10.0.0.1", "10.0.0.2"}},
+	} {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(values, now)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		res, err := q.Apply(leases, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := ips(res.DHCPv4Leases); !equal(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestPaging(t *testing.T) {
+	leases := append([]dhcpd.DHCPv4Lease{}, v4leases...)
+	values := url.Values{"sort": {"-ip"}, "limit": {"3"}}
+	q, err := Parse(values, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := q.Apply(leases, v6leases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ips(res.DHCPv4Leases); !equal(got, []string{"10.0.1.2", "10.0.0.3", "10.0.0.2"}) || res.Next == "" {
+		t.Fatalf("unexpected first page %v, next %q", got, res.Next)
+	}
+
+	// A lease added before the next page doesn't shift it
+	leases = append(leases, v4lease("10.0.2.1", "active", "00:00:00:00:00:05", "", now))
+	values.Set("cursor", res.Next)
+	if q, err = Parse(values, now); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = q.Apply(leases, v6leases); err != nil {
+		t.Fatal(err)
+	}
+	if got := ips(res.DHCPv4Leases); !equal(got, []string{"10.0.0.1"}) || len(res.DHCPv6Leases) != 0 || res.Next != "" {
+		t.Errorf("unexpected second page %v, %d v6 leases, next %q", got, len(res.DHCPv6Leases), res.Next)
+	}
+
+	// The cursor belongs to its sort
+	values.Set("sort", "ip")
+	if _, err := Parse(values, now); err == nil {
+		t.Error("expected an error for a cursor of another sort")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, query := range []string{
+		"family=5",
+		"mac=wopr",
+		"hostname=[",
+		"subnet=10.0.0.0",
+		"since=yesterday",
+		"sort=color",
+		"limit=0",
+		"cursor=!",
+	} {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(values, now); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
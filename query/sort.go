@@ -0,0 +1,220 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+// field reads a sortable field from a lease
+type field struct {
+	typ reflect.Type
+	get func(lease reflect.Value) reflect.Value
+}
+
+// The sortable fields of each family by their JSON names
+var (
+	v4Fields = map[string]*field{}
+	v6Fields = map[string]*field{}
+)
+
+func init() {
+	addFields(v4Fields, reflect.TypeOf(dhcpd.DHCPv4Lease{}), func(i int) func(reflect.Value) reflect.Value {
+		return func(lease reflect.Value) reflect.Value { return lease.Field(i) }
+	})
+	addFields(v6Fields, reflect.TypeOf(dhcpd6.DHCPv6Lease{}), func(i int) func(reflect.Value) reflect.Value {
+		return func(lease reflect.Value) reflect.Value { return lease.Field(i) }
+	})
+	addrType := reflect.TypeOf(dhcpd6.DHCPv6LeaseAddr{})
+	addFields(v6Fields, addrType, func(i int) func(reflect.Value) reflect.Value {
+		return func(lease reflect.Value) reflect.Value {
+			addrs := lease.FieldByName("Addrs")
+			if addrs.Len() == 0 {
+				return reflect.Zero(addrType.Field(i).Type)
+			}
+			return addrs.Index(0).Elem().Field(i)
+		}
+	})
+}
+
+func addFields(fields map[string]*field, t reflect.Type, get func(i int) func(reflect.Value) reflect.Value) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || fields[name] != nil {
+			continue
+		}
+		// Lists, like the addresses of a v6 lease, have no order
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.Uint8 {
+			continue
+		}
+		fields[name] = &field{typ: f.Type, get: get(i)}
+	}
+}
+
+type item[T any] struct {
+	index int // in the file
+	lease *T
+	key   []reflect.Value
+}
+
+// page sorts the leases matching q and returns those after the position, and
+// the position of the last if there are more.
+func page[T any](q *Query, leases []T, match func(*T) bool, fields map[string]*field, after *position) ([]T, *position, error) {
+	var items []*item[T]
+	for i := len(leases) - 1; i >= 0; i-- {
+		if !match(&leases[i]) {
+			continue
+		}
+		it := &item[T]{index: i, lease: &leases[i]}
+		for _, k := range q.Sort {
+			if f := fields[k.Field]; f != nil {
+				it.key = append(it.key, f.get(reflect.ValueOf(it.lease).Elem()))
+			} else {
+				// Sorts the family by the other keys
+				it.key = append(it.key, reflect.Value{})
+			}
+		}
+		items = append(items, it)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return compareItems(q.Sort, items[i].key, items[i].index, items[j].key, items[j].index) < 0
+	})
+
+	if after != nil {
+		key, err := decodeKey(q.Sort, fields, after.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		start := sort.Search(len(items), func(i int) bool {
+			return compareItems(q.Sort, items[i].key, items[i].index, key, after.Index) > 0
+		})
+		items = items[start:]
+	}
+	var next *position
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+		last := items[len(items)-1]
+		next = &position{Index: last.index}
+		for _, v := range last.key {
+			b := []byte("null")
+			if v.IsValid() {
+				var err error
+				if b, err = json.Marshal(v.Interface()); err != nil {
+					return nil, nil, err
+				}
+			}
+			next.Key = append(next.Key, b)
+		}
+	}
+	res := make([]T, 0, len(items))
+	for _, it := range items {
+		res = append(res, *it.lease)
+	}
+	return res, next, nil
+}
+
+// decodeKey reads the sort key of a cursor position into the fields' types
+func decodeKey(keys []Key, fields map[string]*field, raw []json.RawMessage) ([]reflect.Value, error) {
+	if len(raw) != len(keys) {
+		return nil, fmt.Errorf("cursor: expected %d keys, got %d", len(keys), len(raw))
+	}
+	res := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		f := fields[k.Field]
+		if f == nil {
+			continue
+		}
+		v := reflect.New(f.typ)
+		if err := json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("cursor: %s: %w", k.Field, err)
+		}
+		res[i] = v.Elem()
+	}
+	return res, nil
+}
+
+// compareItems orders by the keys, then newest, that is last in the file, first
+func compareItems(keys []Key, a []reflect.Value, ai int, b []reflect.Value, bi int) int {
+	for i, k := range keys {
+		if !a[i].IsValid() || !b[i].IsValid() {
+			continue
+		}
+		if c := compare(a[i], b[i]); c != 0 {
+			if k.Descending {
+				return -c
+			}
+			return c
+		}
+	}
+	switch {
+	case ai > bi:
+		return -1
+	case ai < bi:
+		return 1
+	}
+	return 0
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// compare orders values of the same type, absent values first
+func compare(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		// By what they point to, so e.g. a *time.Time compares as a time
+		// rather than by its String, unless only the pointer is a Stringer
+		if !a.Type().Implements(stringerType) || a.Elem().Type().Implements(stringerType) {
+			return compare(a.Elem(), b.Elem())
+		}
+	}
+	switch v := a.Interface().(type) {
+	case netip.Addr:
+		return v.Compare(b.Interface().(netip.Addr))
+	case time.Time:
+		w := b.Interface().(time.Time)
+		switch {
+		case v.Before(w):
+			return -1
+		case v.After(w):
+			return 1
+		}
+		return 0
+	case []byte:
+		return bytes.Compare(v, b.Bytes())
+	}
+	if a.Type().Implements(stringerType) {
+		return strings.Compare(a.Interface().(fmt.Stringer).String(), b.Interface().(fmt.Stringer).String())
+	}
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case a.Int() < b.Int():
+			return -1
+		case a.Int() > b.Int():
+			return 1
+		}
+		return 0
+	}
+	// Anything else, like a client ID, by its JSON
+	ja, _ := json.Marshal(a.Interface())
+	jb, _ := json.Marshal(b.Interface())
+	return bytes.Compare(ja, jb)
+}
@@ -0,0 +1,219 @@
+// Package reservations pins the clients of leases to their addresses, as
+// host declarations for dhcpd.conf and dhcpd6.conf or as Kea reservations.
+package reservations
+
+import (
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/hosts"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// DHCPv4Host reserves addresses for a client identified by its MAC, or
+// otherwise its client identifier
+type DHCPv4Host struct {
+	Name         string // of the declaration, unique
+	Hostname     string // of the client, if it has one
+	HardwareAddr net.HardwareAddr
+	ClientID     []byte       // only when there's no MAC
+	Addrs        []netip.Addr // in the order of their leases, newest last
+}
+
+// DHCPv6Host reserves addresses and delegated prefixes for a DUID
+type DHCPv6Host struct {
+	Name     string // of the declaration, unique
+	Hostname string // of the client, if it has one
+	DUID     *duid.DUID
+	Addrs    []netip.Addr
+	Prefixes []netip.Prefix
+}
+
+// CurrentDHCPv4 is the newest of the leases of each address, given in file
+// order, that is active
+func CurrentDHCPv4(leases []dhcpd.DHCPv4Lease) []dhcpd.DHCPv4Lease {
+	newest := map[netip.Addr]int{}
+	for i, lease := range leases {
+		newest[lease.IP] = i
+	}
+	var res []dhcpd.DHCPv4Lease
+	for i, lease := range leases {
+		if newest[lease.IP] == i && active(lease.BindingState) {
+			res = append(res, lease)
+		}
+	}
+	return res
+}
+
+// CurrentDHCPv6 is the leases holding the newest active binding of any of
+// their addresses, given in file order, with only those addresses
+func CurrentDHCPv6(leases []dhcpd6.DHCPv6Lease) []dhcpd6.DHCPv6Lease {
+	newest := map[netip.Prefix]int{}
+	for i, lease := range leases {
+		for _, addr := range lease.Addrs {
+			newest[addr.Prefix()] = i
+		}
+	}
+	var res []dhcpd6.DHCPv6Lease
+	for i, lease := range leases {
+		var addrs []*dhcpd6.DHCPv6LeaseAddr
+		for _, addr := range lease.Addrs {
+			if newest[addr.Prefix()] == i && active(addr.BindingState) {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) > 0 {
+			lease.Addrs = addrs
+			res = append(res, lease)
+		}
+	}
+	return res
+}
+
+func active(state string) bool {
+	return state == "active" || state == "bootp"
+}
+
+// Collect reserves the addresses of the leases, given in file order, for
+// their clients. A v4 client is named by the ddns-fwd-name or
+// client-hostname of its newest lease, and a v6 client after the v4 client
+// with the same MAC or RFC 4361 DUID, or else by its MAC or DUID. Names are
+// unique among the hosts of each family.
+func Collect(v4leases []dhcpd.DHCPv4Lease, v6leases []dhcpd6.DHCPv6Lease) ([]*DHCPv4Host, []*DHCPv6Host) {
+	v4hosts := map[string]*DHCPv4Host{}
+	var v4order []string
+	names := map[string]string{} // by MAC and by DUID
+	for i := range v4leases {
+		lease := &v4leases[i]
+		mac, hasMAC := inventory.DHCPv4HardwareAddr(lease)
+		var key string
+		switch {
+		case hasMAC:
+			key = mac.String()
+		case len(lease.UID) > 0:
+			key = hex.EncodeToString(lease.UID)
+		default:
+			continue
+		}
+		h, ok := v4hosts[key]
+		if !ok {
+			h = &DHCPv4Host{}
+			if hasMAC {
+				h.HardwareAddr = mac
+			} else {
+				h.ClientID = lease.UID
+			}
+			v4hosts[key] = h
+			v4order = append(v4order, key)
+		}
+		h.Addrs = addAddr(h.Addrs, lease.IP)
+		if name := hostname(lease); name != "" {
+			h.Hostname = name
+			names[key] = name
+			if lease.ClientID != nil && lease.ClientID.DUID() != nil {
+				names[lease.ClientID.DUID().String()] = name
+			}
+		}
+	}
+
+	v6hosts := map[string]*DHCPv6Host{}
+	var v6order []string
+	for i := range v6leases {
+		lease := &v6leases[i]
+		if lease.DUID == nil {
+			continue
+		}
+		key := lease.DUID.String()
+		h, ok := v6hosts[key]
+		if !ok {
+			h = &DHCPv6Host{DUID: lease.DUID}
+			v6hosts[key] = h
+			v6order = append(v6order, key)
+		}
+		if mac, ok := inventory.DHCPv6HardwareAddr(lease); ok && names[mac.String()] != "" {
+			h.Hostname = names[mac.String()]
+		} else if names[key] != "" {
+			h.Hostname = names[key]
+		}
+		for _, addr := range lease.Addrs {
+			if addr.PrefixLen != 0 {
+				h.Prefixes = addPrefix(h.Prefixes, addr.Prefix())
+			} else if lease.Type == dhcpd6.DHCPv6LeaseTypeNonTemporary {
+				// Temporary addresses change by design and aren't pinned
+				h.Addrs = addAddr(h.Addrs, addr.IP)
+			}
+		}
+	}
+
+	var v4res []*DHCPv4Host
+	taken := map[string]bool{}
+	for _, key := range v4order {
+		h := v4hosts[key]
+		h.Name = unique(taken, h.Hostname, key)
+		v4res = append(v4res, h)
+	}
+	var v6res []*DHCPv6Host
+	taken = map[string]bool{}
+	for _, key := range v6order {
+		h := v6hosts[key]
+		if len(h.Addrs) == 0 && len(h.Prefixes) == 0 {
+			continue
+		}
+		h.Name = unique(taken, h.Hostname, key)
+		v6res = append(v6res, h)
+	}
+	sort.Slice(v4res, func(i, j int) bool { return v4res[i].Name < v4res[j].Name })
+	sort.Slice(v6res, func(i, j int) bool { return v6res[i].Name < v6res[j].Name })
+	return v4res, v6res
+}
+
+// hostname is the first label of the lease's name, as a host declaration
+// names the host rather than its domain
+func hostname(lease *dhcpd.DHCPv4Lease) string {
+	name := lease.DDNSFwdName
+	if name == "" {
+		name = lease.ClientHostname
+	}
+	label, _, _ := strings.Cut(hosts.Hostname(name), ".")
+	return label
+}
+
+// unique names a host after its key when it has no name, and suffixes it
+// with the end of its key when the name is already taken
+func unique(taken map[string]bool, name, key string) string {
+	id := strings.ReplaceAll(key, ":", "")
+	if name == "" {
+		name = "host-" + id
+	} else if taken[name] {
+		if len(id) > 6 {
+			id = id[len(id)-6:]
+		}
+		name += "-" + id
+	}
+	taken[name] = true
+	return name
+}
+
+func addAddr(addrs []netip.Addr, ip netip.Addr) []netip.Addr {
+	for _, a := range addrs {
+		if a == ip {
+			return addrs
+		}
+	}
+	return append(addrs, ip)
+}
+
+func addPrefix(prefixes []netip.Prefix, prefix netip.Prefix) []netip.Prefix {
+	for _, p := range prefixes {
+		if p == prefix {
+			return prefixes
+		}
+	}
+	return append(prefixes, prefix)
+}
@@ -0,0 +1,144 @@
+package reservations
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+func TestCollect(t *testing.T) {
+	ll, err := duid.ParseDUIDString("00:03:00:01:20:c9:d0:a4:af:be")
+	if err != nil {
+		t.Fatal(err)
+	}
+	en, err := duid.ParseDUIDString("00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4leases := CurrentDHCPv4([]dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.107"), BindingState: "active", HardwareEthernet: "8c:dc:d4:2b:ec:6c", ClientHostname: "wopr"},
+		// A second address of the same client, its newest
+		{IP: netip.MustParseAddr("192.168.1.112"), BindingState: "active", HardwareEthernet: "8c:dc:d4:2b:ec:6c"},
+		{IP: netip.MustParseAddr("192.168.1.108"), BindingState: "active", HardwareEthernet: "20:c9:d0:a4:af:be", ClientHostname: "Joe's iPhone"},
+		{IP: netip.MustParseAddr("192.168.1.109"), BindingState: "active", HardwareEthernet: "00:11:22:33:44:55", DDNSFwdName: "wopr.example.com."},
+		{IP: netip.MustParseAddr("192.168.1.110"), BindingState: "active", UID: []byte("joshua")},
+		// Released since
+		{IP: netip.MustParseAddr("192.168.1.111"), BindingState: "active", HardwareEthernet: "00:aa:bb:cc:dd:ee"},
+		{IP: netip.MustParseAddr("192.168.1.111"), BindingState: "released", HardwareEthernet: "00:aa:bb:cc:dd:ee"},
+	})
+	v6leases := CurrentDHCPv6([]dhcpd6.DHCPv6Lease{
+		{Type: dhcpd6.DHCPv6LeaseTypeNonTemporary, DUID: ll, Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8::22c9:d0ff:fea4:afbe"), BindingState: "active"},
+		}},
+		{Type: dhcpd6.DHCPv6LeaseTypeTemporary, DUID: ll, Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8::1234"), BindingState: "active"},
+		}},
+		{Type: dhcpd6.DHCPv6LeaseTypePrefixDelegation, DUID: en, Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8:1::"), PrefixLen: 56, BindingState: "active"},
+			{IP: netip.MustParseAddr("2001:db8:2::"), PrefixLen: 56, BindingState: "expired"},
+		}},
+	})
+	v4hosts, v6hosts := Collect(v4leases, v6leases)
+
+	var b bytes.Buffer
+	if err := WriteDHCPv4Conf(&b, v4hosts); err != nil {
+		t.Fatal(err)
+	}
+	expected := `host host-6a6f73687561 {
+  option dhcp-client-identifier 6a:6f:73:68:75:61;
+  fixed-address 192.168.1.110;
+}
+host joe-s-iphone {
+  hardware ethernet 20:c9:d0:a4:af:be;
+  fixed-address 192.168.1.108;
+}
+host wopr {
+  hardware ethernet 8c:dc:d4:2b:ec:6c;
+  fixed-address 192.168.1.107, 192.168.1.112;
+}
+host wopr-334455 {
+  hardware ethernet 00:11:22:33:44:55;
+  fixed-address 192.168.1.109;
+}
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := WriteDHCPv6Conf(&b, v6hosts); err != nil {
+		t.Fatal(err)
+	}
+	expected = `host host-0002000000090cc084d303000912 {
+  host-identifier option dhcp6.client-id 00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12;
+  fixed-prefix6 2001:db8:1::/56;
+}
+host joe-s-iphone {
+  host-identifier option dhcp6.client-id 00:03:00:01:20:c9:d0:a4:af:be;
+  fixed-address6 2001:db8::22c9:d0ff:fea4:afbe;
+}
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if _, err := WriteKea(&b, v4hosts[1:2], v6hosts[1:]); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{
+  "Dhcp4": {
+    "reservations": [
+      {
+        "hw-address": "20:c9:d0:a4:af:be",
+        "ip-address": "192.168.1.108",
+        "hostname": "joe-s-iphone"
+      }
+    ]
+  },
+  "Dhcp6": {
+    "reservations": [
+      {
+        "duid": "00:03:00:01:20:c9:d0:a4:af:be",
+        "ip-addresses": [
+          "2001:db8::22c9:d0ff:fea4:afbe"
+        ],
+        "hostname": "joe-s-iphone"
+      }
+    ]
+  }
+}
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// Kea takes one address per client
+	b.Reset()
+	warnings, err := WriteKea(&b, v4hosts[2:3], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{
+  "Dhcp4": {
+    "reservations": [
+      {
+        "hw-address": "8c:dc:d4:2b:ec:6c",
+        "ip-address": "192.168.1.112",
+        "hostname": "wopr"
+      }
+    ]
+  }
+}
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+	if len(warnings) != 1 || warnings[0] != "host wopr: reserving only its newest address 192.168.1.112, not 192.168.1.107" {
+		t.Errorf("expected a warning of 192.168.1.107 left out, got %q", warnings)
+	}
+}
@@ -0,0 +1,136 @@
+package reservations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/internal/errwriter"
+)
+
+// WriteDHCPv4Conf writes host declarations for dhcpd.conf, each with one
+// fixed-address listing all of its addresses, as dhcpd takes no other
+func WriteDHCPv4Conf(w io.Writer, hosts []*DHCPv4Host) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		ew.Printf("host %s {\n", h.Name)
+		if h.HardwareAddr != nil {
+			ew.Printf("  hardware ethernet %s;\n", h.HardwareAddr)
+		} else {
+			ew.Printf("  option dhcp-client-identifier %s;\n", colonHex(h.ClientID))
+		}
+		addrs := make([]string, len(h.Addrs))
+		for i, ip := range h.Addrs {
+			addrs[i] = ip.String()
+		}
+		ew.Printf("  fixed-address %s;\n", strings.Join(addrs, ", "))
+		ew.Printf("}\n")
+	}
+	return ew.Err()
+}
+
+// WriteDHCPv6Conf writes host declarations for dhcpd6.conf
+func WriteDHCPv6Conf(w io.Writer, hosts []*DHCPv6Host) error {
+	ew := errwriter.New(w)
+	for _, h := range hosts {
+		ew.Printf("host %s {\n", h.Name)
+		ew.Printf("  host-identifier option dhcp6.client-id %s;\n", h.DUID)
+		for _, ip := range h.Addrs {
+			ew.Printf("  fixed-address6 %s;\n", ip)
+		}
+		for _, prefix := range h.Prefixes {
+			ew.Printf("  fixed-prefix6 %s;\n", prefix)
+		}
+		ew.Printf("}\n")
+	}
+	return ew.Err()
+}
+
+func colonHex(b []byte) string {
+	// A net.HardwareAddr of any length formats as colon separated hex
+	return net.HardwareAddr(b).String()
+}
+
+// KeaDHCPv4Reservation is a reservation of Kea's Dhcp4 configuration, see:
+// https://kea.readthedocs.io/en/latest/arm/dhcp4-srv.html#host-reservations-in-dhcpv4
+type KeaDHCPv4Reservation struct {
+	HWAddress string `json:"hw-address,omitempty"`
+	ClientID  string `json:"client-id,omitempty"`
+	IPAddress string `json:"ip-address"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+// KeaDHCPv6Reservation is a reservation of Kea's Dhcp6 configuration, see:
+// https://kea.readthedocs.io/en/latest/arm/dhcp6-srv.html#host-reservations-in-dhcpv6
+type KeaDHCPv6Reservation struct {
+	DUID        string   `json:"duid"`
+	IPAddresses []string `json:"ip-addresses,omitempty"`
+	Prefixes    []string `json:"prefixes,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+}
+
+// KeaDHCPv4Reservations reserves the newest address of each host. Kea
+// allows a v4 reservation only one address and a client only one
+// reservation, so the others are left out with a warning.
+func KeaDHCPv4Reservations(hosts []*DHCPv4Host) ([]KeaDHCPv4Reservation, []string) {
+	res := []KeaDHCPv4Reservation{}
+	var warnings []string
+	for _, h := range hosts {
+		if len(h.Addrs) == 0 {
+			continue
+		}
+		newest := h.Addrs[len(h.Addrs)-1]
+		r := KeaDHCPv4Reservation{IPAddress: newest.String(), Hostname: h.Hostname}
+		if h.HardwareAddr != nil {
+			r.HWAddress = h.HardwareAddr.String()
+		} else {
+			r.ClientID = colonHex(h.ClientID)
+		}
+		res = append(res, r)
+		for _, ip := range h.Addrs[:len(h.Addrs)-1] {
+			warnings = append(warnings, fmt.Sprintf("host %s: reserving only its newest address %s, not %s", h.Name, newest, ip))
+		}
+	}
+	return res, warnings
+}
+
+// KeaDHCPv6Reservations reserves the addresses and prefixes of each host
+func KeaDHCPv6Reservations(hosts []*DHCPv6Host) []KeaDHCPv6Reservation {
+	res := []KeaDHCPv6Reservation{}
+	for _, h := range hosts {
+		r := KeaDHCPv6Reservation{DUID: h.DUID.String(), Hostname: h.Hostname}
+		for _, ip := range h.Addrs {
+			r.IPAddresses = append(r.IPAddresses, ip.String())
+		}
+		for _, prefix := range h.Prefixes {
+			r.Prefixes = append(r.Prefixes, prefix.String())
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+type keaReservations struct {
+	Reservations interface{} `json:"reservations"`
+}
+
+// WriteKea writes the reservations as global reservations of Kea's Dhcp4
+// and Dhcp6 configurations, omitting a family without hosts, and returns
+// the warnings of the addresses left out
+func WriteKea(w io.Writer, v4hosts []*DHCPv4Host, v6hosts []*DHCPv6Host) ([]string, error) {
+	config := map[string]keaReservations{}
+	var warnings []string
+	if len(v4hosts) > 0 {
+		var v4 []KeaDHCPv4Reservation
+		v4, warnings = KeaDHCPv4Reservations(v4hosts)
+		config["Dhcp4"] = keaReservations{v4}
+	}
+	if len(v6hosts) > 0 {
+		config["Dhcp6"] = keaReservations{KeaDHCPv6Reservations(v6hosts)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return warnings, enc.Encode(config)
+}
@@ -0,0 +1,99 @@
+package sources
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+)
+
+// Both peers of a dhcpd failover pair record every binding of their shared
+// pools, updating each other as they change, so their lease files hold two
+// copies of each address: the same once they've caught up, or one stale
+// while they can't communicate.
+
+// changed is when the binding of a lease last changed: when it was granted
+// or renewed, or when it ended if it no longer holds
+func changed(lease *dhcpd.DHCPv4Lease) time.Time {
+	var t time.Time
+	times := []*time.Time{lease.Starts, lease.CLTT}
+	if lease.BindingState != "active" && lease.BindingState != "bootp" {
+		times = append(times, lease.Ends)
+	}
+	for _, u := range times {
+		if u != nil && u.After(t) {
+			t = *u
+		}
+	}
+	return t
+}
+
+// DedupeDHCPv4 drops the copy of each address held by both peers of a
+// failover pair, given the leases of each source in file order. The records
+// of an address are kept from the peer whose latest record of it changed
+// last, or the primary when they changed together, and dropped from the
+// other. The leases themselves aren't modified.
+func DedupeDHCPv4(srcs []*Source, leases [][]dhcpd.DHCPv4Lease) [][]dhcpd.DHCPv4Lease {
+	type pair struct{ primary, secondary int }
+	pairs := map[string]*pair{}
+	for i, s := range srcs {
+		if s.Role == "" {
+			continue
+		}
+		p, ok := pairs[s.Site]
+		if !ok {
+			p = &pair{-1, -1}
+			pairs[s.Site] = p
+		}
+		if s.Role == RolePrimary {
+			p.primary = i
+		} else {
+			p.secondary = i
+		}
+	}
+
+	drop := make([]map[netip.Addr]bool, len(srcs))
+	for _, p := range pairs {
+		if p.primary < 0 || p.secondary < 0 {
+			continue
+		}
+		primary, secondary := latest(leases[p.primary]), latest(leases[p.secondary])
+		for ip, s := range secondary {
+			pr, ok := primary[ip]
+			if !ok {
+				continue
+			}
+			loser := p.secondary
+			if changed(s).After(changed(pr)) {
+				loser = p.primary
+			}
+			if drop[loser] == nil {
+				drop[loser] = map[netip.Addr]bool{}
+			}
+			drop[loser][ip] = true
+		}
+	}
+
+	res := make([][]dhcpd.DHCPv4Lease, len(leases))
+	for i, part := range leases {
+		if drop[i] == nil {
+			res[i] = part
+			continue
+		}
+		for _, lease := range part {
+			if !drop[i][lease.IP] {
+				res[i] = append(res[i], lease)
+			}
+		}
+	}
+	return res
+}
+
+// latest is the last lease of each address in the file
+func latest(leases []dhcpd.DHCPv4Lease) map[netip.Addr]*dhcpd.DHCPv4Lease {
+	res := map[netip.Addr]*dhcpd.DHCPv4Lease{}
+	for i := range leases {
+		res[leases[i].IP] = &leases[i]
+	}
+	return res
+}
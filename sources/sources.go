@@ -0,0 +1,180 @@
+// Package sources describes the lease files of several DHCP servers, across
+// sites and failover pairs, so they can be shown as one estate.
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Formats of lease files
+const (
+	FormatISC     = "isc"
+	FormatKea     = "kea"
+	FormatDnsmasq = "dnsmasq"
+)
+
+// Roles of the servers of a dhcpd failover pair
+const (
+	RolePrimary   = "primary"
+	RoleSecondary = "secondary"
+)
+
+// Config is read from a JSON file, e.g.
+//
+//	{
+//	  "sources": [
+//	    {"name": "dc1-a", "path": "/srv/dc1-a/dhcpd.leases", "family": 4, "site": "dc1", "role": "primary"},
+//	    {"name": "dc1-b", "path": "/srv/dc1-b/dhcpd.leases", "family": 4, "site": "dc1", "role": "secondary"},
+//	    {"name": "dc1-v6", "path": "/srv/dc1-a/dhcpd6.leases", "family": 6, "site": "dc1"},
+//	    {"name": "lab", "path": "/srv/lab/dnsmasq.leases", "format": "dnsmasq", "site": "lab"}
+//	  ]
+//	}
+type Config struct {
+	Sources []*Source `json:"sources"`
+}
+
+// Source is a lease file of a server
+type Source struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// 4 or 6. dnsmasq keeps both families in one file, so it's both when 0.
+	Family int    `json:"family,omitempty"`
+	Format string `json:"format,omitempty"` // isc, the default, kea or dnsmasq
+	Site   string `json:"site,omitempty"`
+	// primary or secondary of a dhcpd failover pair, whose peers at a site
+	// share their v4 leases, see DedupeDHCPv4
+	Role string `json:"role,omitempty"`
+}
+
+// HasFamily reports whether the source holds leases of the family
+func (s *Source) HasFamily(family int) bool {
+	return s.Family == family || s.Family == 0
+}
+
+func (s *Source) validate() error {
+	if s.Name == "" {
+		return errors.New("source without a name")
+	}
+	if s.Path == "" {
+		return fmt.Errorf("source %s: expected a path", s.Name)
+	}
+	if s.Format == "" {
+		s.Format = FormatISC
+	}
+	switch s.Format {
+	case FormatISC, FormatKea, FormatDnsmasq:
+	default:
+		return fmt.Errorf("source %s: unknown format %s", s.Name, s.Format)
+	}
+	switch {
+	case s.Family == 0 && s.Format != FormatDnsmasq:
+		return fmt.Errorf("source %s: expected a family of 4 or 6", s.Name)
+	case s.Family != 0 && s.Family != 4 && s.Family != 6:
+		return fmt.Errorf("source %s: expected a family of 4 or 6, got %d", s.Name, s.Family)
+	}
+	switch s.Role {
+	case "":
+	case RolePrimary, RoleSecondary:
+		if s.Format != FormatISC || s.Family != 4 || s.Site == "" {
+			return fmt.Errorf("source %s: only dhcpd's v4 lease files at a site have failover roles", s.Name)
+		}
+	default:
+		return fmt.Errorf("source %s: unknown role %s", s.Name, s.Role)
+	}
+	return nil
+}
+
+// Validate checks the sources, filling in defaults. Names must be unique
+// within a family, as they tag the leases, and a site has at most one
+// failover pair.
+func (c *Config) Validate() error {
+	names := map[int]map[string]bool{4: {}, 6: {}}
+	roles := map[[2]string]string{}
+	for _, s := range c.Sources {
+		if err := s.validate(); err != nil {
+			return err
+		}
+		for _, family := range []int{4, 6} {
+			seen := names[family]
+			if !s.HasFamily(family) {
+				continue
+			}
+			if seen[s.Name] {
+				return fmt.Errorf("source %s: duplicate name of v%d leases", s.Name, family)
+			}
+			seen[s.Name] = true
+		}
+		if s.Role == "" {
+			continue
+		}
+		key := [2]string{s.Site, s.Role}
+		if other, ok := roles[key]; ok {
+			return fmt.Errorf("source %s: site %s already has the %s %s", s.Name, s.Site, s.Role, other)
+		}
+		roles[key] = s.Name
+	}
+	return nil
+}
+
+// ParseConfig decodes and validates a configuration, filling in defaults
+func ParseConfig(b []byte) (*Config, error) {
+	var conf Config
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// ParseSource reads a source from its comma separated key=value fields, the
+// keys of its JSON, e.g. name=dc1-a,path=/srv/dc1-a/dhcpd.leases,family=4,site=dc1,role=primary
+// It's validated along with the others of its Config.
+func ParseSource(spec string) (*Source, error) {
+	var s Source
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, fmt.Errorf("source %s: expected key=value, got %s", spec, field)
+		}
+		switch key {
+		case "name":
+			s.Name = value
+		case "path":
+			s.Path = value
+		case "family":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("source %s: family: %w", spec, err)
+			}
+			s.Family = n
+		case "format":
+			s.Format = value
+		case "site":
+			s.Site = value
+		case "role":
+			s.Role = value
+		default:
+			return nil, fmt.Errorf("source %s: unknown key %s", spec, key)
+		}
+	}
+	return &s, nil
+}
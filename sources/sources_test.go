@@ -0,0 +1,103 @@
+package sources
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, input := range []string{
+		`{"sources": [{"path": "/var/lib/dhcp/dhcpd.leases", "family": 4}]}`,
+		`{"sources": [{"name": "a", "family": 4}]}`,
+		`{"sources": [{"name": "a", "path": "dhcpd.leases"}]}`,
+		`{"sources": [{"name": "a", "path": "dhcpd.leases", "family": 5}]}`,
+		`{"sources": [{"name": "a", "path": "leases.csv", "family": 4, "format": "csv"}]}`,
+		`{"sources": [{"name": "a", "path": "dhcpd.leases", "family": 4, "role": "primary"}]}`,
+		`{"sources": [{"name": "a", "path": "dhcpd6.leases", "family": 6, "site": "dc1", "role": "primary"}]}`,
+		`{"sources": [{"name": "a", "path": "a.leases", "family": 4}, {"name": "a", "path": "b.leases", "family": 4}]}`,
+		`{"sources": [{"name": "a", "path": "a.leases", "family": 6}, {"name": "a", "path": "dnsmasq.leases", "format": "dnsmasq"}]}`,
+		`{"sources": [{"name": "a", "path": "a.leases", "family": 4, "site": "dc1", "role": "primary"}, {"name": "b", "path": "b.leases", "family": 4, "site": "dc1", "role": "primary"}]}`,
+	} {
+		if _, err := ParseConfig([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+	conf, err := ParseConfig([]byte(`{"sources": [{"name": "lab", "path": "dnsmasq.leases", "format": "dnsmasq"}, {"name": "a", "path": "a.leases", "family": 4}, {"name": "a", "path": "a6.leases", "family": 6}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := conf.Sources[0]; !s.HasFamily(4) || !s.HasFamily(6) {
+		t.Errorf("expected a dnsmasq source of both families, got %+v", s)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	s, err := ParseSource("name=dc1-a, path=/srv/dc1-a/dhcpd.leases, family=4, site=dc1, role=primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Source{Name: "dc1-a", Path: "/srv/dc1-a/dhcpd.leases", Family: 4, Site: "dc1", Role: RolePrimary}
+	if *s != expected {
+		t.Errorf("expected %+v, got %+v", expected, *s)
+	}
+	for _, spec := range []string{"name", "name=a,colour=red", "family=four"} {
+		if _, err := ParseSource(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestDedupeDHCPv4(t *testing.T) {
+	srcs := []*Source{
+		{Name: "dc1-a", Site: "dc1", Role: RolePrimary},
+		{Name: "dc1-b", Site: "dc1", Role: RoleSecondary},
+		{Name: "dc2"},
+	}
+	t1 := time.Date(2021, time.December, 25, 22, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	a, b, c := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.3")
+	leases := [][]dhcpd.DHCPv4Lease{
+		{
+			{IP: a, Starts: &t1, BindingState: "active"},
+			{IP: b, Starts: &t1, BindingState: "active"},
+			{IP: c, Starts: &t1, BindingState: "active"},
+		},
+		{
+			// Caught up
+			{IP: a, Starts: &t1, BindingState: "active"},
+			// Renewed while the primary was unreachable
+			{IP: b, Starts: &t1, BindingState: "active"},
+			{IP: b, Starts: &t2, BindingState: "active"},
+		},
+		{
+			{IP: a, Starts: &t1, BindingState: "active"},
+		},
+	}
+	res := DedupeDHCPv4(srcs, leases)
+	ips := func(leases []dhcpd.DHCPv4Lease) []netip.Addr {
+		var res []netip.Addr
+		for _, lease := range leases {
+			res = append(res, lease.IP)
+		}
+		return res
+	}
+	for i, expected := range [][]netip.Addr{{a, c}, {b, b}, {a}} {
+		got := ips(res[i])
+		if len(got) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", srcs[i].Name, expected, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != expected[j] {
+				t.Errorf("%s: expected %v, got %v", srcs[i].Name, expected, got)
+				break
+			}
+		}
+	}
+	if len(leases[0]) != 3 {
+		t.Error("expected the leases to be unmodified")
+	}
+}
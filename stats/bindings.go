@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"net/netip"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+)
+
+// siteAddr is an address at a site, as sites may each use the same private
+// address space
+type siteAddr struct {
+	site string
+	addr netip.Addr
+}
+
+// DHCPv4States counts the latest binding state of each leased address, given
+// the leases in the order they appear in the lease file.
+func DHCPv4States(leases []dhcpd.DHCPv4Lease) map[string]uint64 {
+	latest := map[siteAddr]string{}
+	for _, lease := range leases {
+		latest[siteAddr{lease.Site, lease.IP}] = lease.BindingState
+	}
+	counts := map[string]uint64{}
+	for _, state := range latest {
+		counts[state]++
+	}
+	return counts
+}
+
+// DHCPv6States counts the latest binding state of each leased address or
+// prefix by the type of lease, given the leases in the order they appear in
+// the lease file.
+func DHCPv6States(leases []dhcpd6.DHCPv6Lease) map[dhcpd6.DHCPv6LeaseType]map[string]uint64 {
+	type binding struct {
+		typ   dhcpd6.DHCPv6LeaseType
+		state string
+	}
+	type sitePrefix struct {
+		site   string
+		prefix netip.Prefix
+	}
+	latest := map[sitePrefix]binding{}
+	for _, lease := range leases {
+		for _, addr := range lease.Addrs {
+			latest[sitePrefix{lease.Site, addr.Prefix()}] = binding{lease.Type, addr.BindingState}
+		}
+	}
+	counts := map[dhcpd6.DHCPv6LeaseType]map[string]uint64{}
+	for _, b := range latest {
+		if counts[b.typ] == nil {
+			counts[b.typ] = map[string]uint64{}
+		}
+		counts[b.typ][b.state]++
+	}
+	return counts
+}
+
+// DHCPv4VendorClasses counts the active leases by vendor-class-identifier,
+// given the leases in the order they appear in the lease file.
+func DHCPv4VendorClasses(leases []dhcpd.DHCPv4Lease) map[string]uint64 {
+	latest := map[siteAddr]*dhcpd.DHCPv4Lease{}
+	for i := range leases {
+		latest[siteAddr{leases[i].Site, leases[i].IP}] = &leases[i]
+	}
+	counts := map[string]uint64{}
+	for _, lease := range latest {
+		if lease.BindingState == "active" {
+			counts[lease.VendorClassIdentifier]++
+		}
+	}
+	return counts
+}
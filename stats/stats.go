@@ -0,0 +1,236 @@
+package stats
+
+import (
+	"math"
+	"math/big"
+	"net/netip"
+	"sort"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+)
+
+// Usage counts the addresses (or for prefix6, the prefixes) of a range
+// declared in dhcpd.conf by the binding state of their latest lease. Each
+// address is counted once, so the states sum to the total: addresses which
+// were never leased are counted as free, while expired and released ones,
+// though dhcpd may lease them again, are counted apart. When the leases are
+// from several sites, which may each use the same private address space,
+// each site has its own usage of the range.
+type Usage struct {
+	Site          string       `json:"site,omitempty"`
+	SharedNetwork string       `json:"shared-network,omitempty"`
+	Subnet        netip.Prefix `json:"subnet"`
+	Type          string       `json:"type"` // range, range6 or prefix6
+	Start         netip.Addr   `json:"start"`
+	End           netip.Addr   `json:"end"`
+	PrefixLen     int          `json:"prefix-len,omitempty"` // only for prefix6
+	// Sizes saturate at the largest uint64, a /64 range6 has one more address
+	Total     uint64 `json:"total"`
+	Active    uint64 `json:"active"`
+	Free      uint64 `json:"free"`
+	Backup    uint64 `json:"backup"`
+	Expired   uint64 `json:"expired"`
+	Released  uint64 `json:"released"`
+	Abandoned uint64 `json:"abandoned"`
+	Reserved  uint64 `json:"reserved"`
+}
+
+// Utilization is the percentage of the range which is actively leased
+func (u *Usage) Utilization() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return 100 * float64(u.Active) / float64(u.Total)
+}
+
+func (u *Usage) count(state string) {
+	switch state {
+	case "active", "bootp":
+		u.Active++
+	case "backup":
+		u.Backup++
+	case "expired":
+		u.Expired++
+	case "released":
+		u.Released++
+	case "abandoned":
+		u.Abandoned++
+	case "reserved":
+		u.Reserved++
+	}
+}
+
+func (u *Usage) finish() {
+	used := u.Active + u.Backup + u.Expired + u.Released + u.Abandoned + u.Reserved
+	if used < u.Total {
+		u.Free = u.Total - used
+	}
+}
+
+// bindings is the latest binding state of each address, sorted so that the
+// leases within a range can be found without scanning all of them.
+type bindings struct {
+	addrs  []netip.Addr
+	states map[netip.Addr]string
+}
+
+// set records the binding state of an address, leases appear in the lease
+// file in the order they were written so later ones supersede earlier ones.
+func (b *bindings) set(addr netip.Addr, state string) {
+	if b.states == nil {
+		b.states = map[netip.Addr]string{}
+	}
+	if _, ok := b.states[addr]; !ok {
+		b.addrs = append(b.addrs, addr)
+	}
+	b.states[addr] = state
+}
+
+func (b *bindings) sort() {
+	sort.Slice(b.addrs, func(i, j int) bool {
+		return b.addrs[i].Less(b.addrs[j])
+	})
+}
+
+func (b *bindings) count(u *Usage) {
+	i := sort.Search(len(b.addrs), func(i int) bool {
+		return !b.addrs[i].Less(u.Start)
+	})
+	for ; i < len(b.addrs) && b.addrs[i].Compare(u.End) <= 0; i++ {
+		u.count(b.states[b.addrs[i]])
+	}
+}
+
+// siteBindings are the bindings of each site the leases are from
+type siteBindings map[string]*bindings
+
+func (s siteBindings) set(site string, addr netip.Addr, state string) {
+	b, ok := s[site]
+	if !ok {
+		b = &bindings{}
+		s[site] = b
+	}
+	b.set(addr, state)
+}
+
+func (s siteBindings) count(site string, u *Usage) {
+	if b, ok := s[site]; ok {
+		b.count(u)
+	}
+}
+
+// sites sorts the bindings of each site and lists the sites in order, or
+// just the unnamed one if there are none
+func sites(all ...siteBindings) []string {
+	set := map[string]bool{}
+	for _, s := range all {
+		for site, b := range s {
+			b.sort()
+			set[site] = true
+		}
+	}
+	if len(set) == 0 {
+		return []string{""}
+	}
+	res := make([]string, 0, len(set))
+	for site := range set {
+		res = append(res, site)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func newUsage(scope dhcpdconf.Scope, site string) *Usage {
+	u := &Usage{Site: site}
+	if scope.SharedNetwork != nil {
+		u.SharedNetwork = scope.SharedNetwork.Name
+	}
+	if scope.Subnet != nil {
+		u.Subnet = scope.Subnet.Prefix
+	}
+	return u
+}
+
+// DHCPv4 computes the usage of each range in conf, given the leases in the
+// order they appear in the lease file.
+func DHCPv4(conf *dhcpdconf.Config, leases []dhcpd.DHCPv4Lease) []*Usage {
+	b := siteBindings{}
+	for _, lease := range leases {
+		b.set(lease.Site, lease.IP, lease.BindingState)
+	}
+	sites := sites(b)
+
+	var res []*Usage
+	conf.Walk(func(scope dhcpdconf.Scope) {
+		if scope.Range == nil || !scope.Range.Start.Is4() {
+			return
+		}
+		for _, site := range sites {
+			u := newUsage(scope, site)
+			u.Type = "range"
+			u.Start, u.End = scope.Range.Start, scope.Range.End
+			u.Total = span(u.Start, u.End, 0)
+			b.count(site, u)
+			u.finish()
+			res = append(res, u)
+		}
+	})
+	return res
+}
+
+// DHCPv6 computes the usage of each range6 and prefix6 in conf, given the
+// leases in the order they appear in the lease file.
+func DHCPv6(conf *dhcpdconf.Config, leases []dhcpd6.DHCPv6Lease) []*Usage {
+	addrs, prefixes := siteBindings{}, siteBindings{}
+	for _, lease := range leases {
+		for _, addr := range lease.Addrs {
+			if addr.PrefixLen != 0 {
+				prefixes.set(lease.Site, addr.IP, addr.BindingState)
+			} else {
+				addrs.set(lease.Site, addr.IP, addr.BindingState)
+			}
+		}
+	}
+	sites := sites(addrs, prefixes)
+
+	var res []*Usage
+	conf.Walk(func(scope dhcpdconf.Scope) {
+		for _, site := range sites {
+			switch {
+			case scope.Range != nil && scope.Range.Start.Is6():
+				u := newUsage(scope, site)
+				u.Type = "range6"
+				u.Start, u.End = scope.Range.Start, scope.Range.End
+				u.Total = span(u.Start, u.End, 0)
+				addrs.count(site, u)
+				u.finish()
+				res = append(res, u)
+			case scope.Prefix6 != nil:
+				u := newUsage(scope, site)
+				u.Type = "prefix6"
+				u.Start, u.End = scope.Prefix6.Start, scope.Prefix6.End
+				u.PrefixLen = scope.Prefix6.Bits
+				u.Total = span(u.Start, u.End, 128-u.PrefixLen)
+				prefixes.count(site, u)
+				u.finish()
+				res = append(res, u)
+			}
+		}
+	})
+	return res
+}
+
+// span counts the blocks of 2^shift addresses from start to end inclusive
+func span(start, end netip.Addr, shift int) uint64 {
+	s := new(big.Int).SetBytes(start.AsSlice())
+	e := new(big.Int).SetBytes(end.AsSlice())
+	n := new(big.Int).Sub(e, s)
+	n.Rsh(n, uint(shift))
+	n.Add(n, big.NewInt(1))
+	if !n.IsUint64() {
+		return math.MaxUint64
+	}
+	return n.Uint64()
+}
@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpdconf"
+)
+
+func TestDHCPv4(t *testing.T) {
+	conf, err := dhcpdconf.Parse(strings.NewReader(`
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.109;
+}
+`))
+	if err != nil {
+		t.Fatalf("parse conf: %v", err)
+	}
+	leases := []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.100"), BindingState: "active"},
+		{IP: netip.MustParseAddr("192.168.1.101"), BindingState: "active"},
+		// superseded by the lease after it
+		{IP: netip.MustParseAddr("192.168.1.102"), BindingState: "active"},
+		{IP: netip.MustParseAddr("192.168.1.102"), BindingState: "expired"},
+		{IP: netip.MustParseAddr("192.168.1.103"), BindingState: "abandoned"},
+		{IP: netip.MustParseAddr("192.168.1.104"), BindingState: "backup"},
+		{IP: netip.MustParseAddr("192.168.1.105"), BindingState: "released"},
+		{IP: netip.MustParseAddr("192.168.1.106"), BindingState: "free"},
+		// outside of the range
+		{IP: netip.MustParseAddr("192.168.1.5"), BindingState: "active"},
+	}
+
+	usage := DHCPv4(conf, leases)
+	if len(usage) != 1 {
+		t.Fatalf("expected one range but was %d", len(usage))
+	}
+	u := usage[0]
+	if u.Subnet != netip.MustParsePrefix("192.168.1.0/24") {
+		t.Errorf("expected subnet 192.168.1.0/24 but was %s", u.Subnet)
+	}
+	expected := Usage{Total: 10, Active: 2, Free: 4, Backup: 1, Expired: 1, Released: 1, Abandoned: 1}
+	if u.Total != expected.Total || u.Active != expected.Active || u.Free != expected.Free ||
+		u.Backup != expected.Backup || u.Expired != expected.Expired || u.Released != expected.Released ||
+		u.Abandoned != expected.Abandoned {
+		t.Errorf("expected %+v but was %+v", expected, *u)
+	}
+	if sum := u.Active + u.Free + u.Backup + u.Expired + u.Released + u.Abandoned + u.Reserved; sum != u.Total {
+		t.Errorf("expected the states to sum to %d but was %d", u.Total, sum)
+	}
+	if u.Utilization() != 20 {
+		t.Errorf("expected 20%% utilization but was %f", u.Utilization())
+	}
+}
+
+func TestDHCPv6(t *testing.T) {
+	conf, err := dhcpdconf.Parse(strings.NewReader(`
+subnet6 2001:db8::/64 {
+  range6 2001:db8::/64;
+  prefix6 2001:db8:0:100:: 2001:db8:0:f00:: /56;
+}
+`))
+	if err != nil {
+		t.Fatalf("parse conf: %v", err)
+	}
+	leases := []dhcpd6.DHCPv6Lease{
+		{
+			Type: dhcpd6.DHCPv6LeaseTypeNonTemporary,
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+				{IP: netip.MustParseAddr("2001:db8::1"), BindingState: "active"},
+			},
+		},
+		{
+			Type: dhcpd6.DHCPv6LeaseTypePrefixDelegation,
+			Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+				{IP: netip.MustParseAddr("2001:db8:0:200::"), PrefixLen: 56, BindingState: "active"},
+			},
+		},
+	}
+
+	usage := DHCPv6(conf, leases)
+	if len(usage) != 2 {
+		t.Fatalf("expected range6 and prefix6 but was %d", len(usage))
+	}
+	if usage[0].Type != "range6" || usage[0].Active != 1 {
+		t.Errorf("expected range6 with one active address but was %+v", *usage[0])
+	}
+	if usage[1].Type != "prefix6" || usage[1].Total != 15 || usage[1].Active != 1 || usage[1].Free != 14 {
+		t.Errorf("expected prefix6 with 15 prefixes and one active but was %+v", *usage[1])
+	}
+}
+
+func TestDHCPv4States(t *testing.T) {
+	leases := []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.100"), BindingState: "active", VendorClassIdentifier: "MSFT 5.0"},
+		{IP: netip.MustParseAddr("192.168.1.101"), BindingState: "active", VendorClassIdentifier: "MSFT 5.0"},
+		{IP: netip.MustParseAddr("192.168.1.101"), BindingState: "free", VendorClassIdentifier: "MSFT 5.0"},
+		{IP: netip.MustParseAddr("192.168.1.102"), BindingState: "active", VendorClassIdentifier: "android-dhcp-12"},
+	}
+
+	states := DHCPv4States(leases)
+	if states["active"] != 2 || states["free"] != 1 {
+		t.Errorf("expected two active and one free but was %v", states)
+	}
+
+	classes := DHCPv4VendorClasses(leases)
+	if classes["MSFT 5.0"] != 1 || classes["android-dhcp-12"] != 1 {
+		t.Errorf("expected one active lease per vendor class but was %v", classes)
+	}
+}
+
+func TestSites(t *testing.T) {
+	conf, err := dhcpdconf.Parse(strings.NewReader(`
+subnet 10.0.0.0 netmask 255.255.255.0 {
+  range 10.0.0.100 10.0.0.109;
+}
+`))
+	if err != nil {
+		t.Fatalf("parse conf: %v", err)
+	}
+	// Both sites lease the same private addresses
+	leases := []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("10.0.0.100"), BindingState: "active", VendorClassIdentifier: "MSFT 5.0", Site: "dc1"},
+		{IP: netip.MustParseAddr("10.0.0.101"), BindingState: "active", VendorClassIdentifier: "MSFT 5.0", Site: "dc1"},
+		{IP: netip.MustParseAddr("10.0.0.100"), BindingState: "active", VendorClassIdentifier: "MSFT 5.0", Site: "dc2"},
+	}
+
+	if states := DHCPv4States(leases); states["active"] != 3 {
+		t.Errorf("expected three active but was %v", states)
+	}
+	if classes := DHCPv4VendorClasses(leases); classes["MSFT 5.0"] != 3 {
+		t.Errorf("expected three active MSFT 5.0 leases but was %v", classes)
+	}
+
+	usage := DHCPv4(conf, leases)
+	if len(usage) != 2 {
+		t.Fatalf("expected the range at each site but was %d", len(usage))
+	}
+	for i, expected := range []Usage{{Site: "dc1", Total: 10, Active: 2, Free: 8}, {Site: "dc2", Total: 10, Active: 1, Free: 9}} {
+		u := usage[i]
+		if u.Site != expected.Site || u.Total != expected.Total || u.Active != expected.Active || u.Free != expected.Free {
+			t.Errorf("expected %+v but was %+v", expected, *u)
+		}
+	}
+
+	v6leases := []dhcpd6.DHCPv6Lease{
+		{Type: dhcpd6.DHCPv6LeaseTypeNonTemporary, Site: "dc1", Addrs: []*dhcpd6.DHCPv6LeaseAddr{{IP: netip.MustParseAddr("fd00::1"), BindingState: "active"}}},
+		{Type: dhcpd6.DHCPv6LeaseTypeNonTemporary, Site: "dc2", Addrs: []*dhcpd6.DHCPv6LeaseAddr{{IP: netip.MustParseAddr("fd00::1"), BindingState: "active"}}},
+	}
+	if states := DHCPv6States(v6leases); states[dhcpd6.DHCPv6LeaseTypeNonTemporary]["active"] != 2 {
+		t.Errorf("expected two active but was %v", states)
+	}
+}
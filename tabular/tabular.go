@@ -0,0 +1,389 @@
+// Package tabular flattens leases into rows of named columns, written as CSV
+// or TSV, for spreadsheets. A v6 lease is a row per address.
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/inventory"
+)
+
+// Column is a value of either family of lease, empty where it doesn't apply
+type Column struct {
+	Name        string
+	Description string
+	v4          func(lease *dhcpd.DHCPv4Lease) string
+	v6          func(lease *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string
+	// Set by the client, so it may be anything, see cell
+	client bool
+}
+
+// Columns are all those available, in their usual order
+var Columns = []*Column{
+	{
+		Name:        "family",
+		Description: "4 or 6",
+		v4:          func(*dhcpd.DHCPv4Lease) string { return "4" },
+		v6:          func(*dhcpd6.DHCPv6Lease, *dhcpd6.DHCPv6LeaseAddr) string { return "6" },
+	},
+	{
+		Name:        "type",
+		Description: "ia-na, ia-ta or ia-pd, v6 only",
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return string(lease.Type)
+		},
+	},
+	{
+		Name: "ip",
+		v4:   func(lease *dhcpd.DHCPv4Lease) string { return lease.IP.String() },
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil {
+				return ""
+			}
+			return addr.IP.String()
+		},
+	},
+	{
+		Name:        "prefix-len",
+		Description: "of a delegated prefix",
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil || addr.PrefixLen == 0 {
+				return ""
+			}
+			return strconv.Itoa(addr.PrefixLen)
+		},
+	},
+	{
+		Name: "binding-state",
+		v4:   func(lease *dhcpd.DHCPv4Lease) string { return lease.BindingState },
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil {
+				return ""
+			}
+			return addr.BindingState
+		},
+	},
+	{
+		Name: "starts",
+		v4:   func(lease *dhcpd.DHCPv4Lease) string { return formatTime(lease.Starts) },
+	},
+	{
+		Name: "ends",
+		v4:   func(lease *dhcpd.DHCPv4Lease) string { return formatTime(lease.Ends) },
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil {
+				return ""
+			}
+			return formatTime(addr.Ends)
+		},
+	},
+	{
+		Name:        "cltt",
+		Description: "client's last transaction time",
+		v4:          func(lease *dhcpd.DHCPv4Lease) string { return formatTime(lease.CLTT) },
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return formatTime(lease.CLTT)
+		},
+	},
+	{
+		Name:        "hwaddr",
+		Description: "client MAC, from the hardware address, client identifier or DUID",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return macString(inventory.DHCPv4HardwareAddr(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return macString(inventory.DHCPv6HardwareAddr(lease))
+		},
+	},
+	{
+		Name:        "vendor",
+		Description: "of the MAC, from the IEEE registry",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return vendor(inventory.DHCPv4HardwareAddr(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return vendor(inventory.DHCPv6HardwareAddr(lease))
+		},
+	},
+	{
+		Name:   "client-hostname",
+		v4:     func(lease *dhcpd.DHCPv4Lease) string { return lease.ClientHostname },
+		client: true,
+	},
+	{
+		Name:   "vendor-class-identifier",
+		v4:     func(lease *dhcpd.DHCPv4Lease) string { return lease.VendorClassIdentifier },
+		client: true,
+	},
+	{
+		Name:        "uid",
+		Description: "client identifier as hex",
+		v4:          func(lease *dhcpd.DHCPv4Lease) string { return hex.EncodeToString(lease.UID) },
+	},
+	{
+		Name:        "iaid",
+		Description: "as hex",
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return hex.EncodeToString(lease.IAID)
+		},
+	},
+	{
+		Name:        "duid",
+		Description: "as colon separated hex, of v6 and RFC 4361 v4 clients",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return duidString(v4DUID(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return duidString(lease.DUID)
+		},
+	},
+	{
+		Name:        "duid-type",
+		Description: "DUID-LLT, DUID-EN or DUID-LL",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return duidType(v4DUID(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return duidType(lease.DUID)
+		},
+	},
+	{
+		Name:        "enterprise-number",
+		Description: "of a DUID-EN",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return enterpriseNumber(v4DUID(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return enterpriseNumber(lease.DUID)
+		},
+	},
+	{
+		Name:        "enterprise",
+		Description: "of a DUID-EN, from the IANA registry",
+		v4: func(lease *dhcpd.DHCPv4Lease) string {
+			return enterprise(v4DUID(lease))
+		},
+		v6: func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string {
+			return enterprise(lease.DUID)
+		},
+	},
+	{
+		Name:   "ddns-fwd-name",
+		v4:     func(lease *dhcpd.DHCPv4Lease) string { return lease.DDNSFwdName },
+		client: true,
+	},
+	{
+		Name:        "source",
+		Description: "server the lease is from, when several are read",
+		v4:          func(lease *dhcpd.DHCPv4Lease) string { return lease.Source },
+		v6:          func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string { return lease.Source },
+	},
+	{
+		Name:        "site",
+		Description: "of the server the lease is from",
+		v4:          func(lease *dhcpd.DHCPv4Lease) string { return lease.Site },
+		v6:          func(lease *dhcpd6.DHCPv6Lease, _ *dhcpd6.DHCPv6LeaseAddr) string { return lease.Site },
+	},
+	{
+		Name: "preferred-life",
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil {
+				return ""
+			}
+			return strconv.Itoa(addr.PreferredLife)
+		},
+	},
+	{
+		Name: "max-life",
+		v6: func(_ *dhcpd6.DHCPv6Lease, addr *dhcpd6.DHCPv6LeaseAddr) string {
+			if addr == nil {
+				return ""
+			}
+			return strconv.Itoa(addr.MaxLife)
+		},
+	},
+}
+
+// DefaultColumns are written unless others are chosen
+var DefaultColumns = []string{"family", "ip", "prefix-len", "binding-state", "starts", "ends", "hwaddr", "vendor", "client-hostname", "duid", "enterprise"}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func macString(mac net.HardwareAddr, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return mac.String()
+}
+
+func vendor(mac net.HardwareAddr, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return inventory.Vendor(mac)
+}
+
+func v4DUID(lease *dhcpd.DHCPv4Lease) *duid.DUID {
+	if lease.ClientID == nil {
+		return nil
+	}
+	return lease.ClientID.DUID()
+}
+
+func duidString(d *duid.DUID) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+func duidType(d *duid.DUID) string {
+	if d == nil {
+		return ""
+	}
+	return d.Type.String()
+}
+
+func enterpriseNumber(d *duid.DUID) string {
+	if d == nil || d.EN == nil {
+		return ""
+	}
+	return d.EN.EN.String()
+}
+
+func enterprise(d *duid.DUID) string {
+	if d == nil || d.EN == nil {
+		return ""
+	}
+	return d.EN.EN.Organization()
+}
+
+// cell is the value of a column as written. Spreadsheets evaluate a cell
+// starting with =, +, - or @ as a formula, so a client could name itself
+// =HYPERLINK(...), and those values are quoted with a leading ' instead, see:
+// https://owasp.org/www-community/attacks/CSV_Injection
+func (c *Column) cell(v string) string {
+	if c.client && v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// ParseColumns reads comma separated column names, all the defaults when empty
+func ParseColumns(s string) ([]*Column, error) {
+	names := DefaultColumns
+	if s != "" {
+		names = strings.Split(s, ",")
+	}
+	var columns []*Column
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		column := lookup(name)
+		if column == nil {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func lookup(name string) *Column {
+	for _, c := range Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Writer writes leases as rows after a header of the column names
+type Writer struct {
+	w       *csv.Writer
+	columns []*Column
+	header  bool
+}
+
+// NewWriter writes CSV, or TSV when comma is a tab
+func NewWriter(w io.Writer, comma rune, columns []*Column) *Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &Writer{w: cw, columns: columns}
+}
+
+func (w *Writer) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	names := make([]string, 0, len(w.columns))
+	for _, c := range w.columns {
+		names = append(names, c.Name)
+	}
+	return w.w.Write(names)
+}
+
+// DHCPv4 writes a row of the lease
+func (w *Writer) DHCPv4(lease *dhcpd.DHCPv4Lease) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	row := make([]string, 0, len(w.columns))
+	for _, c := range w.columns {
+		var v string
+		if c.v4 != nil {
+			v = c.cell(c.v4(lease))
+		}
+		row = append(row, v)
+	}
+	return w.w.Write(row)
+}
+
+// DHCPv6 writes a row for each address of the lease, or one without an
+// address if it has none.
+func (w *Writer) DHCPv6(lease *dhcpd6.DHCPv6Lease) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	addrs := lease.Addrs
+	if len(addrs) == 0 {
+		addrs = []*dhcpd6.DHCPv6LeaseAddr{nil}
+	}
+	for _, addr := range addrs {
+		row := make([]string, 0, len(w.columns))
+		for _, c := range w.columns {
+			var v string
+			if c.v6 != nil {
+				v = c.cell(c.v6(lease, addr))
+			}
+			row = append(row, v)
+		}
+		if err := w.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered rows, and the header if there were none
+func (w *Writer) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
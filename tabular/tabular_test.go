@@ -0,0 +1,108 @@
+package tabular
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/dhcpd6"
+	"github.com/cptaffe/isc-dhcpd-lease-parser/duid"
+)
+
+func TestWriter(t *testing.T) {
+	starts := time.Date(2021, time.December, 25, 22, 27, 49, 0, time.FixedZone("CST", -6*60*60))
+	d, err := duid.ParseDUIDString("00:02:00:00:00:09:0c:c0:84:d3:03:00:09:12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := ParseColumns("family,ip,prefix-len,binding-state,starts,hwaddr,client-hostname,enterprise-number")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	w := NewWriter(&b, ',', columns)
+	err = w.DHCPv4(&dhcpd.DHCPv4Lease{
+		IP:               netip.MustParseAddr("192.168.1.107"),
+		Starts:           &starts,
+		BindingState:     "active",
+		HardwareEthernet: "8c:dc:d4:2b:ec:6c",
+		ClientHostname:   "wopr, the computer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.DHCPv6(&dhcpd6.DHCPv6Lease{
+		Type: dhcpd6.DHCPv6LeaseTypePrefixDelegation,
+		DUID: d,
+		Addrs: []*dhcpd6.DHCPv6LeaseAddr{
+			{IP: netip.MustParseAddr("2001:db8:1::"), PrefixLen: 56, BindingState: "active"},
+			{IP: netip.MustParseAddr("2001:db8:2::"), PrefixLen: 56, BindingState: "expired"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `family,ip,prefix-len,binding-state,starts,hwaddr,client-hostname,enterprise-number
+4,192.168.1.107,,active,2021-12-26T04:27:49Z,8c:dc:d4:2b:ec:6c,"wopr, the computer",
+6,2001:db8:1::,56,active,,,,9
+6,2001:db8:2::,56,expired,,,,9
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestFormulas(t *testing.T) {
+	columns, err := ParseColumns("ip,client-hostname,vendor-class-identifier,ddns-fwd-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	w := NewWriter(&b, ',', columns)
+	for _, lease := range []dhcpd.DHCPv4Lease{
+		{IP: netip.MustParseAddr("192.168.1.107"), ClientHostname: `=HYPERLINK("http://example.com","wopr")`, VendorClassIdentifier: "+1", DDNSFwdName: "@sum"},
+		{IP: netip.MustParseAddr("192.168.1.108"), ClientHostname: "-2+3", VendorClassIdentifier: "\tx", DDNSFwdName: "\rx"},
+		{IP: netip.MustParseAddr("192.168.1.109"), ClientHostname: "joe=1", VendorClassIdentifier: "MSFT 5.0"},
+	} {
+		if err := w.DHCPv4(&lease); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "ip,client-hostname,vendor-class-identifier,ddns-fwd-name\n" +
+		"192.168.1.107,\"'=HYPERLINK(\"\"http://example.com\"\",\"\"wopr\"\")\",'+1,'@sum\n" +
+		"192.168.1.108,'-2+3,'\tx,\"'\rx\"\n" +
+		"192.168.1.109,joe=1,MSFT 5.0,\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestTSV(t *testing.T) {
+	columns, err := ParseColumns("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	w := NewWriter(&b, '\t', columns)
+	// A header, even without leases
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "family\tip\tprefix-len\tbinding-state\tstarts\tends\thwaddr\tvendor\tclient-hostname\tduid\tenterprise\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+	if _, err := ParseColumns("ip,colour"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}